@@ -3,23 +3,61 @@ package agent
 
 import (
 	"context"
+	"strings"
 
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 )
 
 type Agent interface {
-	// 添加工具
-	// AddTools(tools ...tool.InvokableTool) error
 	HandleQuestion(ctx context.Context, question string, history []*schema.Message) (chatHistory []*schema.Message, err error)
 }
 
+// ToolManager 由支持在会话中调整工具集的 agent 实现
+// 对工具集的增删、启用与禁用会在下次调用模型前自动重新绑定
+type ToolManager interface {
+	Tools() ToolSet
+	// 添加工具
+	AddTools(tools ...tool.InvokableTool) error
+}
+
+// ToolSet 是 agent 在会话中可以调整的工具集, 由 tools.ToolSet 实现
+type ToolSet interface {
+	// Names 按注册顺序返回所有工具的完整名称
+	Names() []string
+	// Describe 列出所有工具的名称、别名、状态与描述
+	Describe() string
+	Enable(name string) error
+	// Disable 禁用工具, agent 必需的工具(例如 bell)不能被禁用
+	Disable(name string) error
+}
+
+// ModelSwitcher 由支持在会话中切换模型的 agent 实现
+type ModelSwitcher interface {
+	// SwitchModel 改用配置中 models 定义的模型, 不是其中的名称时沿用当前提供方的配置改用该模型 ID
+	SwitchModel(ctx context.Context, model string) error
+}
+
 const (
 	AgentSpaceman = "spaceman"
+	AgentNetizen  = "netizen"
 )
 
 // CreateAgentFunc 定义创建 agent 的函数类型
 type CreateAgentFunc func(ctx context.Context, task string) (Agent, error)
 
+// Summary 返回对话中最后一条非空的 assistant 回复, 即 agent 结束任务前给出的结论
+// 没有这样的回复时返回空字符串
+func Summary(history []*schema.Message) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		msg := history[i]
+		if msg.Role == schema.Assistant && strings.TrimSpace(msg.Content) != "" {
+			return msg.Content
+		}
+	}
+	return ""
+}
+
 // func NewAgent(ctx context.Context, task string) (Agent, error) {
 // 	cfg, err := config.LoadConfig("config.yml")
 // 	if err != nil {
@@ -0,0 +1,70 @@
+// Package agenttest 提供 agent 对话循环的 golden transcript 测试工具
+// 配合 provider/replay 回放模型应答, 把 agent 产生的对话历史与 testdata 中的文件比对
+package agenttest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// UpdateEnv 设置为 1 时重写 golden 文件而不是比对
+const UpdateEnv = "COSMICA_UPDATE_GOLDEN"
+
+// Entry 是对话历史中一条消息的稳定表示, 忽略流式下标等与行为无关的字段
+type Entry struct {
+	Role       schema.RoleType `json:"role"`
+	Content    string          `json:"content,omitempty"`
+	ToolCalls  []Call          `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+type Call struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Transcript 把对话历史转换为可比对的形式
+func Transcript(history []*schema.Message) []Entry {
+	entries := make([]Entry, 0, len(history))
+	for _, msg := range history {
+		e := Entry{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+		for _, tc := range msg.ToolCalls {
+			e.ToolCalls = append(e.ToolCalls, Call{ID: tc.ID, Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Golden 比对对话历史与 testdata/<name>.golden.json
+func Golden(t testing.TB, name string, history []*schema.Message) {
+	t.Helper()
+	got, err := json.MarshalIndent(Transcript(history), "", "  ")
+	if err != nil {
+		t.Fatalf("marshal transcript: %v", err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with %s=1 to create it): %v", UpdateEnv, err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("transcript mismatch for %s (run with %s=1 to update)\n--- got\n%s\n--- want\n%s", path, UpdateEnv, got, want)
+	}
+}
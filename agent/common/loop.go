@@ -0,0 +1,421 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/instructions"
+	"github.com/bootun/cosmica/prompts"
+	"github.com/bootun/cosmica/provider"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/tools/base"
+	"github.com/bootun/cosmica/utils"
+	"github.com/bootun/cosmica/utils/logging"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const defaultMaxRetry = 10
+
+// runner 是 SpaceMan 与 Netizen 共用的对话循环: 生成回答, 调用工具, 直到模型调用 bell 或达到最大轮数
+type runner struct {
+	systemPrompt string
+	// promptName 与 promptVars 用于在工具集变更后重新渲染系统提示词, promptVersion 是渲染时工具集的版本
+	promptName    string
+	promptVars    prompts.Vars
+	promptVersion uint64
+	// instructionsDir 不为空时系统提示词附上该目录适用的项目说明
+	instructionsDir string
+	// base 是未绑定工具的模型, 工具集变更后基于它重新绑定
+	base         model.ToolCallingChatModel
+	model        model.ToolCallingChatModel
+	boundVersion uint64
+	caps         provider.Capabilities
+	toolSet      *tools.ToolSet
+	maxRetry     int
+	name         string
+	// cfg 创建 agent 时读取的配置, 模型与工具集都由调用方指定时为 nil
+	cfg       *config.Config
+	observers []agent.Observer
+	approver  agent.Approver
+	// closers 在 Close 时释放的资源, 例如 MCP 服务的连接
+	closers []io.Closer
+}
+
+// newRunner 根据选项组装对话循环, 未指定模型时按配置为 agentName 创建模型链
+// defaultTools 仅在未通过 WithToolSet 指定工具集时调用, 可以据 o 为子 agent 沿用选项, 返回的 closer 在 Close 时释放
+func newRunner(ctx context.Context, agentName, promptName string, defaultTools func(cfg *config.Config, o *options) (*tools.ToolSet, []io.Closer, error), opts ...Option) (*runner, error) {
+	o := &options{out: os.Stdout}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// 模型与工具集都已指定时不需要读取配置文件
+	var cfg *config.Config
+	if o.model == nil || o.toolSet == nil {
+		var err error
+		if cfg, err = config.LoadConfig("config.yml"); err != nil {
+			return nil, fmt.Errorf("load config: %w", err)
+		}
+	}
+
+	r := &runner{
+		promptName:      promptName,
+		promptVars:      o.promptVars,
+		instructionsDir: o.instructionsDir,
+		toolSet:         o.toolSet,
+		maxRetry:        defaultMaxRetry,
+		name:            agentName,
+		cfg:             cfg,
+		observers:       o.observers,
+		approver:        o.approver,
+	}
+	// 终端渲染只是观察者之一, 输出到 io.Discard 时不需要
+	if o.out != io.Discard {
+		theme := ""
+		if cfg != nil {
+			theme = cfg.UI.Theme
+		}
+		r.observers = append([]agent.Observer{NewTerminalRenderer(o.out, theme)}, r.observers...)
+	}
+	if r.toolSet == nil {
+		var err error
+		if r.toolSet, r.closers, err = defaultTools(cfg, o); err != nil {
+			return nil, fmt.Errorf("create tool set: %w", err)
+		}
+	}
+	if cfg != nil {
+		r.toolSet.SetAutoRepair(cfg.Tools.AutoRepair)
+	}
+	// 模型只能通过 bell 结束对话, 会话中不允许禁用或移除它
+	if err := r.toolSet.Require(base.BellName); err != nil && !errors.Is(err, tools.ErrToolNotFound) {
+		r.Close()
+		return nil, err
+	}
+	if err := r.renderSystemPrompt(ctx); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if o.model != nil {
+		r.base = o.model
+		r.caps = provider.Capabilities{ToolCalling: true}
+		if cm, ok := o.model.(provider.ChatModel); ok {
+			r.caps = cm.Capabilities()
+		}
+	} else {
+		var err error
+		if r.base, r.caps, err = newChatModel(ctx, cfg, agentName); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("create chat model: %w", err)
+		}
+	}
+	if err := r.bindTools(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close 释放 agent 持有的外部资源
+func (r *runner) Close() error {
+	var errs []error
+	for _, c := range r.closers {
+		errs = append(errs, c.Close())
+	}
+	r.closers = nil
+	return errors.Join(errs...)
+}
+
+// bindTools 在工具集变更后重新为模型绑定工具, 不支持工具调用的模型不绑定
+func (r *runner) bindTools() error {
+	version := r.toolSet.Version()
+	if r.model != nil && version == r.boundVersion {
+		return nil
+	}
+	r.model, r.boundVersion = r.base, version
+	if !r.caps.ToolCalling {
+		return nil
+	}
+	bound, err := r.base.WithTools(r.toolSet.Infos())
+	if err != nil {
+		return fmt.Errorf("bind tools: %w", err)
+	}
+	r.model = bound
+	return nil
+}
+
+// SwitchModel 改用指定的模型, 下次调用模型时生效
+func (r *runner) SwitchModel(ctx context.Context, name string) error {
+	if r.cfg == nil {
+		cfg, err := config.LoadConfig("config.yml")
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		r.cfg = cfg
+	}
+	m, ok := r.cfg.Models[name]
+	if ok {
+		m.Name = name
+	} else {
+		chain, err := r.cfg.ModelChain(r.name)
+		if err != nil {
+			return err
+		}
+		m = chain[0]
+		m.Name, m.ModelID = "", name
+	}
+	cm, err := provider.NewChain(ctx, []config.Model{m})
+	if err != nil {
+		return fmt.Errorf("create chat model: %w", err)
+	}
+	r.base, r.caps = cm, cm.Capabilities()
+	// 置空后下次调用模型前重新绑定工具
+	r.model = nil
+	return r.bindTools()
+}
+
+// subAgentLister 由可以委派任务给子 agent 的工具(create_agent)实现
+type subAgentLister interface {
+	SubAgents() []string
+}
+
+// renderSystemPrompt 用运行环境与工具集渲染系统提示词模板, override 中不为空的变量优先
+func renderSystemPrompt(ctx context.Context, name string, ts *tools.ToolSet, override prompts.Vars) (string, error) {
+	vars := prompts.Env().Merge(override)
+	if vars.Tools == nil {
+		for _, info := range ts.Infos() {
+			vars.Tools = append(vars.Tools, prompts.Tool{Name: tools.ModelName(info.Name), Description: firstParagraph(info.Desc)})
+		}
+	}
+	if vars.SubAgents == nil {
+		var names []string
+		for _, t := range ts.ToolList() {
+			if l, ok := t.(subAgentLister); ok {
+				names = append(names, l.SubAgents()...)
+			}
+		}
+		var err error
+		if vars.SubAgents, err = prompts.SubAgents(ctx, vars.Locale, names...); err != nil {
+			return "", err
+		}
+	}
+	return prompts.Render(ctx, name, vars)
+}
+
+// firstParagraph 返回工具描述的第一段并合并为一行, 完整的描述已经随工具定义发给模型
+func firstParagraph(desc string) string {
+	para, _, _ := strings.Cut(strings.TrimSpace(desc), "\n\n")
+	return strings.Join(strings.Fields(para), " ")
+}
+
+// renderSystemPrompt 在工具集变更后重新渲染系统提示词, 使提示词中的工具列表与 /tools enable|disable 后的工具集一致
+func (r *runner) renderSystemPrompt(ctx context.Context) error {
+	version := r.toolSet.Version()
+	if r.systemPrompt != "" && version == r.promptVersion {
+		return nil
+	}
+	prompt, err := renderSystemPrompt(ctx, r.promptName, r.toolSet, r.promptVars)
+	if err != nil {
+		return fmt.Errorf("render system prompt: %w", err)
+	}
+	r.systemPrompt, r.promptVersion = prompt, version
+	return nil
+}
+
+// currentSystemPrompt 返回附上项目说明的系统提示词, 说明读取失败时只使用 agent 自己的提示词
+// 重新渲染失败时沿用上次渲染的提示词
+func (r *runner) currentSystemPrompt(ctx context.Context) string {
+	if err := r.renderSystemPrompt(ctx); err != nil {
+		slog.WarnContext(ctx, "refresh system prompt failed", "error", err)
+	}
+	if r.instructionsDir == "" {
+		return r.systemPrompt
+	}
+	prompt, err := instructions.SystemPrompt(r.systemPrompt, r.instructionsDir)
+	if err != nil {
+		slog.WarnContext(ctx, "load project instructions failed", "error", err)
+	}
+	return prompt
+}
+
+// extraSystemPromptKey 标记 agent 自己生成的系统提示词, 值为 agent 名称, 写入消息的 Extra 中
+// 内容与提示词相同的客户端消息没有该标记, 不会被当作 agent 的提示词替换掉
+const extraSystemPromptKey = "cosmica_system_prompt"
+
+// systemMessage 返回带有标记的系统提示词消息
+func (r *runner) systemMessage(prompt string) *schema.Message {
+	msg := schema.SystemMessage(prompt)
+	msg.Extra = map[string]any{extraSystemPromptKey: r.name}
+	return msg
+}
+
+// isOwnSystemPrompt 报告 msg 是否为 agent 生成的系统提示词
+func isOwnSystemPrompt(msg *schema.Message) bool {
+	if msg.Role != schema.System {
+		return false
+	}
+	_, ok := msg.Extra[extraSystemPromptKey]
+	return ok
+}
+
+// Tools 返回 agent 的工具集, 对工具集的修改会在下次调用模型时生效
+func (r *runner) Tools() agent.ToolSet {
+	return r.toolSet
+}
+
+// AddTools 向 agent 的工具集中添加工具
+func (r *runner) AddTools(ts ...tool.InvokableTool) error {
+	for _, t := range ts {
+		if err := r.toolSet.AddTool(t); err != nil {
+			return fmt.Errorf("add tool: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *runner) HandleQuestion(ctx context.Context, question string, history []*schema.Message) (chatHistory []*schema.Message, err error) {
+	ctx, src, emit := r.startRun(ctx)
+	ctx, span := startTurnSpan(ctx, src)
+	ctx = logging.WithAttrs(ctx, slog.String("agent", r.name), slog.String("turn", src.RunID))
+	emit(ctx, agent.TurnStarted{Source: src, Question: question})
+	i := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("cosmica.iterations", i))
+		endSpan(span, err)
+		if err != nil {
+			emit(ctx, agent.Failed{Source: src, Err: err})
+			return
+		}
+		emit(ctx, agent.Finished{Source: src, Reply: agent.Summary(chatHistory), Iterations: i})
+	}()
+
+	systemPrompt := r.currentSystemPrompt(ctx)
+	switch {
+	case len(history) < 1 || !isOwnSystemPrompt(history[0]):
+		// 不以 agent 自己的提示词开头的历史(例如来自 HTTP 客户端)补上提示词
+		// 客户端的系统消息排在其后, 只作为补充, 不能替换 agent 的提示词
+		chatHistory = append([]*schema.Message{
+			r.systemMessage(systemPrompt),
+		}, history...)
+	case history[0].Content != systemPrompt || history[0].Extra[extraSystemPromptKey] != r.name:
+		// agent 自己的提示词换成最新的, 使对话中新增的项目说明(例如 /memory add)生效
+		chatHistory = append([]*schema.Message{
+			r.systemMessage(systemPrompt),
+		}, history[1:]...)
+	default:
+		chatHistory = history
+	}
+	chatHistory = append(chatHistory, schema.UserMessage(question))
+
+	finished := false
+	for {
+		if i > r.maxRetry || finished {
+			break
+		}
+		i++
+		// 被取消的对话不再继续调用模型或工具
+		if err := ctx.Err(); err != nil {
+			return chatHistory, err
+		}
+		if err := r.bindTools(); err != nil {
+			return chatHistory, err
+		}
+		emit(ctx, agent.StepStarted{Source: src, Iteration: i})
+		// 生成回答
+		modelCtx, modelSpan := startModelSpan(ctx, i)
+		stream, err := r.model.Stream(modelCtx, fitContext(withoutImages(chatHistory, r.caps.Vision), r.caps.MaxContext))
+		if err != nil {
+			endModelSpan(modelSpan, nil, err)
+			return chatHistory, fmt.Errorf("chat with stream: %w", err)
+		}
+		msg, err := utils.DealStream(stream, func(msg *schema.Message) {
+			if msg.Content != "" {
+				emit(ctx, agent.TokenDelta{Source: src, Content: msg.Content})
+			}
+		})
+		endModelSpan(modelSpan, msg, err)
+		if err != nil {
+			slog.WarnContext(ctx, "model stream failed", "iteration", i, "error", err)
+			return chatHistory, fmt.Errorf("deal message: %w", err)
+		}
+		step := agent.StepFinished{Source: src, Iteration: i, Model: provider.ModelOf(msg)}
+		if msg.ResponseMeta != nil {
+			step.Usage = msg.ResponseMeta.Usage
+		}
+		emit(ctx, step)
+		reply := schema.AssistantMessage(msg.Content, msg.ToolCalls)
+		// 保留实际应答的模型等信息
+		reply.Extra = msg.Extra
+		chatHistory = append(chatHistory, reply)
+
+		if len(msg.ToolCalls) > 0 {
+			// 工具调用
+			for _, toolCall := range msg.ToolCalls {
+				emit(ctx, agent.ToolCallRequested{Source: src, Call: toolCall})
+				toolCtx, toolSpan := startToolSpan(ctx, toolCall)
+				toolCtx = logging.WithAttrs(toolCtx, slog.String("tool", toolCall.Function.Name), slog.String("tool_call", toolCall.ID))
+				if r.approver != nil && !r.approver(toolCtx, toolCall) {
+					toolSpan.SetAttributes(attribute.Bool("cosmica.tool.rejected", true))
+					slog.InfoContext(toolCtx, "tool call rejected")
+					endSpan(toolSpan, ctx.Err())
+					if err := ctx.Err(); err != nil {
+						return chatHistory, err
+					}
+					emit(ctx, agent.ToolResult{Source: src, Call: toolCall, Rejected: true})
+					chatHistory = append(chatHistory, schema.ToolMessage("用户拒绝了这次工具调用", toolCall.ID))
+					continue
+				}
+				// 校验参数并调用工具
+				start := time.Now()
+				res, err := r.toolSet.Invoke(toolCtx, toolCall.Function.Name, toolCall.Function.Arguments)
+				endSpan(toolSpan, err)
+				emit(ctx, agent.ToolResult{Source: src, Call: toolCall, Result: res, Err: err, Duration: time.Since(start)})
+				if err != nil {
+					slog.WarnContext(toolCtx, "tool call failed", "error", err, logging.Digest("arguments", toolCall.Function.Arguments))
+					chatHistory = append(chatHistory, schema.ToolMessage(fmt.Sprintf("调用工具出现了错误: %v", err), toolCall.ID))
+					continue
+				}
+				chatHistory = append(chatHistory, schema.ToolMessage(res, toolCall.ID))
+				if res == base.FinishFlag {
+					finished = true
+					break
+				}
+			}
+		} else if !r.caps.ToolCalling {
+			// 模型无法调用 bell 结束对话, 给出回答即视为结束
+			finished = true
+		}
+	}
+	return
+}
+
+var runSeq atomic.Int64
+
+// startRun 为一次 HandleQuestion 分配 RunID, 返回携带事件出口的 context
+// 事件先交给自己的观察者, 再转交给父 agent, 父 agent 的观察者因此能看到子 agent 的全部事件
+func (r *runner) startRun(ctx context.Context) (context.Context, agent.Source, func(context.Context, agent.Event)) {
+	src := agent.Source{Agent: r.name, RunID: fmt.Sprintf("%s-%d", r.name, runSeq.Add(1))}
+	parentCtx := ctx
+	if parent, ok := agent.SourceFrom(ctx); ok {
+		src.Parent = parent.RunID
+		src.Depth = parent.Depth + 1
+	}
+	emit := func(ctx context.Context, ev agent.Event) {
+		for _, obs := range r.observers {
+			obs.OnEvent(ctx, ev)
+		}
+		agent.Emit(parentCtx, ev)
+	}
+	return agent.WithEmitter(ctx, src, emit), src, emit
+}
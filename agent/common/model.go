@@ -0,0 +1,127 @@
+package common
+
+import (
+	"context"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/provider"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// newChatModel 按配置为 agent 创建模型链
+func newChatModel(ctx context.Context, cfg *config.Config, agentName string) (model.ToolCallingChatModel, provider.Capabilities, error) {
+	chain, err := cfg.ModelChain(agentName)
+	if err != nil {
+		return nil, provider.Capabilities{}, err
+	}
+	cm, err := provider.NewChain(ctx, chain)
+	if err != nil {
+		return nil, provider.Capabilities{}, err
+	}
+	return cm, cm.Capabilities(), nil
+}
+
+// fitContext 在对话超出模型上下文窗口时丢弃最早的非系统消息
+// token 数按每 4 个字节约 1 个 token 粗略估算
+func fitContext(history []*schema.Message, maxContext int) []*schema.Message {
+	if maxContext <= 0 {
+		return history
+	}
+	estimate := func(msg *schema.Message) int {
+		n := len(msg.Content)
+		for _, tc := range msg.ToolCalls {
+			n += len(tc.Function.Name) + len(tc.Function.Arguments)
+		}
+		return n/4 + 1
+	}
+	total := 0
+	for _, msg := range history {
+		total += estimate(msg)
+	}
+	if total <= maxContext {
+		return history
+	}
+
+	var system []*schema.Message
+	rest := history
+	for len(rest) > 0 && rest[0].Role == schema.System {
+		system = append(system, rest[0])
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return history
+	}
+	// 按轮整段丢弃, 使对话仍从用户消息开始(Anthropic 等接口要求第一条是用户消息)
+	for total > maxContext {
+		next := -1
+		for i := 1; i < len(rest); i++ {
+			if rest[i].Role == schema.User {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		for _, msg := range rest[:next] {
+			total -= estimate(msg)
+		}
+		rest = rest[next:]
+	}
+	// 只剩当前一轮时保留提问, 从最早的工具调用开始丢弃
+	// 至少保留最后一条消息; 工具结果不能脱离对应的工具调用单独存在
+	question, steps := rest[0], rest[1:]
+	for len(steps) > 1 && total > maxContext {
+		total -= estimate(steps[0])
+		steps = steps[1:]
+		for len(steps) > 1 && steps[0].Role == schema.Tool {
+			total -= estimate(steps[0])
+			steps = steps[1:]
+		}
+	}
+	fitted := append(system, question)
+	return append(fitted, steps...)
+}
+
+// withoutImages 在模型不支持图片输入时把消息中的图片换成文字说明, 而不是让接口报错
+// 没有图片的消息原样返回, 调用方的历史不会被修改
+func withoutImages(history []*schema.Message, vision bool) []*schema.Message {
+	if vision {
+		return history
+	}
+	var out []*schema.Message
+	for i, msg := range history {
+		if !hasImage(msg) {
+			if out != nil {
+				out = append(out, msg)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]*schema.Message, 0, len(history)), history[:i]...)
+		}
+		cp := *msg
+		cp.MultiContent = make([]schema.ChatMessagePart, 0, len(msg.MultiContent))
+		for _, part := range msg.MultiContent {
+			if part.Type == schema.ChatMessagePartTypeImageURL {
+				part = schema.ChatMessagePart{Type: schema.ChatMessagePartTypeText, Text: "[image omitted: the model does not accept images]"}
+			}
+			cp.MultiContent = append(cp.MultiContent, part)
+		}
+		out = append(out, &cp)
+	}
+	if out == nil {
+		return history
+	}
+	return out
+}
+
+func hasImage(msg *schema.Message) bool {
+	for _, part := range msg.MultiContent {
+		if part.Type == schema.ChatMessagePartTypeImageURL {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func roles(history []*schema.Message) string {
+	var rs []string
+	for _, msg := range history {
+		rs = append(rs, string(msg.Role))
+	}
+	return strings.Join(rs, ",")
+}
+
+func TestFitContext(t *testing.T) {
+	long := strings.Repeat("x", 400)
+	call := []schema.ToolCall{{ID: "1", Function: schema.FunctionCall{Name: "file_reader"}}}
+	history := []*schema.Message{
+		schema.SystemMessage("prompt"),
+		schema.UserMessage(long),
+		schema.AssistantMessage(long, nil),
+		schema.UserMessage("second"),
+		schema.AssistantMessage("", call),
+		schema.ToolMessage(long, "1"),
+		schema.UserMessage("third"),
+	}
+	if got := fitContext(history, 1000); len(got) != len(history) {
+		t.Errorf("history within the window was trimmed: %s", roles(got))
+	}
+	// 整轮丢弃, 第一条非系统消息仍是用户消息
+	if got := roles(fitContext(history, 150)); got != "system,user,assistant,tool,user" {
+		t.Errorf("trimmed = %s", got)
+	}
+	if got := roles(fitContext(history, 10)); got != "system,user" {
+		t.Errorf("trimmed = %s", got)
+	}
+
+	// 只剩当前一轮时保留提问, 丢弃最早的工具调用及其结果
+	turn := []*schema.Message{
+		schema.SystemMessage("prompt"),
+		schema.UserMessage("question"),
+		schema.AssistantMessage("", call),
+		schema.ToolMessage(long, "1"),
+		schema.AssistantMessage("", call),
+		schema.ToolMessage("short", "1"),
+	}
+	if got := roles(fitContext(turn, 50)); got != "system,user,assistant,tool" {
+		t.Errorf("trimmed = %s", got)
+	}
+}
+
+func TestWithoutImages(t *testing.T) {
+	image := &schema.Message{Role: schema.User, MultiContent: []schema.ChatMessagePart{
+		{Type: schema.ChatMessagePartTypeText, Text: "这是什么"},
+		{Type: schema.ChatMessagePartTypeImageURL, ImageURL: &schema.ChatMessageImageURL{URL: "data:image/png;base64,AAAA"}},
+	}}
+	history := []*schema.Message{schema.SystemMessage("prompt"), image}
+	if got := withoutImages(history, true); got[1] != image {
+		t.Error("images should be kept for vision models")
+	}
+	got := withoutImages(history, false)
+	if parts := got[1].MultiContent; len(parts) != 2 || parts[1].Type != schema.ChatMessagePartTypeText || parts[0].Text != "这是什么" {
+		t.Errorf("parts = %+v", parts)
+	}
+	if image.MultiContent[1].Type != schema.ChatMessagePartTypeImageURL {
+		t.Error("the caller's history should not be modified")
+	}
+	plain := []*schema.Message{schema.UserMessage("hi")}
+	if got := withoutImages(plain, false); &got[0] != &plain[0] {
+		t.Error("history without images should be returned as is")
+	}
+}
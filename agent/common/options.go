@@ -0,0 +1,76 @@
+package common
+
+import (
+	"io"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/prompts"
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/components/model"
+)
+
+// Option 定制 agent 的构造过程, 主要用于替换模型与工具集
+type Option func(*options)
+
+type options struct {
+	model   model.ToolCallingChatModel
+	toolSet *tools.ToolSet
+	out     io.Writer
+	// observers 接收对话循环的事件
+	observers []agent.Observer
+	approver  agent.Approver
+	// promptVars 中不为空的变量覆盖从运行环境得到的提示词变量
+	promptVars prompts.Vars
+	// instructionsDir 不为空时在系统提示词后附上该目录适用的项目说明
+	instructionsDir string
+}
+
+// WithChatModel 使用指定的模型, 不再从配置文件创建
+// 模型若实现了 provider.ChatModel, 则按其声明的能力调整行为
+func WithChatModel(m model.ToolCallingChatModel) Option {
+	return func(o *options) {
+		o.model = m
+	}
+}
+
+// WithToolSet 使用指定的工具集替换默认工具集
+func WithToolSet(ts *tools.ToolSet) Option {
+	return func(o *options) {
+		o.toolSet = ts
+	}
+}
+
+// WithOutput 指定终端渲染输出回答与工具调用信息的位置, 默认为标准输出, io.Discard 表示不渲染
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.out = w
+	}
+}
+
+// WithObserver 添加接收对话循环事件的观察者, 子 agent 的事件同样会转交给它们
+func WithObserver(observers ...agent.Observer) Option {
+	return func(o *options) {
+		o.observers = append(o.observers, observers...)
+	}
+}
+
+// WithApprover 在调用工具前请求批准
+func WithApprover(a agent.Approver) Option {
+	return func(o *options) {
+		o.approver = a
+	}
+}
+
+// WithInstructions 每轮对话开始时读取 dir 及其父目录中的 COSMICA.md 与用户级说明, 附在系统提示词之后
+func WithInstructions(dir string) Option {
+	return func(o *options) {
+		o.instructionsDir = dir
+	}
+}
+
+// WithPromptVars 指定渲染系统提示词的变量, 不为空的字段覆盖从运行环境得到的值, 例如固定日期与工作目录
+func WithPromptVars(v prompts.Vars) Option {
+	return func(o *options) {
+		o.promptVars = v
+	}
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/utils/text"
+)
+
+// terminalRenderer 把回答与工具调用打印到终端, 子 agent 的输出同样打印
+type terminalRenderer struct {
+	out   io.Writer
+	theme text.Theme
+	// md 为 nil 时原样输出 markdown, 用于输出不是终端(例如重定向到文件)的情况
+	md *text.Markdown
+}
+
+// NewTerminalRenderer 返回把事件渲染到 w 的观察者, 未指定 WithOutput(io.Discard) 的 agent 默认使用它
+// w 是终端时以 theme 渲染 markdown, 否则原样输出且不带颜色
+func NewTerminalRenderer(w io.Writer, theme string) agent.Observer {
+	t := &terminalRenderer{out: w}
+	if text.IsTerminal(w) {
+		t.theme = text.ThemeFor(theme)
+		t.md = text.NewMarkdown(w, t.theme)
+	}
+	return t
+}
+
+func (t *terminalRenderer) OnEvent(ctx context.Context, ev agent.Event) {
+	switch ev := ev.(type) {
+	case agent.TokenDelta:
+		if t.md != nil {
+			t.md.Write([]byte(ev.Content))
+		} else {
+			fmt.Fprint(t.out, ev.Content)
+		}
+	case agent.StepFinished:
+		if t.md != nil {
+			t.md.Flush()
+		}
+		fmt.Fprintln(t.out)
+	case agent.ToolCallRequested:
+		fmt.Fprintln(t.out, t.theme.ToolCall.Render(fmt.Sprintf("<tool call: %s, args: %v>", ev.Call.Function.Name, ev.Call.Function.Arguments)))
+	case agent.ToolResult:
+		// 终端对话默认不输出警告日志, 工具出错在这里告知用户
+		if ev.Err != nil {
+			fmt.Fprintln(t.out, t.theme.ToolError.Render(fmt.Sprintf("<tool error: %s, %v>", ev.Call.Function.Name, ev.Err)))
+		}
+	}
+}
@@ -2,134 +2,129 @@ package common
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"errors"
+	"io"
+	"log/slog"
 
 	"github.com/bootun/cosmica/agent"
 	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/prompts"
+	"github.com/bootun/cosmica/provider"
 	"github.com/bootun/cosmica/tools"
 	"github.com/bootun/cosmica/tools/base"
+	"github.com/bootun/cosmica/tools/codesearch"
 	"github.com/bootun/cosmica/tools/compose"
 	"github.com/bootun/cosmica/tools/file"
+	"github.com/bootun/cosmica/tools/gocode"
+	"github.com/bootun/cosmica/tools/mcp"
+	"github.com/bootun/cosmica/tools/memory"
 	"github.com/bootun/cosmica/tools/shell"
-	"github.com/bootun/cosmica/utils"
-	"github.com/bootun/cosmica/utils/text"
-	"github.com/cloudwego/eino-ext/components/model/openai"
-	"github.com/cloudwego/eino/schema"
+	"github.com/cloudwego/eino/components/embedding"
 )
 
+var _ agent.ToolManager = (*SpaceMan)(nil)
+
 type SpaceMan struct {
-	model   *openai.ChatModel
-	toolSet *tools.ToolSet
+	*runner
 }
 
-func NewSpaceMan(ctx context.Context) (agent.Agent, error) {
-	cfg, err := config.LoadConfig("config.yml")
-	if err != nil {
-		return nil, fmt.Errorf("load config: %w", err)
-	}
-	spacemanCfg := cfg.Agents.Spaceman
-	chatModel, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
-		BaseURL: spacemanCfg.BaseURL,
-		APIKey:  spacemanCfg.Token,
-		Model:   spacemanCfg.ModelID,
-	})
+func NewSpaceMan(ctx context.Context, opts ...Option) (agent.Agent, error) {
+	r, err := newRunner(ctx, agent.AgentSpaceman, prompts.Spaceman,
+		func(cfg *config.Config, o *options) (*tools.ToolSet, []io.Closer, error) {
+			// 为AI配置工具集
+			ts, err := tools.NewToolSet(
+				shell.NewShellExecutor(),
+				base.NewBell(),
+				file.NewFileReader(),
+				file.NewDirReader(),
+				compose.NewAgentCreator(
+					NetizenFactory(childOptions(o)...),
+				),
+			)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !cfg.Memory.Disabled {
+				// 记忆不可用时 spaceman 仍然可以工作
+				store, err := newMemoryStore(ctx, cfg)
+				if err != nil {
+					slog.WarnContext(ctx, "open memory failed", "error", err)
+				} else {
+					for _, t := range memory.NewTools(store) {
+						if err := ts.AddTool(t); err != nil {
+							return nil, nil, err
+						}
+					}
+				}
+			}
+			if !cfg.CodeSearch.Disabled {
+				index, err := newCodeIndex(ctx, cfg)
+				if err != nil {
+					slog.WarnContext(ctx, "open code index failed", "error", err)
+				} else if err := ts.AddTool(codesearch.NewTool(index)); err != nil {
+					return nil, nil, err
+				}
+			}
+			// 工作目录位于 Go 模块中时提供基于类型信息的代码导航, 模块根目录由 go env GOMOD 确定
+			if !cfg.Tools.DisableGo {
+				w, err := gocode.NewWorkspace(".")
+				switch {
+				case errors.Is(err, gocode.ErrNoModule):
+				case err != nil:
+					// 没有安装 go 等情况下 spaceman 仍然可以工作
+					slog.WarnContext(ctx, "open go workspace failed", "error", err)
+				default:
+					for _, t := range gocode.NewTools(w) {
+						if err := ts.AddTool(t); err != nil {
+							return nil, nil, err
+						}
+					}
+				}
+			}
+			// 外部 MCP 服务的工具注册在 mcp.<服务名> 命名空间下
+			var closers []io.Closer
+			for _, s := range mcp.Attach(ctx, ts, cfg.MCPServers) {
+				closers = append(closers, s)
+			}
+			return ts, closers, nil
+		}, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("create chat model: %w", err)
+		return nil, err
 	}
+	return &SpaceMan{runner: r}, nil
+}
+
+// childOptions 返回子 agent 沿用的选项: 工具调用同样需要 spaceman 的审批者批准, 同样遵循项目说明
+// 子 agent 的事件经 context 转交给 spaceman 的观察者, 不再重复注册观察者, 也无需自己再渲染一遍
+func childOptions(o *options) []Option {
+	return []Option{WithOutput(io.Discard), WithApprover(o.approver), WithInstructions(o.instructionsDir)}
+}
 
-	// 为AI配置工具集
-	ts, err := tools.NewToolSet(
-		shell.NewShellExecutor(),
-		base.NewBell(),
-		file.NewFileReader(),
-		file.NewDirReader(),
-		compose.NewAgentCreator(
-			NewNetizen,
-		),
-	)
+// newMemoryStore 打开长期记忆, 配置了向量模型时按向量检索, 否则使用 BM25
+func newMemoryStore(ctx context.Context, cfg *config.Config) (*memory.Store, error) {
+	e, err := newEmbedder(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("create tool set: %w", err)
+		return nil, err
 	}
-	if err = chatModel.BindTools(ts.Infos()); err != nil {
-		return nil, fmt.Errorf("bind tools: %w", err)
-	}
-	return &SpaceMan{model: chatModel, toolSet: ts}, nil
+	return memory.Open(cfg.Memory.File, e, cfg.Embedding.ModelID)
 }
 
-// TODO(bootun): refactor me
-func (sm *SpaceMan) HandleQuestion(ctx context.Context, question string, history []*schema.Message) (chatHistory []*schema.Message, err error) {
-	if len(history) < 1 {
-		chatHistory = []*schema.Message{
-			schema.SystemMessage("你是spaceman, 一个严格遵守用户指令，不会偷懒的人工智能，负责规划并解决用户提出的问题。在进行所有行动之前，你需要预先规划为了完成这件事，接下来要做的事情，并告诉用户，然后才行动、调用工具等。"),
+// newCodeIndex 打开工作目录的代码索引, 索引在检索时于后台增量更新
+func newCodeIndex(ctx context.Context, cfg *config.Config) (*codesearch.Index, error) {
+	var e embedding.Embedder
+	if cfg.CodeSearch.Embeddings {
+		var err error
+		if e, err = newEmbedder(ctx, cfg); err != nil {
+			return nil, err
 		}
-	} else {
-		chatHistory = history
 	}
-	chatHistory = append(chatHistory, schema.UserMessage(question))
-
-	maxRetry := 10
-	i := 0
-	finished := false
-	for {
-		if i > maxRetry || finished {
-			break
-		}
-		i++
-		// 生成回答
-		stream, err := sm.model.Stream(ctx, chatHistory)
-		if err != nil {
-			return chatHistory, fmt.Errorf("chat with stream: %w", err)
-		}
-		msg, err := utils.DealStream(stream, func(msg *schema.Message) {
-			fmt.Print(msg.Content)
-		})
-		if err != nil {
-			return chatHistory, fmt.Errorf("deal message: %w", err)
-		}
-		fmt.Println()
-		chatHistory = append(chatHistory, schema.AssistantMessage(msg.Content, msg.ToolCalls))
-
-		if len(msg.ToolCalls) > 0 {
-			// 工具调用
-			for _, toolCall := range msg.ToolCalls {
-				toolName := toolCall.Function.Name
-				toolParams := toolCall.Function.Arguments
-
-				fmt.Println(text.Colorize(fmt.Sprintf("<tool call: %s, args: %v>", toolName, toolParams), text.Black, text.BgYellow))
-				// 获取工具
+	return codesearch.Open(".", cfg.CodeSearch.IndexFile, e, cfg.Embedding.ModelID)
+}
 
-				t, err := sm.toolSet.GetTool(toolName)
-				if err != nil {
-					log.Printf("获取%s工具调用出现错误: %v, 参数: %v", toolName, err, toolParams)
-					chatHistory = append(chatHistory, schema.ToolMessage(fmt.Sprintf("调用工具出现了错误: %v", err), toolCall.ID))
-					continue
-				}
-				// 调用工具
-				res, err := t.InvokableRun(ctx, toolParams)
-				if err != nil {
-					log.Printf("调用%s工具时出现了错误: %v, 参数: %v", toolName, err, toolParams)
-					chatHistory = append(chatHistory, schema.ToolMessage(fmt.Sprintf("调用工具出现了错误: %v", err), toolCall.ID))
-					continue
-				}
-				chatHistory = append(chatHistory, schema.ToolMessage(res, toolCall.ID))
-				if res == base.FinishFlag {
-					finished = true
-					break
-				}
-			}
-		}
+// newEmbedder 按配置创建向量模型, 未配置时返回 nil
+func newEmbedder(ctx context.Context, cfg *config.Config) (embedding.Embedder, error) {
+	if cfg.Embedding.ModelID == "" {
+		return nil, nil
 	}
-	return
+	return provider.NewEmbedder(ctx, cfg.Embedding)
 }
-
-// // AddTool implements agent.Agent.
-// func (sm *SpaceMan) AddTools(tools ...tool.InvokableTool) error {
-// 	for _, t := range tools {
-// 		if err := sm.toolSet.AddTool(t); err!= nil {
-// 			return fmt.Errorf("add tool: %w", err)
-// 		}
-// 	}
-// 	sm.model.BindTools(sm.toolSet.Infos())
-// 	return nil
-// }
@@ -0,0 +1,487 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/agent/agenttest"
+	"github.com/bootun/cosmica/instructions"
+	"github.com/bootun/cosmica/prompts"
+	"github.com/bootun/cosmica/provider/replay"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/tools/base"
+	"github.com/bootun/cosmica/tools/compose"
+	"github.com/bootun/cosmica/tools/file"
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMain(m *testing.M) {
+	// 测试不受运行环境的语言设置影响
+	prompts.Configure("", prompts.LocaleZH)
+	os.Exit(m.Run())
+}
+
+// goldenVars 固定系统提示词中的环境变量, 使 golden 文件与运行环境无关
+var goldenVars = WithPromptVars(prompts.Vars{OS: "linux", Cwd: "/work", Date: "2025-01-01"})
+
+func newTestToolSet(t *testing.T) *tools.ToolSet {
+	t.Helper()
+	ts, err := tools.NewToolSet(base.NewBell())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestSpaceManFinishesWithBell(t *testing.T) {
+	m := replay.NewScripted(
+		replay.Reply("你好, 有什么可以帮你?", replay.ToolCall("bell", nil)),
+	)
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(m), WithToolSet(newTestToolSet(t)), WithOutput(io.Discard), goldenVars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := sm.HandleQuestion(context.Background(), "你好", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agenttest.Golden(t, "spaceman_bell", history)
+	if n := m.Remaining(); n != 0 {
+		t.Errorf("%d scripted turns were not used", n)
+	}
+	if calls := m.Calls(); len(calls) != 1 || len(calls[0].Tools) != 1 {
+		t.Errorf("expected one call with the bell tool bound, got %+v", calls)
+	}
+}
+
+func TestSpaceManReportsUnknownTool(t *testing.T) {
+	m := replay.NewScripted(
+		replay.Reply("", replay.ToolCall("nope", `{}`)),
+		replay.Reply("没有这个工具", replay.ToolCall("bell", nil)),
+	)
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(m), WithToolSet(newTestToolSet(t)), WithOutput(io.Discard), goldenVars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := sm.HandleQuestion(context.Background(), "调用一个不存在的工具", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agenttest.Golden(t, "spaceman_unknown_tool", history)
+}
+
+func TestSpaceManDelegatesToNetizen(t *testing.T) {
+	netizenModel := replay.NewScripted(
+		replay.Reply("北京今天晴, 25度", replay.ToolCall("bell", nil)),
+	)
+	spacemanModel := replay.NewScripted(
+		replay.Reply("我让netizen查一下", replay.ToolCall("create_agent", map[string]string{
+			"name": "netizen",
+			"task": "查询北京今天的天气",
+		})),
+		replay.Reply("北京今天晴, 25度", replay.ToolCall("bell", nil)),
+	)
+
+	ts := newTestToolSet(t)
+	err := ts.AddTool(compose.NewAgentCreator(NetizenFactory(
+		WithChatModel(netizenModel),
+		WithToolSet(newTestToolSet(t)),
+		WithOutput(io.Discard),
+		goldenVars,
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(spacemanModel), WithToolSet(ts), WithOutput(io.Discard), goldenVars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := sm.HandleQuestion(context.Background(), "北京今天天气怎么样", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agenttest.Golden(t, "spaceman_delegate", history)
+
+	// netizen 收到的是 create_agent 下发的任务
+	calls := netizenModel.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("netizen was called %d times", len(calls))
+	}
+	agenttest.Golden(t, "netizen_task", calls[0].Input)
+}
+
+func TestSpaceManRebindsToolsAfterChange(t *testing.T) {
+	m := replay.NewScripted(
+		replay.Reply("", replay.ToolCall("bell", nil)),
+		replay.Reply("", replay.ToolCall("bell", nil)),
+		replay.Reply("", replay.ToolCall("bell", nil)),
+	)
+	ts := newTestToolSet(t)
+	if err := ts.AddTool(file.NewDirReader()); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewSpaceMan(context.Background(), WithChatModel(m), WithToolSet(ts), WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := a.(*SpaceMan)
+
+	var history []*schema.Message
+	ask := func() {
+		t.Helper()
+		if history, err = sm.HandleQuestion(context.Background(), "hi", history); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ask()
+	if err := sm.Tools().Disable("dir_reader"); err != nil {
+		t.Fatal(err)
+	}
+	ask()
+	if err := sm.AddTools(file.NewFileReader()); err != nil {
+		t.Fatal(err)
+	}
+	ask()
+
+	var bound []int
+	for _, c := range m.Calls() {
+		bound = append(bound, len(c.Tools))
+	}
+	if want := []int{2, 1, 2}; !reflect.DeepEqual(bound, want) {
+		t.Errorf("tools bound per call = %v, want %v", bound, want)
+	}
+	// 系统提示词中的工具列表随工具集一起更新
+	for i, want := range []bool{true, false, false} {
+		if prompt := m.Calls()[i].Input[0].Content; strings.Contains(prompt, "- dir_reader:") != want {
+			t.Errorf("call %d lists dir_reader = %v, want %v", i, !want, want)
+		}
+	}
+	if prompt := m.Calls()[2].Input[0].Content; !strings.Contains(prompt, "- file_reader:") {
+		t.Errorf("the added tool is missing from the prompt:\n%s", prompt)
+	}
+	if _, err := ts.Invoke(context.Background(), "dir_reader", `{"dirname":"."}`); !errors.Is(err, tools.ErrToolDisabled) {
+		t.Errorf("expected ErrToolDisabled, got %v", err)
+	}
+	if err := sm.Tools().Disable("bell"); !errors.Is(err, tools.ErrToolRequired) {
+		t.Errorf("expected ErrToolRequired, got %v", err)
+	}
+}
+
+func TestSpaceManEmitsEvents(t *testing.T) {
+	netizenModel := replay.NewScripted(
+		replay.Reply("晴", replay.ToolCall("bell", nil)),
+	)
+	spacemanModel := replay.NewScripted(
+		replay.Reply("查一下", replay.ToolCall("create_agent", map[string]string{
+			"name": "netizen",
+			"task": "查询天气",
+		})),
+		replay.Reply("晴", replay.ToolCall("bell", nil)),
+	)
+	ts := newTestToolSet(t)
+	err := ts.AddTool(compose.NewAgentCreator(NetizenFactory(
+		WithChatModel(netizenModel),
+		WithToolSet(newTestToolSet(t)),
+		WithOutput(io.Discard),
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	var spawnedBy, childParent string
+	observer := agent.ObserverFunc(func(ctx context.Context, ev agent.Event) {
+		src := ev.EventSource()
+		name := reflect.TypeOf(ev).Name()
+		events = append(events, fmt.Sprintf("%d:%s:%s", src.Depth, src.Agent, name))
+		switch ev.(type) {
+		case agent.SubAgentSpawned:
+			spawnedBy = src.RunID
+		case agent.TurnStarted:
+			if src.Depth == 1 {
+				childParent = src.Parent
+			}
+		}
+	})
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(spacemanModel), WithToolSet(ts), WithOutput(io.Discard), WithObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.HandleQuestion(context.Background(), "天气怎么样", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"0:spaceman:TurnStarted",
+		"0:spaceman:StepStarted",
+		"0:spaceman:TokenDelta",
+		"0:spaceman:StepFinished",
+		"0:spaceman:ToolCallRequested",
+		"0:spaceman:SubAgentSpawned",
+		"1:netizen:TurnStarted",
+		"1:netizen:StepStarted",
+		"1:netizen:TokenDelta",
+		"1:netizen:StepFinished",
+		"1:netizen:ToolCallRequested",
+		"1:netizen:ToolResult",
+		"1:netizen:Finished",
+		"0:spaceman:ToolResult",
+		"0:spaceman:StepStarted",
+		"0:spaceman:TokenDelta",
+		"0:spaceman:StepFinished",
+		"0:spaceman:ToolCallRequested",
+		"0:spaceman:ToolResult",
+		"0:spaceman:Finished",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+	if spawnedBy == "" || childParent != spawnedBy {
+		t.Errorf("child parent %q, want %q", childParent, spawnedBy)
+	}
+}
+
+func TestChildOptionsKeepApproverAndInstructions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	if _, err := instructions.Add(dir, "回答要简短"); err != nil {
+		t.Fatal(err)
+	}
+	var asked []string
+	o := &options{instructionsDir: dir, approver: func(ctx context.Context, call schema.ToolCall) bool {
+		asked = append(asked, call.Function.Name)
+		return call.Function.Name == "bell"
+	}}
+	m := replay.NewScripted(
+		replay.Reply("", replay.ToolCall("dir_reader", map[string]string{"dirname": "."})),
+		replay.Reply("被拒绝了", replay.ToolCall("bell", nil)),
+	)
+	ts := newTestToolSet(t)
+	if err := ts.AddTool(file.NewDirReader()); err != nil {
+		t.Fatal(err)
+	}
+	netizen, err := NetizenFactory(append(childOptions(o), WithChatModel(m), WithToolSet(ts))...)(context.Background(), "看看目录")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := netizen.HandleQuestion(context.Background(), "看看目录", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dir_reader", "bell"}; !reflect.DeepEqual(asked, want) {
+		t.Errorf("asked = %v, want %v", asked, want)
+	}
+	if msg := history[3]; msg.Role != schema.Tool || msg.Content != "用户拒绝了这次工具调用" {
+		t.Errorf("tool message = %+v", msg)
+	}
+	if prompt := m.Calls()[0].Input[0].Content; !strings.Contains(prompt, "回答要简短") {
+		t.Errorf("the netizen should follow the project instructions:\n%s", prompt)
+	}
+}
+
+func TestSpaceManTracesChildRuns(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	netizenModel := replay.NewScripted(
+		replay.Reply("晴", replay.ToolCall("bell", nil)),
+	)
+	spacemanModel := replay.NewScripted(
+		replay.Reply("查一下", replay.ToolCall("create_agent", map[string]string{
+			"name": "netizen",
+			"task": "查询天气",
+		})),
+		replay.Reply("", replay.ToolCall("nope", `{}`)),
+		replay.Reply("晴", replay.ToolCall("bell", nil)),
+	)
+	ts := newTestToolSet(t)
+	err := ts.AddTool(compose.NewAgentCreator(NetizenFactory(
+		WithChatModel(netizenModel),
+		WithToolSet(newTestToolSet(t)),
+		WithOutput(io.Discard),
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(spacemanModel), WithToolSet(ts), WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.HandleQuestion(context.Background(), "天气怎么样", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 以 span 名描述调用树, 子 agent 的 span 位于 create_agent 工具之下
+	spans := rec.Ended()
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+	var root sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		if !s.Parent().IsValid() {
+			root = s
+			continue
+		}
+		children[s.Parent().SpanID()] = append(children[s.Parent().SpanID()], s)
+	}
+	if root == nil {
+		t.Fatal("no root span")
+	}
+	var lines []string
+	var walk func(s sdktrace.ReadOnlySpan, depth int)
+	walk = func(s sdktrace.ReadOnlySpan, depth int) {
+		line := strings.Repeat("  ", depth) + s.Name()
+		if s.Status().Code == codes.Error {
+			line += " (error)"
+		}
+		lines = append(lines, line)
+		kids := children[s.SpanContext().SpanID()]
+		sort.Slice(kids, func(i, j int) bool { return kids[i].StartTime().Before(kids[j].StartTime()) })
+		for _, c := range kids {
+			walk(c, depth+1)
+		}
+	}
+	walk(root, 0)
+	want := []string{
+		"invoke_agent spaceman",
+		"  chat",
+		"  execute_tool create_agent",
+		"    invoke_agent netizen",
+		"      chat",
+		"      execute_tool bell",
+		"  chat",
+		"  execute_tool nope (error)",
+		"  chat",
+		"  execute_tool bell",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("spans:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, s := range spans {
+		if s.Name() != "execute_tool create_agent" {
+			continue
+		}
+		attrs := attribute.NewSet(s.Attributes()...)
+		if v, _ := attrs.Value("gen_ai.tool.name"); v.AsString() != "create_agent" {
+			t.Errorf("tool name = %v", v.AsString())
+		}
+		if v, _ := attrs.Value("cosmica.tool.arguments_size"); v.AsInt64() == 0 {
+			t.Error("arguments size not recorded")
+		}
+	}
+}
+
+func TestSpaceManSwitchesModel(t *testing.T) {
+	dir := t.TempDir()
+	fx := &replay.Fixture{Turns: []replay.Turn{replay.Reply("换了模型", replay.ToolCall("bell", nil))}}
+	fixture := filepath.Join(dir, "fixture.json")
+	if err := fx.Save(fixture); err != nil {
+		t.Fatal(err)
+	}
+	cfg := fmt.Sprintf("models:\n  recorded:\n    provider: replay\n    model_id: recorded\n    fixture: %s\n", fixture)
+	if err := os.WriteFile(filepath.Join(dir, "config.yml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	old := replay.NewScripted()
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(old), WithToolSet(newTestToolSet(t)), WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.(agent.ModelSwitcher).SwitchModel(context.Background(), "missing"); err == nil {
+		t.Error("switching to a model without a provider should fail")
+	}
+	if err := sm.(agent.ModelSwitcher).SwitchModel(context.Background(), "recorded"); err != nil {
+		t.Fatal(err)
+	}
+	history, err := sm.HandleQuestion(context.Background(), "你好", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history[2].Content; got != "换了模型" {
+		t.Errorf("reply = %q, want the switched model's", got)
+	}
+	if len(old.Calls()) != 0 {
+		t.Error("the previous model should not be called")
+	}
+}
+
+func TestSpaceManRefreshesInstructions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	if _, err := instructions.Add(dir, "回答要简短"); err != nil {
+		t.Fatal(err)
+	}
+	m := replay.NewScripted(
+		replay.Reply("好", replay.ToolCall("bell", nil)),
+		replay.Reply("好的", replay.ToolCall("bell", nil)),
+	)
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(m), WithToolSet(newTestToolSet(t)), WithOutput(io.Discard), WithInstructions(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := sm.HandleQuestion(context.Background(), "你好", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instructions.Add(dir, "用英文回答"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.HandleQuestion(context.Background(), "再说一次", history); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := m.Calls()
+	first, second := calls[0].Input[0].Content, calls[1].Input[0].Content
+	if !strings.Contains(first, "回答要简短") || strings.Contains(first, "用英文回答") {
+		t.Errorf("first system prompt:\n%s", first)
+	}
+	if !strings.Contains(second, "用英文回答") || strings.Count(second, "回答要简短") != 1 {
+		t.Errorf("system prompt was not refreshed:\n%s", second)
+	}
+	if history[0].Content != first {
+		t.Error("the caller's history should not be modified")
+	}
+}
+
+func TestSpaceManKeepsUnmarkedSystemMessages(t *testing.T) {
+	m := replay.NewScripted(
+		replay.Reply("好", replay.ToolCall("bell", nil)),
+		replay.Reply("好的", replay.ToolCall("bell", nil)),
+	)
+	sm, err := NewSpaceMan(context.Background(), WithChatModel(m), WithToolSet(newTestToolSet(t)), WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.HandleQuestion(context.Background(), "你好", nil); err != nil {
+		t.Fatal(err)
+	}
+	prompt := m.Calls()[0].Input[0].Content
+	// 以 agent 提示词开头但没有标记的系统消息来自客户端, 不能被当作 agent 的提示词替换
+	client := prompt + "\n\n只用英文回答"
+	if _, err := sm.HandleQuestion(context.Background(), "再说一次", []*schema.Message{schema.SystemMessage(client)}); err != nil {
+		t.Fatal(err)
+	}
+	input := m.Calls()[1].Input
+	if len(input) < 3 || input[0].Content != prompt || input[1].Content != client {
+		t.Errorf("input = %+v", input)
+	}
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/provider"
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/bootun/cosmica/agent"
+
+// tracer 返回全局 TracerProvider 的 Tracer, 未通过 telemetry.Setup 配置导出时不记录任何 span
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startTurnSpan 为一次 HandleQuestion 创建 span, 子 agent 的 span 位于父 agent 的 create_agent 工具 span 之下
+func startTurnSpan(ctx context.Context, src agent.Source) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		semconv.GenAIOperationNameKey.String("invoke_agent"),
+		semconv.GenAIAgentName(src.Agent),
+		semconv.GenAIAgentID(src.RunID),
+		attribute.Int("cosmica.agent.depth", src.Depth),
+	}
+	if src.Parent != "" {
+		attrs = append(attrs, attribute.String("cosmica.agent.parent_id", src.Parent))
+	}
+	return tracer().Start(ctx, "invoke_agent "+src.Agent, trace.WithAttributes(attrs...))
+}
+
+// startModelSpan 为一次模型流式输出创建 span
+func startModelSpan(ctx context.Context, iteration int) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "chat", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.GenAIOperationNameChat,
+		attribute.Int("cosmica.iteration", iteration),
+	))
+}
+
+// endModelSpan 记录实际应答的模型与 token 用量后结束 span
+func endModelSpan(span trace.Span, msg *schema.Message, err error) {
+	if msg != nil {
+		if model := provider.ModelOf(msg); model != "" {
+			span.SetName("chat " + model)
+			span.SetAttributes(semconv.GenAIResponseModel(model))
+		}
+		if msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
+			usage := msg.ResponseMeta.Usage
+			span.SetAttributes(
+				semconv.GenAIUsageInputTokens(usage.PromptTokens),
+				semconv.GenAIUsageOutputTokens(usage.CompletionTokens),
+			)
+		}
+		span.SetAttributes(attribute.Int("cosmica.tool_calls", len(msg.ToolCalls)))
+	}
+	endSpan(span, err)
+}
+
+// startToolSpan 为一次工具调用创建 span, 只记录参数长度, 参数本身可能包含敏感内容
+func startToolSpan(ctx context.Context, call schema.ToolCall) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "execute_tool "+call.Function.Name, trace.WithAttributes(
+		semconv.GenAIOperationNameExecuteTool,
+		semconv.GenAIToolName(call.Function.Name),
+		semconv.GenAIToolCallID(call.ID),
+		attribute.Int("cosmica.tool.arguments_size", len(call.Function.Arguments)),
+	))
+}
+
+// endSpan 在出错时记录错误并标记 span 失败, 然后结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
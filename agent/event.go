@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Source 标识产生事件的 agent
+// 每次 HandleQuestion 都有唯一的 RunID; 子 agent 的 Depth 比父 agent 大一, Parent 为父 agent 的 RunID
+type Source struct {
+	Agent  string
+	RunID  string
+	Parent string
+	Depth  int
+}
+
+func (s Source) EventSource() Source {
+	return s
+}
+
+// Event 是对话循环中发生的事件, 具体类型见下方的结构体
+type Event interface {
+	EventSource() Source
+}
+
+// TurnStarted 开始处理用户的问题
+type TurnStarted struct {
+	Source
+	Question string
+}
+
+// StepStarted 开始第 Iteration 次调用模型, 从 1 开始
+type StepStarted struct {
+	Source
+	Iteration int
+}
+
+// TokenDelta 模型输出的一个分片
+type TokenDelta struct {
+	Source
+	Content string
+}
+
+// StepFinished 模型完成一次输出, Model 为实际应答的模型, Usage 在提供方未返回时为 nil
+type StepFinished struct {
+	Source
+	Iteration int
+	Model     string
+	Usage     *schema.TokenUsage
+}
+
+// ToolCallRequested 模型请求调用工具
+type ToolCallRequested struct {
+	Source
+	Call schema.ToolCall
+}
+
+// ToolResult 工具调用结束, Err 不为 nil 时 Result 为空; 被拒绝的调用 Rejected 为 true
+type ToolResult struct {
+	Source
+	Call     schema.ToolCall
+	Result   string
+	Err      error
+	Rejected bool
+	Duration time.Duration
+}
+
+// SubAgentSpawned 工具创建了子 agent, 子 agent 的事件 Parent 为当前 agent 的 RunID
+type SubAgentSpawned struct {
+	Source
+	Name string
+	Task string
+}
+
+// Finished 问题处理完毕, Reply 为 agent 最后给出的回答
+type Finished struct {
+	Source
+	Reply      string
+	Iterations int
+}
+
+// Failed 处理问题时出现错误, 包括被取消
+type Failed struct {
+	Source
+	Err error
+}
+
+// Observer 接收 agent 的事件, 在对话循环所在的 goroutine 中同步调用, 不应长时间阻塞
+type Observer interface {
+	OnEvent(ctx context.Context, ev Event)
+}
+
+// ObserverFunc 把函数适配为 Observer
+type ObserverFunc func(ctx context.Context, ev Event)
+
+func (f ObserverFunc) OnEvent(ctx context.Context, ev Event) {
+	f(ctx, ev)
+}
+
+// Approver 在调用工具前请求批准, 返回 false 时不调用工具并告知模型调用被拒绝
+type Approver func(ctx context.Context, call schema.ToolCall) bool
+
+type emitterKey struct{}
+
+// emitter 保存在 context 中, 让工具与子 agent 把事件交给当前 agent 的观察者
+type emitter struct {
+	source Source
+	emit   func(ctx context.Context, ev Event)
+}
+
+// WithEmitter 返回携带当前 agent 事件出口的 context, 由对话循环在调用工具前设置
+func WithEmitter(ctx context.Context, source Source, emit func(ctx context.Context, ev Event)) context.Context {
+	return context.WithValue(ctx, emitterKey{}, &emitter{source: source, emit: emit})
+}
+
+// SourceFrom 返回 context 所属 agent 的 Source, 不在 agent 中时 ok 为 false
+func SourceFrom(ctx context.Context) (Source, bool) {
+	em, ok := ctx.Value(emitterKey{}).(*emitter)
+	if !ok {
+		return Source{}, false
+	}
+	return em.source, true
+}
+
+// Emit 把事件交给 context 所属 agent 的观察者, 不在 agent 中时忽略
+func Emit(ctx context.Context, ev Event) {
+	if em, ok := ctx.Value(emitterKey{}).(*emitter); ok {
+		em.emit(ctx, ev)
+	}
+}
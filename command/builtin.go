@@ -0,0 +1,266 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/instructions"
+	"github.com/cloudwego/eino/schema"
+)
+
+// historyPreview /history 中每条消息最多显示的字符数
+const historyPreview = 120
+
+// Default 返回注册了内置命令的命令表, 调用方可以继续注册自己的命令
+func Default() *Registry {
+	r := NewRegistry()
+	for _, c := range []Command{
+		{Name: "clear", Help: "清空对话历史", Run: clearHistory},
+		{Name: "history", Help: "显示对话历史", Run: showHistory},
+		{Name: "undo", Help: "撤销最近一轮对话", Run: undo},
+		{Name: "retry", Help: "撤销最近一轮对话并重新提问", Run: retry},
+		{Name: "model", Usage: "<id>", Help: "切换当前 agent 使用的模型", Run: switchModel},
+		{Name: "agent", Usage: "[name]", Help: "切换 agent, 对话历史保留", Run: switchAgent},
+		{Name: "save", Usage: "<file>", Help: "把对话历史保存为 JSON 文件", Run: save},
+		{Name: "load", Usage: "<file>", Help: "从 /save 保存的文件恢复对话历史", Run: load},
+		{Name: "memory", Usage: "[add <fact>]", Help: "列出生效的项目说明文件, 或向 ./COSMICA.md 追加一条事项", Run: memory},
+		{Name: "tools", Usage: "[enable|disable <name>...]", Help: "列出或启用、禁用工具", Run: tools},
+		{Name: "quit", Help: "退出", Run: quit},
+		{Name: "exit", Help: "同 /quit", Run: quit},
+		{Name: "help", Help: "列出所有命令", Run: func(ctx context.Context, s *Session, args []string) (Result, error) {
+			return Result{Output: help(r)}, nil
+		}},
+	} {
+		// 内置命令名不会重复
+		_ = r.Register(c)
+	}
+	return r
+}
+
+func help(r *Registry) string {
+	var b strings.Builder
+	for _, c := range r.Commands() {
+		name := "/" + c.Name
+		if c.Usage != "" {
+			name += " " + c.Usage
+		}
+		fmt.Fprintf(&b, "%-36s %s\n", name, c.Help)
+	}
+	return b.String()
+}
+
+func clearHistory(ctx context.Context, s *Session, args []string) (Result, error) {
+	s.History = nil
+	return Result{Output: "conversation cleared", Reset: true}, nil
+}
+
+func showHistory(ctx context.Context, s *Session, args []string) (Result, error) {
+	var b strings.Builder
+	for _, msg := range s.History {
+		if msg.Role == schema.System {
+			continue
+		}
+		if content := oneLine(msg.Content); content != "" {
+			fmt.Fprintf(&b, "[%s] %s\n", msg.Role, content)
+		}
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&b, "[%s] → %s %s\n", msg.Role, call.Function.Name, oneLine(call.Function.Arguments))
+		}
+	}
+	if b.Len() == 0 {
+		return Result{Output: "no history"}, nil
+	}
+	return Result{Output: b.String()}, nil
+}
+
+// lastTurn 返回最近一轮对话的用户消息在历史中的下标, 没有时返回 -1
+func lastTurn(history []*schema.Message) int {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == schema.User {
+			return i
+		}
+	}
+	return -1
+}
+
+func undo(ctx context.Context, s *Session, args []string) (Result, error) {
+	i := lastTurn(s.History)
+	if i < 0 {
+		return Result{}, errors.New("nothing to undo")
+	}
+	question := s.History[i].Content
+	s.History = s.History[:i]
+	return Result{Output: "removed: " + oneLine(question), Reset: true}, nil
+}
+
+func retry(ctx context.Context, s *Session, args []string) (Result, error) {
+	i := lastTurn(s.History)
+	if i < 0 {
+		return Result{}, errors.New("nothing to retry")
+	}
+	question := s.History[i].Content
+	s.History = s.History[:i]
+	return Result{Ask: question, Reset: true}, nil
+}
+
+func switchModel(ctx context.Context, s *Session, args []string) (Result, error) {
+	if len(args) != 1 {
+		return Result{}, usageError("model", "<id>")
+	}
+	ms, ok := s.Agent.(agent.ModelSwitcher)
+	if !ok {
+		return Result{}, errors.New("the current agent does not support switching models")
+	}
+	if err := ms.SwitchModel(ctx, args[0]); err != nil {
+		return Result{}, fmt.Errorf("switch model: %w", err)
+	}
+	return Result{Output: "model switched to " + args[0], Model: args[0]}, nil
+}
+
+func switchAgent(ctx context.Context, s *Session, args []string) (Result, error) {
+	if len(args) == 0 {
+		return Result{Output: "current agent: " + s.AgentName}, nil
+	}
+	if len(args) != 1 {
+		return Result{}, usageError("agent", "[name]")
+	}
+	if err := s.switchAgent(ctx, args[0]); err != nil {
+		return Result{}, err
+	}
+	return Result{Output: "agent switched to " + args[0]}, nil
+}
+
+// switchAgent 改用名为 name 的 agent, 去掉原 agent 的系统提示词, 由新 agent 在下次提问时加入自己的
+func (s *Session) switchAgent(ctx context.Context, name string) error {
+	if s.NewAgent == nil {
+		return errors.New("switching agents is not supported")
+	}
+	a, err := s.NewAgent(ctx, name)
+	if err != nil {
+		return fmt.Errorf("create agent %s: %w", name, err)
+	}
+	s.Close()
+	s.Agent, s.AgentName = a, name
+	if len(s.History) > 0 && s.History[0].Role == schema.System {
+		s.History = s.History[1:]
+	}
+	return nil
+}
+
+// transcript 是 /save 保存的文件格式
+type transcript struct {
+	Agent    string            `json:"agent"`
+	Messages []*schema.Message `json:"messages"`
+}
+
+func save(ctx context.Context, s *Session, args []string) (Result, error) {
+	if len(args) != 1 {
+		return Result{}, usageError("save", "<file>")
+	}
+	data, err := json.MarshalIndent(transcript{Agent: s.AgentName, Messages: s.History}, "", "  ")
+	if err != nil {
+		return Result{}, fmt.Errorf("marshal history: %w", err)
+	}
+	if err := os.WriteFile(args[0], data, 0o644); err != nil {
+		return Result{}, fmt.Errorf("save history: %w", err)
+	}
+	return Result{Output: fmt.Sprintf("saved %d messages to %s", len(s.History), args[0])}, nil
+}
+
+// load 恢复对话历史, 文件中的 agent 与当前不同时一并切换
+// 保存时的系统提示词可能已经过时, 恢复时去掉, 由 agent 在下次提问时加入当前的提示词
+func load(ctx context.Context, s *Session, args []string) (Result, error) {
+	if len(args) != 1 {
+		return Result{}, usageError("load", "<file>")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return Result{}, fmt.Errorf("load history: %w", err)
+	}
+	var t transcript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Result{}, fmt.Errorf("parse %s: %w", args[0], err)
+	}
+	if t.Agent != "" && t.Agent != s.AgentName {
+		if err := s.switchAgent(ctx, t.Agent); err != nil {
+			return Result{}, err
+		}
+	}
+	s.History = t.Messages
+	if len(s.History) > 0 && s.History[0].Role == schema.System {
+		s.History = s.History[1:]
+	}
+	return Result{Output: fmt.Sprintf("loaded %d messages from %s", len(t.Messages), args[0]), Reset: true}, nil
+}
+
+func tools(ctx context.Context, s *Session, args []string) (Result, error) {
+	tm, ok := s.Agent.(agent.ToolManager)
+	if !ok {
+		return Result{}, errors.New("the current agent does not support changing tools")
+	}
+	ts := tm.Tools()
+	if len(args) == 0 {
+		return Result{Output: ts.Describe()}, nil
+	}
+	if len(args) < 2 {
+		return Result{}, usageError("tools", "[enable|disable <name>...]")
+	}
+	var op func(string) error
+	switch args[0] {
+	case "enable":
+		op = ts.Enable
+	case "disable":
+		op = ts.Disable
+	default:
+		return Result{}, usageError("tools", "[enable|disable <name>...]")
+	}
+	var b strings.Builder
+	for _, name := range args[1:] {
+		if err := op(name); err != nil {
+			fmt.Fprintf(&b, "%s %s: %v\n", args[0], name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %sd\n", name, args[0])
+	}
+	return Result{Output: b.String()}, nil
+}
+
+// memory 列出生效的说明文件或追加事项, 追加的事项从下一个问题起生效
+func memory(ctx context.Context, s *Session, args []string) (Result, error) {
+	if len(args) == 0 {
+		files, err := instructions.Discover(".")
+		if err != nil {
+			return Result{}, err
+		}
+		if len(files) == 0 {
+			return Result{Output: "no " + instructions.FileName + " found, add one with /memory add <fact>"}, nil
+		}
+		return Result{Output: strings.Join(files, "\n")}, nil
+	}
+	if args[0] != "add" || len(args) < 2 {
+		return Result{}, usageError("memory", "[add <fact>]")
+	}
+	path, err := instructions.Add(".", strings.Join(args[1:], " "))
+	if err != nil {
+		return Result{}, fmt.Errorf("add memory: %w", err)
+	}
+	return Result{Output: "added to " + path}, nil
+}
+
+func quit(ctx context.Context, s *Session, args []string) (Result, error) {
+	return Result{Quit: true}, nil
+}
+
+// oneLine 把文本压缩为一行并截断到 historyPreview 个字符
+func oneLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > historyPreview {
+		return string(r[:historyPreview]) + "…"
+	}
+	return s
+}
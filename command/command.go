@@ -0,0 +1,116 @@
+// Package command 实现终端对话中以 / 开头的命令, 终端界面与逐行输入共用同一组命令
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrUnknownCommand 输入的命令没有注册
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Session 是命令读写的会话状态, 由终端界面持有
+type Session struct {
+	Agent     agent.Agent
+	AgentName string
+	History   []*schema.Message
+	// NewAgent 按名称创建 agent, 为 nil 时不能切换 agent
+	NewAgent func(ctx context.Context, name string) (agent.Agent, error)
+}
+
+// Close 释放当前 agent 持有的外部资源
+func (s *Session) Close() error {
+	if c, ok := s.Agent.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Result 是命令的执行结果
+type Result struct {
+	// Output 显示给用户的输出
+	Output string
+	// Ask 不为空时界面把它作为问题交给 agent, 例如 /retry
+	Ask string
+	// Reset 为 true 表示历史被替换, 界面应按新的历史重新显示对话
+	Reset bool
+	// Model 不为空表示当前 agent 改用了该模型, 界面据此更新状态栏
+	Model string
+	// Quit 为 true 时退出对话
+	Quit bool
+}
+
+// Handler 执行命令, args 为命令名之后以空白分隔的参数
+type Handler func(ctx context.Context, s *Session, args []string) (Result, error)
+
+// Command 是一个可注册的命令
+type Command struct {
+	// Name 命令名, 不含开头的 /
+	Name string
+	// Usage 参数说明, 例如 "<file>"
+	Usage string
+	Help  string
+	Run   Handler
+}
+
+// Registry 按名称保存命令
+type Registry struct {
+	commands map[string]Command
+}
+
+// NewRegistry 创建空的命令表
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]Command)}
+}
+
+// Register 注册命令, 名称重复时返回错误
+func (r *Registry) Register(c Command) error {
+	if c.Name == "" || c.Run == nil {
+		return errors.New("command must have a name and a handler")
+	}
+	if _, ok := r.commands[c.Name]; ok {
+		return fmt.Errorf("command /%s already registered", c.Name)
+	}
+	r.commands[c.Name] = c
+	return nil
+}
+
+// Commands 按名称顺序返回所有命令
+func (r *Registry) Commands() []Command {
+	cmds := make([]Command, 0, len(r.commands))
+	for _, c := range r.commands {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// IsCommand 报告输入是否为命令
+func IsCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "/")
+}
+
+// Run 解析并执行一行命令
+func (r *Registry) Run(ctx context.Context, s *Session, line string) (Result, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return Result{}, fmt.Errorf("%w %q", ErrUnknownCommand, line)
+	}
+	c, ok := r.commands[strings.TrimPrefix(fields[0], "/")]
+	if !ok {
+		return Result{}, fmt.Errorf("%w %s, type /help to list commands", ErrUnknownCommand, fields[0])
+	}
+	return c.Run(ctx, s, fields[1:])
+}
+
+// usageError 返回命令的用法
+func usageError(c string, usage string) error {
+	return fmt.Errorf("usage: /%s %s", c, usage)
+}
@@ -0,0 +1,165 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeAgent 记录切换的模型与是否被关闭
+type fakeAgent struct {
+	name   string
+	model  string
+	closed bool
+}
+
+func (a *fakeAgent) HandleQuestion(ctx context.Context, question string, history []*schema.Message) ([]*schema.Message, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *fakeAgent) SwitchModel(ctx context.Context, model string) error {
+	a.model = model
+	return nil
+}
+
+func (a *fakeAgent) Close() error {
+	a.closed = true
+	return nil
+}
+
+func newTestSession() *Session {
+	return &Session{
+		Agent:     &fakeAgent{name: agent.AgentSpaceman},
+		AgentName: agent.AgentSpaceman,
+		History: []*schema.Message{
+			schema.SystemMessage("你是spaceman"),
+			schema.UserMessage("第一个问题"),
+			schema.AssistantMessage("第一个回答", nil),
+			schema.UserMessage("第二个问题"),
+			schema.AssistantMessage("", []schema.ToolCall{{ID: "call_0", Function: schema.FunctionCall{Name: "bell", Arguments: "{}"}}}),
+			schema.ToolMessage("ok", "call_0"),
+		},
+		NewAgent: func(ctx context.Context, name string) (agent.Agent, error) {
+			return &fakeAgent{name: name}, nil
+		},
+	}
+}
+
+func run(t *testing.T, r *Registry, s *Session, line string) Result {
+	t.Helper()
+	res, err := r.Run(context.Background(), s, line)
+	if err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+	return res
+}
+
+func TestRegistry(t *testing.T) {
+	r := Default()
+	if err := r.Register(Command{Name: "help", Run: quit}); err == nil {
+		t.Error("registering a duplicate command should fail")
+	}
+	if err := r.Register(Command{Name: "ping", Help: "回应 pong", Run: func(ctx context.Context, s *Session, args []string) (Result, error) {
+		return Result{Output: "pong " + strings.Join(args, " ")}, nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSession()
+	if res := run(t, r, s, "/ping a  b"); res.Output != "pong a b" {
+		t.Errorf("/ping = %q", res.Output)
+	}
+	if _, err := r.Run(context.Background(), s, "/nope"); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("/nope error = %v", err)
+	}
+	help := run(t, r, s, "/help").Output
+	for _, want := range []string{"/ping", "回应 pong", "/save <file>", "/undo"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("/help does not mention %q:\n%s", want, help)
+		}
+	}
+}
+
+func TestUndoAndRetry(t *testing.T) {
+	r, s := Default(), newTestSession()
+	res := run(t, r, s, "/retry")
+	if res.Ask != "第二个问题" || !res.Reset || len(s.History) != 3 {
+		t.Errorf("/retry = %+v with %d messages left", res, len(s.History))
+	}
+	run(t, r, s, "/undo")
+	if len(s.History) != 1 || s.History[0].Role != schema.System {
+		t.Errorf("/undo left %d messages", len(s.History))
+	}
+	if _, err := r.Run(context.Background(), s, "/undo"); err == nil {
+		t.Error("/undo without any turn should fail")
+	}
+	if res := run(t, r, s, "/history"); res.Output != "no history" {
+		t.Errorf("/history = %q", res.Output)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	out := run(t, Default(), newTestSession(), "/history").Output
+	want := "[user] 第一个问题\n[assistant] 第一个回答\n[user] 第二个问题\n[assistant] → bell {}\n[tool] ok\n"
+	if out != want {
+		t.Errorf("/history = %q, want %q", out, want)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	r, s := Default(), newTestSession()
+	file := filepath.Join(t.TempDir(), "chat.json")
+	run(t, r, s, "/save "+file)
+	run(t, r, s, "/clear")
+	if len(s.History) != 0 {
+		t.Fatalf("/clear left %d messages", len(s.History))
+	}
+	res := run(t, r, s, "/load "+file)
+	// 保存的系统提示词被去掉, 下次提问时由 agent 加入当前的提示词
+	if !res.Reset || len(s.History) != 5 || s.History[0].Role != schema.User || s.History[3].ToolCalls[0].Function.Name != "bell" {
+		t.Errorf("/load = %+v, history %v", res, s.History)
+	}
+	if _, err := r.Run(context.Background(), s, "/load"); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("/load without a file error = %v", err)
+	}
+}
+
+func TestSwitchModelAndAgent(t *testing.T) {
+	r, s := Default(), newTestSession()
+	old := s.Agent.(*fakeAgent)
+	if res := run(t, r, s, "/model gpt-4o"); old.model != "gpt-4o" || res.Model != "gpt-4o" {
+		t.Errorf("model = %q, result %+v", old.model, res)
+	}
+
+	run(t, r, s, "/agent netizen")
+	if !old.closed || s.AgentName != agent.AgentNetizen || s.Agent.(*fakeAgent).name != agent.AgentNetizen {
+		t.Errorf("agent not switched: %+v", s)
+	}
+	// 新 agent 提问时加入自己的系统提示词, 其余历史保留
+	if len(s.History) != 5 || s.History[0].Role != schema.User {
+		t.Errorf("history after switching agent: %v", s.History)
+	}
+	if res := run(t, r, s, "/agent"); res.Output != "current agent: netizen" {
+		t.Errorf("/agent = %q", res.Output)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	r, s := Default(), newTestSession()
+	if res := run(t, r, s, "/memory"); !strings.HasPrefix(res.Output, "no COSMICA.md found") {
+		t.Errorf("/memory = %q", res.Output)
+	}
+	run(t, r, s, "/memory add 测试用 go test ./...")
+	if res := run(t, r, s, "/memory"); !strings.HasSuffix(res.Output, "COSMICA.md") {
+		t.Errorf("/memory after add = %q", res.Output)
+	}
+	if _, err := r.Run(context.Background(), s, "/memory add"); err == nil {
+		t.Error("/memory add without a fact should fail")
+	}
+}
@@ -10,16 +10,196 @@ import (
 
 type Config struct {
 	Agents Agents `yaml:"agents"`
+	// Models 具名的模型定义, 供 fallbacks 与 routing 引用
+	Models map[string]Model `yaml:"models"`
+	// Routing 为每个 agent 指定按顺序回退的模型链, 优先于 agents 中的配置
+	// 例如规划交给强模型, 浏览等简单子任务交给小模型
+	Routing map[string][]string `yaml:"routing"`
+	Tools   Tools               `yaml:"tools"`
+	// MCPServers 外部 MCP 服务, 其工具、资源与提示词会加入 spaceman 的工具集
+	MCPServers map[string]MCPServer `yaml:"mcp_servers"`
+	Telemetry  Telemetry            `yaml:"telemetry"`
+	Logging    Logging              `yaml:"logging"`
+	UI         UI                   `yaml:"ui"`
+	Prompts    Prompts              `yaml:"prompts"`
+	// Embedding 向量模型, 用于长期记忆的语义检索, 未配置 model_id 时退回到 BM25
+	Embedding  Model      `yaml:"embedding"`
+	Memory     Memory     `yaml:"memory"`
+	CodeSearch CodeSearch `yaml:"code_search"`
+}
+
+// CodeSearch 代码检索工具 code_search 的配置
+type CodeSearch struct {
+	// Disabled 不为 spaceman 提供 code_search 工具
+	Disabled bool `yaml:"disabled"`
+	// Embeddings 使用 embedding 中配置的向量模型, 否则只使用 BM25; 首次检索大仓库时会为所有代码块计算向量
+	Embeddings bool `yaml:"embeddings"`
+	// IndexFile 保存索引的文件, 默认位于用户缓存目录下
+	IndexFile string `yaml:"index_file"`
+}
+
+// Memory 长期记忆的配置
+type Memory struct {
+	// Disabled 不为 spaceman 提供 memory_save、memory_search 与 memory_delete 工具
+	Disabled bool `yaml:"disabled"`
+	// File 保存记忆的文件, 默认为 ~/.cosmica/memory.json
+	File string `yaml:"file"`
+}
+
+// Prompts 提示词模板的配置
+type Prompts struct {
+	// Dir 覆盖内置模板的目录, 其中的 <locale>/<name>.tmpl 优先于内置模板
+	Dir string `yaml:"dir"`
+	// Locale zh 或 en, 为空时根据 LANG 等环境变量判断
+	Locale string `yaml:"locale"`
+}
+
+// UI 终端输出的配置
+type UI struct {
+	// Theme dark(默认), light 或 plain, 设置了 NO_COLOR 环境变量时总是 plain
+	Theme string `yaml:"theme"`
+	// Mode tui(默认) 或 repl, 输入输出不是终端时总是 repl
+	Mode string `yaml:"mode"`
+	// HistoryFile repl 保存输入历史的文件, 默认为 ~/.cosmica_history
+	HistoryFile string `yaml:"history_file"`
+}
+
+// Logging 日志配置, 未指定文件时输出到标准错误
+type Logging struct {
+	// Level debug, info(默认), warn 或 error
+	Level string `yaml:"level"`
+	// Format text(默认) 或 json
+	Format string `yaml:"format"`
+	// File 追加写入的日志文件
+	File string `yaml:"file"`
+}
+
+type Tools struct {
+	// AutoRepair 工具参数不是合法 JSON 时先尝试修复(代码块包裹、多余逗号、缺失括号等)
+	AutoRepair bool `yaml:"auto_repair"`
+	// DisableGo 工作目录是 Go 模块时也不提供 go_symbols、go_definition 等代码分析工具
+	DisableGo bool `yaml:"disable_go"`
+}
+
+// MCPServer 一个 MCP 服务, 设置 Command 时通过 stdio 启动子进程, 否则连接 URL(streamable HTTP)
+type MCPServer struct {
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Env     map[string]string `yaml:"env"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Disabled 为 true 时不连接该服务
+	Disabled bool `yaml:"disabled"`
+}
+
+// Telemetry 链路追踪的导出方式, Exporter 为空时不导出
+type Telemetry struct {
+	// Exporter otlp: 通过 OTLP HTTP 发送; file: 以 JSON Lines 写入本地文件
+	Exporter string `yaml:"exporter"`
+	// Endpoint OTLP 接收端地址, 例如 localhost:4318, 为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 或默认值
+	Endpoint string            `yaml:"endpoint"`
+	Insecure bool              `yaml:"insecure"`
+	Headers  map[string]string `yaml:"headers"`
+	// File 仅 file 使用, 默认为 traces.jsonl
+	File string `yaml:"file"`
+	// ServiceName 上报的服务名, 默认为 cosmica
+	ServiceName string `yaml:"service_name"`
 }
 
 type Agents struct {
 	Spaceman Agent `yaml:"spaceman"`
+	// Netizen 未配置时沿用 Spaceman 的模型配置
+	Netizen Agent `yaml:"netizen"`
+	// Others 其他 agent 的配置, 以 agent 名称为键
+	Others map[string]Agent `yaml:",inline"`
 }
 
 type Agent struct {
-	ModelID string `yaml:"model_id"`
-	BaseURL string `yaml:"base_url"`
-	Token   string `yaml:"token"`
+	Model `yaml:",inline"`
+	// Fallbacks 主模型调用失败时依次尝试的模型, 引用 models 中的名称
+	Fallbacks []string `yaml:"fallbacks"`
+}
+
+type Model struct {
+	// Name 模型在 models 中的名称, 由 ModelChain 填入, 用于在日志与事件中区分同一模型的不同配置
+	Name string `yaml:"-"`
+	// Provider 模型提供方: openai(默认), azure, anthropic, ollama, replay
+	Provider string `yaml:"provider"`
+	ModelID  string `yaml:"model_id"`
+	BaseURL  string `yaml:"base_url"`
+	Token    string `yaml:"token"`
+	// APIVersion 仅 azure 使用
+	APIVersion string `yaml:"api_version"`
+	// Capabilities 覆盖提供方默认声明的模型能力
+	Capabilities Capabilities `yaml:"capabilities"`
+	// Fixture 仅 replay 使用, 回放的脚本文件
+	Fixture string `yaml:"fixture"`
+	// Record 不为空时把与该模型的会话录制到此文件, 供 replay 回放
+	// 沿用同一配置的其他模型(例如子 agent)依次录制到 name-2.json、name-3.json 等文件
+	Record string `yaml:"record"`
+}
+
+// Capabilities 为空的字段表示使用提供方的默认值
+type Capabilities struct {
+	ToolCalling *bool `yaml:"tool_calling"`
+	Vision      *bool `yaml:"vision"`
+	MaxContext  int   `yaml:"max_context"`
+}
+
+// Agent 返回指定 agent 的配置, 未配置的 agent 沿用 spaceman 的配置
+func (c *Config) Agent(name string) Agent {
+	var a Agent
+	switch name {
+	case "spaceman":
+		return c.Agents.Spaceman
+	case "netizen":
+		a = c.Agents.Netizen
+	default:
+		a = c.Agents.Others[name]
+	}
+	if a.ModelID != "" || len(a.Fallbacks) > 0 {
+		return a
+	}
+	return c.Agents.Spaceman
+}
+
+// ModelChain 返回指定 agent 按顺序回退的模型链
+func (c *Config) ModelChain(name string) ([]Model, error) {
+	var chain []Model
+	if names, ok := c.Routing[name]; ok {
+		for _, n := range names {
+			m, err := c.model(n)
+			if err != nil {
+				return nil, fmt.Errorf("routing %s: %w", name, err)
+			}
+			chain = append(chain, m)
+		}
+	} else {
+		agent := c.Agent(name)
+		if agent.ModelID != "" {
+			chain = append(chain, agent.Model)
+		}
+		for _, n := range agent.Fallbacks {
+			m, err := c.model(n)
+			if err != nil {
+				return nil, fmt.Errorf("agent %s fallbacks: %w", name, err)
+			}
+			chain = append(chain, m)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no model configured for agent %s", name)
+	}
+	return chain, nil
+}
+
+func (c *Config) model(name string) (Model, error) {
+	m, ok := c.Models[name]
+	if !ok {
+		return Model{}, fmt.Errorf("model %q not defined in models", name)
+	}
+	m.Name = name
+	return m, nil
 }
 
 func LoadConfig(filePath string) (*Config, error) {
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/agent/common"
+	"github.com/bootun/cosmica/serve"
+)
+
+// runServe 处理 cosmica serve [-addr addr] [-token token] [-approve tools]
+// 默认只监听回环地址, 每个请求都需要携带访问令牌
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address")
+	token := fs.String("token", os.Getenv(serve.TokenEnv), "bearer token required by every request, generated for loopback addresses when empty (env "+serve.TokenEnv+")")
+	approve := fs.String("approve", "shell_executor", "comma separated tools that need the client's approval, * for all, empty for none")
+	fs.Parse(args)
+
+	tok, err := serve.ResolveToken(*addr, *token)
+	if err != nil {
+		return err
+	}
+	if *token == "" {
+		fmt.Fprintf(os.Stderr, "access token: %s\n", tok)
+	}
+	var opts []serve.HTTPOption
+	if *approve != "" {
+		opts = append(opts, serve.WithApproval(strings.Split(*approve, ",")...))
+	}
+	s := serve.NewHTTPServer(newSessionAgent, opts...)
+	defer s.Close()
+
+	hs := &http.Server{Addr: *addr, Handler: serve.RequireToken(tok, s)}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		hs.Shutdown(context.Background())
+	}()
+	slog.Info("http server listening", "addr", *addr)
+	if err := hs.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newSessionAgent 为 HTTP 会话创建 agent, 输出只通过事件推送给客户端
+func newSessionAgent(ctx context.Context, name string, observer agent.Observer, approver agent.Approver) (agent.Agent, error) {
+	return newAgent(ctx, name, common.WithOutput(io.Discard), common.WithObserver(observer), common.WithApprover(approver))
+}
@@ -0,0 +1,128 @@
+// Package instructions 发现并读取项目说明文件 COSMICA.md, 合并到 agent 的系统提示词中,
+// 每个仓库都可以借此告诉 agent 自己的约定
+package instructions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName 项目说明文件名
+const FileName = "COSMICA.md"
+
+// maxFileSize 单个说明文件最多读取的字节数, 避免误放的大文件撑满上下文
+const maxFileSize = 64 << 10
+
+// UserFile 返回用户级的说明文件 ~/.cosmica/COSMICA.md, 对所有项目生效
+func UserFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cosmica", FileName)
+}
+
+// Discover 返回存在的说明文件, 按合并顺序排列: 先是用户级文件, 再从最外层的父目录到 dir
+func Discover(dir string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", dir, err)
+	}
+	var project []string
+	for {
+		project = append(project, filepath.Join(dir, FileName))
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if path == "" || seen[path] {
+			return
+		}
+		seen[path] = true
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			files = append(files, path)
+		}
+	}
+	add(UserFile())
+	for i := len(project) - 1; i >= 0; i-- {
+		add(project[i])
+	}
+	return files, nil
+}
+
+// Load 读取 dir 适用的所有说明文件并合并, 没有说明文件时返回空字符串
+func Load(dir string) (string, error) {
+	files, err := Discover(dir)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", path, err)
+		}
+		content := strings.TrimSpace(string(data))
+		if content == "" {
+			continue
+		}
+		if len(content) > maxFileSize {
+			content = strings.ToValidUTF8(content[:maxFileSize], "") + "\n...(truncated)"
+		}
+		fmt.Fprintf(&b, "\n\n<instructions path=%q>\n%s\n</instructions>", path, content)
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return "以下是用户与项目提供的说明, 越靠后的越具体, 冲突时以靠后的为准:" + b.String(), nil
+}
+
+// SystemPrompt 在 base 之后附上 dir 适用的说明, 读取失败时只返回 base 与错误
+func SystemPrompt(base, dir string) (string, error) {
+	extra, err := Load(dir)
+	if err != nil || extra == "" {
+		return base, err
+	}
+	return base + "\n\n" + extra, nil
+}
+
+// Add 把一条事项追加到 dir 下的 COSMICA.md, 文件不存在时创建, 返回文件路径
+func Add(dir, fact string) (string, error) {
+	fact = strings.Join(strings.Fields(fact), " ")
+	if fact == "" {
+		return "", errors.New("empty fact")
+	}
+	path, err := filepath.Abs(filepath.Join(dir, FileName))
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", dir, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	var b strings.Builder
+	switch {
+	case len(data) == 0:
+		b.WriteString("# 项目说明\n\n")
+	case data[len(data)-1] != '\n':
+		b.WriteString("\n")
+	}
+	b.WriteString("- " + fact + "\n")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(b.String()); err != nil {
+		return "", fmt.Errorf("write %s: %w", path, err)
+	}
+	return path, nil
+}
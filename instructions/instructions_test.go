@@ -0,0 +1,75 @@
+package instructions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadMergesFromOuterToInner(t *testing.T) {
+	home, root := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	write(t, filepath.Join(home, ".cosmica", FileName), "用中文回答")
+	write(t, filepath.Join(root, FileName), "仓库使用 Go")
+	write(t, filepath.Join(root, "svc", "api", FileName), "接口返回 JSON")
+	// 空文件不参与合并
+	write(t, filepath.Join(root, "svc", FileName), "\n")
+
+	files, err := Discover(filepath.Join(root, "svc", "api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 || files[0] != filepath.Join(home, ".cosmica", FileName) || files[3] != filepath.Join(root, "svc", "api", FileName) {
+		t.Errorf("Discover = %q", files)
+	}
+
+	prompt, err := SystemPrompt("你是spaceman", filepath.Join(root, "svc", "api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, repo, api := strings.Index(prompt, "用中文回答"), strings.Index(prompt, "仓库使用 Go"), strings.Index(prompt, "接口返回 JSON")
+	if !strings.HasPrefix(prompt, "你是spaceman\n\n") || user < 0 || !(user < repo && repo < api) {
+		t.Errorf("instructions merged in the wrong order:\n%s", prompt)
+	}
+}
+
+func TestSystemPromptWithoutInstructions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	prompt, err := SystemPrompt("你是spaceman", t.TempDir())
+	if err != nil || prompt != "你是spaceman" {
+		t.Errorf("SystemPrompt = %q, %v", prompt, err)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	dir := t.TempDir()
+	path, err := Add(dir, "  测试用 go test ./... ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, path, "# 项目说明\n\n- 测试用 go test ./...") // 模拟手工编辑后没有结尾换行
+	if _, err := Add(dir, "提交信息用英文"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# 项目说明\n\n- 测试用 go test ./...\n- 提交信息用英文\n"; string(data) != want {
+		t.Errorf("COSMICA.md = %q, want %q", data, want)
+	}
+	if _, err := Add(dir, " "); err == nil {
+		t.Error("adding an empty fact should fail")
+	}
+}
@@ -1,36 +1,233 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/bootun/cosmica/agent"
 	"github.com/bootun/cosmica/agent/common"
+	"github.com/bootun/cosmica/command"
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/mention"
+	"github.com/bootun/cosmica/prompts"
+	"github.com/bootun/cosmica/repl"
+	"github.com/bootun/cosmica/telemetry"
+	"github.com/bootun/cosmica/tui"
+	"github.com/bootun/cosmica/utils/logging"
+	"github.com/bootun/cosmica/utils/text"
 	"github.com/cloudwego/eino/schema"
+	"github.com/mattn/go-isatty"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run 执行命令并返回退出码, 所有退出都经过这里, 使延迟的日志文件关闭与链路导出得以执行
+func run() int {
 	ctx := context.Background()
-	spaceman, err := common.NewSpaceMan(ctx)
+	// 没有配置文件时使用默认的日志配置, 由各子命令自行报告缺少的配置
+	cfg, err := config.LoadConfig("config.yml")
+	if err != nil {
+		cfg = &config.Config{}
+	}
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	logFile, err := logging.Setup(cfg.Logging, command != "mcp" && command != "serve")
+	if err != nil {
+		return fail("setup logging", err)
+	}
+	defer logFile.Close()
+	defer setupTelemetry(ctx, cfg.Telemetry)()
+	prompts.Configure(cfg.Prompts.Dir, cfg.Prompts.Locale)
+
+	switch command {
+	case "mcp":
+		if err := runMCP(ctx, os.Args[2:]); err != nil {
+			return fail("mcp", err)
+		}
+		return 0
+	case "serve":
+		if err := runServe(ctx, os.Args[2:]); err != nil {
+			return fail("serve", err)
+		}
+		return 0
+	}
+	// 主题名拼错时直接报错, 而不是悄悄使用默认主题
+	if _, ok := text.LookupTheme(cfg.UI.Theme); !ok {
+		return fail("chat", fmt.Errorf("unknown ui.theme %q, available: %s", cfg.UI.Theme, strings.Join(text.ThemeNames(), ", ")))
+	}
+	// 终端对话只有一个会话, 以启动时间区分不同进程的日志
+	ctx = logging.WithAttrs(ctx, slog.String("session", "repl-"+time.Now().Format("20060102150405")))
+	// 输入输出都是终端时默认使用全屏界面, 否则(例如管道)逐行读取问题
+	if isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd()) && cfg.UI.Mode != "repl" {
+		err = runTUI(ctx, cfg)
+	} else {
+		err = runREPL(ctx, cfg)
+	}
+	if err != nil {
+		return fail("chat", err)
+	}
+	return 0
+}
+
+// runTUI 在全屏界面中与 spaceman 对话, 未指定日志文件时界面运行期间丢弃日志, 避免破坏画面
+func runTUI(ctx context.Context, cfg *config.Config) error {
+	events := tui.NewEvents()
+	session, err := newSession(ctx, common.WithOutput(io.Discard), common.WithObserver(events))
+	if err != nil {
+		return err
+	}
+	// 关闭 MCP 服务等外部资源
+	defer session.Close()
+	if cfg.Logging.File == "" {
+		logger := slog.Default()
+		slog.SetDefault(slog.New(slog.DiscardHandler))
+		defer slog.SetDefault(logger)
+	}
+	mentions := mention.NewExpander()
+	ui := tui.New(ctx, session, events, tui.WithTheme(text.ThemeFor(cfg.UI.Theme)), tui.WithMentions(mentions))
+	// 问题开头的 !命令 在界面中确认后才执行
+	mentions.Approve = ui.Approve
+	return tui.Run(ui)
+}
+
+// runREPL 逐行读取问题, 输入结束时退出
+func runREPL(ctx context.Context, cfg *config.Config) error {
+	session, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	commands := command.Default()
+	// 全屏界面的输入框本身可以编辑多行, 只有逐行输入需要借助编辑器
+	err = commands.Register(command.Command{Name: "edit", Help: "用 $EDITOR 编写问题", Run: func(ctx context.Context, s *command.Session, args []string) (command.Result, error) {
+		question, err := repl.Edit("")
+		if err != nil {
+			return command.Result{}, err
+		}
+		return command.Result{Ask: question}, nil
+	}})
 	if err != nil {
-		log.Fatalf("create spaceman: %v", err)
+		return fmt.Errorf("register /edit: %w", err)
+	}
+	historyFile := cfg.UI.HistoryFile
+	if historyFile == "" {
+		historyFile = repl.DefaultHistoryFile()
+	}
+	history, err := repl.LoadHistory(historyFile)
+	if err != nil {
+		slog.Warn("load input history failed", "error", err)
+		history, _ = repl.LoadHistory("")
+	}
+	reader := repl.NewReader(os.Stdin, os.Stdout, "> ", history)
+	reader.SetCompleter(mention.Complete)
+	mentions := mention.NewExpander()
+	// 问题开头的 !命令 确认后才执行
+	mentions.Approve = func(ctx context.Context, call schema.ToolCall) bool {
+		var args struct {
+			Command string `json:"command"`
+		}
+		json.Unmarshal([]byte(call.Function.Arguments), &args)
+		ok, err := reader.Confirm(fmt.Sprintf("run %q?", args.Command))
+		if err != nil {
+			slog.Warn("read confirmation failed", "error", err)
+		}
+		return ok
 	}
-	var history []*schema.Message
 	for {
-		question := readUserQuestion()
-		newHis, err := spaceman.HandleQuestion(ctx, question, history)
+		question, err := reader.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		// ctrl+c 只丢弃正在输入的消息
+		if errors.Is(err, repl.ErrInterrupted) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read input: %w", err)
+		}
+		if strings.TrimSpace(question) == "" {
+			continue
+		}
+		if command.IsCommand(question) {
+			res, err := commands.Run(ctx, session, question)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if res.Quit {
+				return nil
+			}
+			if res.Output != "" {
+				fmt.Println(strings.TrimRight(res.Output, "\n"))
+			}
+			if res.Ask == "" {
+				continue
+			}
+			question = res.Ask
+		}
+		newHis, err := session.Agent.HandleQuestion(ctx, mentions.Expand(ctx, question), session.History)
 		if err != nil {
-			log.Fatalf("handle question: %v", err)
+			return fmt.Errorf("handle question: %w", err)
+		}
+		session.History = newHis
+	}
+}
+
+// newSession 创建与 spaceman 对话的会话, /agent 切换的 agent 使用同样的选项
+func newSession(ctx context.Context, opts ...common.Option) (*command.Session, error) {
+	a, err := newAgent(ctx, agent.AgentSpaceman, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create spaceman: %w", err)
+	}
+	return &command.Session{
+		Agent:     a,
+		AgentName: agent.AgentSpaceman,
+		NewAgent: func(ctx context.Context, name string) (agent.Agent, error) {
+			return newAgent(ctx, name, opts...)
+		},
+	}, nil
+}
+
+// newAgent 按名称创建 agent, 系统提示词附上工作目录适用的项目说明
+func newAgent(ctx context.Context, name string, opts ...common.Option) (agent.Agent, error) {
+	opts = append([]common.Option{common.WithInstructions(".")}, opts...)
+	switch name {
+	case agent.AgentSpaceman:
+		return common.NewSpaceMan(ctx, opts...)
+	case agent.AgentNetizen:
+		return common.NetizenFactory(opts...)(ctx, "")
+	default:
+		return nil, fmt.Errorf("unknown agent %q", name)
+	}
+}
+
+// setupTelemetry 按配置导出链路追踪, 返回的函数在退出前导出剩余的 span
+func setupTelemetry(ctx context.Context, cfg config.Telemetry) func() {
+	shutdown, err := telemetry.Setup(ctx, cfg)
+	if err != nil {
+		slog.Error("setup telemetry failed", "error", err)
+		return func() {}
+	}
+	return func() {
+		if err := shutdown(context.Background()); err != nil {
+			slog.Error("export spans failed", "error", err)
 		}
-		history = newHis
 	}
 }
 
-func readUserQuestion() string {
-	fmt.Printf("> ")
-	reader := bufio.NewReader(os.Stdin)
-	text, _ := reader.ReadString('\n')
-	return text
+// fail 记录错误并返回失败的退出码
+func fail(msg string, err error) int {
+	slog.Error(msg, "error", err)
+	return 1
 }
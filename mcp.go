@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/agent/common"
+	"github.com/bootun/cosmica/serve"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/tools/file"
+	"github.com/bootun/cosmica/tools/shell"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// runMCP 处理 cosmica mcp serve [-http addr] [-token token] [-tools]
+// 默认通过 stdio 提供服务, 指定 -http 时改为 streamable HTTP, 每个请求都需要携带访问令牌
+func runMCP(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "serve" {
+		return fmt.Errorf("usage: cosmica mcp serve [-http addr] [-token token] [-tools]")
+	}
+	fs := flag.NewFlagSet("mcp serve", flag.ExitOnError)
+	addr := fs.String("http", "", "listen on this address with streamable HTTP instead of stdio, e.g. 127.0.0.1:8081")
+	token := fs.String("token", os.Getenv(serve.TokenEnv), "bearer token required by -http, generated for loopback addresses when empty (env "+serve.TokenEnv+")")
+	withTools := fs.Bool("tools", false, "also expose the shell and file tools")
+	fs.Parse(args[1:])
+
+	// stdio 模式下标准输出用于传输协议, agent 的输出都写到标准错误
+	agents := []serve.MCPAgent{
+		{
+			Name:        agent.AgentSpaceman,
+			Description: "a general purpose agent that plans and solves the task with shell, file and browser tools, returns the final answer",
+			Create: func(ctx context.Context, task string) (agent.Agent, error) {
+				return common.NewSpaceMan(ctx, common.WithOutput(os.Stderr), common.WithInstructions("."))
+			},
+		},
+		{
+			Name:        agent.AgentNetizen,
+			Description: "an agent that operates a browser to look things up or act on the web, returns the final answer",
+			Create:      common.NetizenFactory(common.WithOutput(os.Stderr), common.WithInstructions(".")),
+		},
+	}
+	var ts *tools.ToolSet
+	if *withTools {
+		var err error
+		ts, err = tools.NewToolSet(
+			shell.NewShellExecutor(),
+			file.NewFileReader(),
+			file.NewDirReader(),
+		)
+		if err != nil {
+			return fmt.Errorf("create tool set: %w", err)
+		}
+	}
+	s, err := serve.NewMCPServer(agents, ts)
+	if err != nil {
+		return fmt.Errorf("create mcp server: %w", err)
+	}
+
+	if *addr != "" {
+		tok, err := serve.ResolveToken(*addr, *token)
+		if err != nil {
+			return err
+		}
+		if *token == "" {
+			fmt.Fprintf(os.Stderr, "access token: %s\n", tok)
+		}
+		slog.Info("mcp server listening", "addr", *addr)
+		return http.ListenAndServe(*addr, serve.RequireToken(tok, server.NewStreamableHTTPServer(s)))
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return server.NewStdioServer(s).Listen(ctx, os.Stdin, os.Stdout)
+}
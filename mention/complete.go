@@ -0,0 +1,62 @@
+package mention
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Complete 补全光标 pos(字节下标)前以 @ 开头的路径: 唯一匹配时补全整个名称, 目录以 / 结尾;
+// 多个匹配时补全公共前缀; 无法补全时返回 false
+func Complete(line string, pos int) (string, int, bool) {
+	start := strings.LastIndexAny(line[:pos], " \t\n") + 1
+	partial, ok := strings.CutPrefix(line[start:pos], "@")
+	if !ok {
+		return "", 0, false
+	}
+	dir, prefix := filepath.Split(partial)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return "", 0, false
+	}
+	var matches []string
+	for _, e := range entries {
+		name := e.Name()
+		// 未输入 . 时不补全隐藏文件
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		if e.IsDir() {
+			name += "/"
+		}
+		matches = append(matches, name)
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	completion := matches[0]
+	for _, m := range matches[1:] {
+		completion = commonPrefix(completion, m)
+	}
+	if completion == prefix {
+		return "", 0, false
+	}
+	word := "@" + dir + completion
+	return line[:start] + word + line[pos:], start + len(word), true
+}
+
+func commonPrefix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	// 不在 UTF-8 字符中间截断
+	for n > 0 && n < len(a) && a[n]&0xC0 == 0x80 {
+		n--
+	}
+	return a[:n]
+}
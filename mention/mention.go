@@ -0,0 +1,180 @@
+// Package mention 展开问题中的 @文件、@目录/ 引用与开头的 !命令: 用对应的工具读取内容, 作为附件追加在问题之后,
+// 用户不必等 agent 自己找到相关的代码
+package mention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/tools/file"
+	"github.com/bootun/cosmica/tools/shell"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// DefaultMaxBytes 单个附件默认最多保留的字节数
+	DefaultMaxBytes = 32 << 10
+	// DefaultMaxTotal 一个问题的附件默认最多占用的字节数
+	DefaultMaxTotal = 128 << 10
+	// maxFileSize 超过这个大小的文件不读取
+	maxFileSize = 8 << 20
+
+	attachmentsStart = "<attachments>"
+	attachmentsEnd   = "</attachments>"
+)
+
+// Expander 展开问题中的引用
+type Expander struct {
+	fileReader tool.InvokableTool
+	dirReader  tool.InvokableTool
+	shell      tool.InvokableTool
+	// MaxBytes 单个附件最多保留的字节数, 超出部分截断
+	MaxBytes int
+	// MaxTotal 所有附件最多占用的字节数, 超出部分截断, 达到后其余引用不再展开
+	MaxTotal int
+	// Approve 在执行 !命令 前请求批准, 与 agent 调用 shell_executor 时一样; 为 nil 时不执行命令
+	Approve agent.Approver
+}
+
+// NewExpander 创建使用 file_reader、dir_reader 与 shell_executor 读取内容的 Expander
+func NewExpander() *Expander {
+	return &Expander{
+		fileReader: file.NewFileReader(),
+		dirReader:  file.NewDirReader(),
+		shell:      shell.NewShellExecutor(),
+		MaxBytes:   DefaultMaxBytes,
+		MaxTotal:   DefaultMaxTotal,
+	}
+}
+
+// reference 是问题中的一处引用
+type reference struct {
+	kind   string // file, dir 或 command
+	target string
+}
+
+// Expand 返回附加了引用内容的问题, 没有引用时原样返回
+// 以 @ 开头的词是路径, 以 / 结尾或指向目录时列出目录, 不存在的路径(例如 @某人)保持原样;
+// 以 ! 开头的问题的第一行是要执行的命令, 经 Approve 批准后执行, 执行失败时附上错误输出
+func (e *Expander) Expand(ctx context.Context, question string) string {
+	// 已展开的问题(例如 /retry 重新提问)不再展开
+	if strings.Contains(question, "\n"+attachmentsStart+"\n") {
+		return question
+	}
+	refs := parse(question)
+	if len(refs) == 0 {
+		return question
+	}
+	var b strings.Builder
+	b.WriteString(question)
+	b.WriteString("\n\n" + attachmentsStart + "\n")
+	total := 0
+	for _, ref := range refs {
+		attr := "path"
+		if ref.kind == "command" {
+			attr = "cmd"
+		}
+		var content string
+		if total >= e.MaxTotal {
+			content = fmt.Sprintf("not attached: attachment limit of %d bytes reached", e.MaxTotal)
+		} else {
+			// 读取后才知道大小, 超出总量的部分截断
+			content = truncate(e.read(ctx, ref), e.MaxTotal-total)
+			total += len(content)
+		}
+		fmt.Fprintf(&b, "<%s %s=%q>\n%s\n</%s>\n", ref.kind, attr, ref.target, strings.TrimRight(content, "\n"), ref.kind)
+	}
+	b.WriteString(attachmentsEnd)
+	return b.String()
+}
+
+// parse 按出现顺序找出问题中的引用, 重复的引用只保留一次
+// 只有整个问题以 ! 开头时第一行才是命令, 粘贴的内容中以 ! 开头的行不会被执行
+func parse(question string) []reference {
+	var refs []reference
+	seen := make(map[reference]bool)
+	add := func(ref reference) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	lines := strings.Split(strings.TrimLeft(question, " \t\r\n"), "\n")
+	if cmd, ok := strings.CutPrefix(strings.TrimSpace(lines[0]), "!"); ok && cmd != "" && !strings.HasPrefix(cmd, " ") && !strings.HasPrefix(cmd, "=") {
+		add(reference{kind: "command", target: cmd})
+		lines = lines[1:]
+	}
+	for _, line := range lines {
+		for _, word := range strings.Fields(line) {
+			path, ok := strings.CutPrefix(word, "@")
+			if !ok || path == "" {
+				continue
+			}
+			// 允许引用出现在句末, 例如 "看看 @main.go."
+			path = strings.TrimRight(path, ".,;:!?)，。；：！？）")
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if info.IsDir() {
+				add(reference{kind: "dir", target: path})
+			} else if !strings.HasSuffix(path, "/") {
+				add(reference{kind: "file", target: path})
+			}
+		}
+	}
+	return refs
+}
+
+// read 用对应的工具读取引用的内容并截断到 MaxBytes, 出错时返回错误说明
+func (e *Expander) read(ctx context.Context, ref reference) string {
+	var (
+		t    tool.InvokableTool
+		args map[string]string
+	)
+	switch ref.kind {
+	case "file":
+		if info, err := os.Stat(ref.target); err == nil && info.Size() > maxFileSize {
+			return fmt.Sprintf("not attached: file is %d bytes", info.Size())
+		}
+		t, args = e.fileReader, map[string]string{"filename": ref.target}
+	case "dir":
+		t, args = e.dirReader, map[string]string{"dirname": filepath.Clean(ref.target)}
+	case "command":
+		t, args = e.shell, map[string]string{"command": ref.target}
+	}
+	data, _ := json.Marshal(args)
+	if ref.kind == "command" {
+		call := schema.ToolCall{Type: "function", Function: schema.FunctionCall{Name: shell.ExecutorName, Arguments: string(data)}}
+		if e.Approve == nil || !e.Approve(ctx, call) {
+			return "not run: the command was not approved"
+		}
+	}
+	out, err := t.InvokableRun(ctx, string(data))
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	if ref.kind == "file" && strings.ContainsRune(out, 0) {
+		return "not attached: binary file"
+	}
+	return truncate(out, e.MaxBytes)
+}
+
+// truncate 把附件截断到 n 字节, 不截断 UTF-8 字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("\n... truncated %d bytes", len(s)-cut)
+}
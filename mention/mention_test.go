@@ -0,0 +1,118 @@
+package mention
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// chdirTestTree 切换到包含几个文件的临时目录
+func chdirTestTree(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":        "package main\n",
+		"pkg/util.go":    "package pkg\n",
+		"pkg/utils.go":   "package pkg\n",
+		"pkg/.hidden":    "",
+		"big.txt":        strings.Repeat("界", 100),
+		"blob.bin":       "a\x00b",
+		"docs/readme.md": "# docs\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+}
+
+func TestExpand(t *testing.T) {
+	chdirTestTree(t)
+	e := NewExpander()
+	var approved []string
+	e.Approve = func(ctx context.Context, call schema.ToolCall) bool {
+		approved = append(approved, call.Function.Name+" "+call.Function.Arguments)
+		return true
+	}
+	got := e.Expand(context.Background(), "!echo hi\n看看 @main.go 和 @pkg/, 问问 @someone\n!false")
+	for _, want := range []string{
+		"!echo hi\n看看 @main.go 和 @pkg/, 问问 @someone\n!false\n\n<attachments>\n",
+		"<command cmd=\"echo hi\">\nhi\n</command>\n",
+		"<file path=\"main.go\">\npackage main\n</file>\n",
+		"<dir path=\"pkg/\">\n[\n  \".hidden\",\n  \"util.go\",\n  \"utils.go\"\n]\n</dir>\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expanded question does not contain %q:\n%s", want, got)
+		}
+	}
+	// 只有问题开头的 ! 是命令, 其余以 ! 开头的行(例如粘贴的内容)不执行
+	if strings.Contains(got, "cmd=\"false\"") || len(approved) != 1 || approved[0] != `shell_executor {"command":"echo hi"}` {
+		t.Errorf("approved %q:\n%s", approved, got)
+	}
+	if strings.Contains(got, "someone\">") {
+		t.Errorf("a path that does not exist should be left alone:\n%s", got)
+	}
+	if again := e.Expand(context.Background(), got); again != got {
+		t.Errorf("an expanded question should not be expanded again:\n%s", again)
+	}
+	if q := "没有引用"; e.Expand(context.Background(), q) != q {
+		t.Error("a question without references should be returned as is")
+	}
+
+	e.Approve = func(ctx context.Context, call schema.ToolCall) bool { return false }
+	if got := e.Expand(context.Background(), "!echo hi"); !strings.Contains(got, "<command cmd=\"echo hi\">\nnot run: the command was not approved\n") {
+		t.Errorf("a rejected command should not run:\n%s", got)
+	}
+	e.Approve = nil
+	if got := e.Expand(context.Background(), "!echo hi"); strings.Contains(got, "\nhi\n") {
+		t.Errorf("a command should not run without an approver:\n%s", got)
+	}
+}
+
+func TestExpandCaps(t *testing.T) {
+	chdirTestTree(t)
+	e := NewExpander()
+	e.MaxBytes, e.MaxTotal = 10, 60
+	got := e.Expand(context.Background(), "@big.txt @blob.bin @main.go @docs/readme.md")
+	for _, want := range []string{
+		"<file path=\"big.txt\">\n界界界\n... truncated 291 bytes\n</file>",
+		"<file path=\"blob.bin\">\nnot attached: binary file\n</file>",
+		// 读取后超出总量的部分截断
+		"<file path=\"main.go\">\npa\n... truncated 30 bytes\n</file>",
+		"<file path=\"docs/readme.md\">\nnot attached: attachment limit of 60 bytes reached\n</file>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expanded question does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	chdirTestTree(t)
+	for _, tt := range []struct {
+		line, want string
+		pos        int
+		ok         bool
+	}{
+		{line: "看 @ma", pos: len("看 @ma"), want: "看 @main.go", ok: true},
+		{line: "@pk and more", pos: 3, want: "@pkg/ and more", ok: true},
+		{line: "@pkg/u", pos: 6, want: "@pkg/util", ok: true},
+		{line: "@pkg/util", pos: 9, ok: false},
+		{line: "@pkg/.h", pos: 7, want: "@pkg/.hidden", ok: true},
+		{line: "main", pos: 4, ok: false},
+		{line: "@nothing", pos: 8, ok: false},
+	} {
+		got, pos, ok := Complete(tt.line, tt.pos)
+		if ok != tt.ok || (ok && (got != tt.want || pos != len(tt.want)-len(tt.line)+tt.pos)) {
+			t.Errorf("Complete(%q, %d) = %q, %d, %v; want %q, %v", tt.line, tt.pos, got, pos, ok, tt.want, tt.ok)
+		}
+	}
+}
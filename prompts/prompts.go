@@ -0,0 +1,209 @@
+// Package prompts 管理 agent 的提示词模板: 模板以 Go template 编写, 按语言(zh/en)内置在程序中,
+// 也可以放在配置的目录中覆盖, 修改提示词不需要重新编译
+package prompts
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 内置的语言, 未知的语言使用 DefaultLocale
+const (
+	LocaleZH      = "zh"
+	LocaleEN      = "en"
+	DefaultLocale = LocaleZH
+)
+
+// 内置模板的名称
+const (
+	Spaceman    = "spaceman"
+	Netizen     = "netizen"
+	Assistant   = "assistant"
+	CreateAgent = "create_agent"
+)
+
+//go:embed templates
+var builtin embed.FS
+
+var (
+	mu sync.RWMutex
+	// overrideDir 覆盖内置模板的目录, 其中的 <locale>/<name>.tmpl 优先于内置模板
+	overrideDir string
+	// locale 配置的语言, 为空时根据环境变量判断
+	locale string
+)
+
+// Configure 设置覆盖模板的目录与语言, 由 main 在启动时根据配置调用, 参数为空表示使用默认值
+func Configure(dir, lang string) {
+	mu.Lock()
+	defer mu.Unlock()
+	overrideDir, locale = dir, lang
+}
+
+// Locale 返回提示词使用的语言: 优先使用配置, 其次是 LC_ALL、LC_MESSAGES 与 LANG 环境变量
+func Locale() string {
+	mu.RLock()
+	lang := locale
+	mu.RUnlock()
+	if lang != "" {
+		return normalize(lang)
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			return normalize(v)
+		}
+	}
+	return DefaultLocale
+}
+
+// normalize 把 en_US.UTF-8 这样的语言设置转换为内置的语言
+func normalize(lang string) string {
+	lang = strings.ToLower(lang)
+	for _, l := range []string{LocaleZH, LocaleEN} {
+		if strings.HasPrefix(lang, l) {
+			return l
+		}
+	}
+	return DefaultLocale
+}
+
+// Tool 是提示词中列出的工具
+type Tool struct {
+	Name        string
+	Description string
+}
+
+// SubAgent 是可以委派任务的子 agent
+type SubAgent struct {
+	Name        string
+	Description string
+}
+
+// Vars 是渲染模板可用的变量, 在模板中以 {{.os}}、{{.cwd}}、{{.date}}、{{.locale}}、{{.tools}} 与 {{.sub_agents}} 引用
+type Vars struct {
+	OS        string
+	Cwd       string
+	Date      string
+	Locale    string
+	Tools     []Tool
+	SubAgents []SubAgent
+	// Data 模板自己的变量, 例如 assistant 模板的 {{.task}}
+	Data map[string]any
+}
+
+// Env 返回从运行环境得到的变量
+func Env() Vars {
+	cwd, _ := os.Getwd()
+	return Vars{
+		OS:     runtime.GOOS,
+		Cwd:    cwd,
+		Date:   time.Now().Format(time.DateOnly),
+		Locale: Locale(),
+	}
+}
+
+// Merge 用 o 中不为空的字段覆盖 v
+func (v Vars) Merge(o Vars) Vars {
+	if o.OS != "" {
+		v.OS = o.OS
+	}
+	if o.Cwd != "" {
+		v.Cwd = o.Cwd
+	}
+	if o.Date != "" {
+		v.Date = o.Date
+	}
+	if o.Locale != "" {
+		v.Locale = o.Locale
+	}
+	if o.Tools != nil {
+		v.Tools = o.Tools
+	}
+	if o.SubAgents != nil {
+		v.SubAgents = o.SubAgents
+	}
+	if o.Data != nil {
+		v.Data = o.Data
+	}
+	return v
+}
+
+func (v Vars) values() map[string]any {
+	values := map[string]any{
+		"os":         v.OS,
+		"cwd":        v.Cwd,
+		"date":       v.Date,
+		"locale":     v.Locale,
+		"tools":      v.Tools,
+		"sub_agents": v.SubAgents,
+	}
+	for k, val := range v.Data {
+		values[k] = val
+	}
+	return values
+}
+
+// Source 返回模板原文: 依次查找覆盖目录与内置模板中 locale 的版本, 都没有时使用默认语言的内置模板
+func Source(name, locale string) (string, error) {
+	mu.RLock()
+	dir := overrideDir
+	mu.RUnlock()
+	file := name + ".tmpl"
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, locale, file))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("read prompt %s: %w", name, err)
+		}
+	}
+	for _, l := range []string{locale, DefaultLocale} {
+		data, err := builtin.ReadFile(path.Join("templates", l, file))
+		if err == nil {
+			return string(data), nil
+		}
+	}
+	return "", fmt.Errorf("prompt %s not found", name)
+}
+
+// Render 按 vars.Locale 渲染名为 name 的模板, 引用不存在的变量时返回错误
+func Render(ctx context.Context, name string, vars Vars) (string, error) {
+	if vars.Locale == "" {
+		vars.Locale = Locale()
+	}
+	src, err := Source(name, vars.Locale)
+	if err != nil {
+		return "", err
+	}
+	msgs, err := schema.SystemMessage(src).Format(ctx, vars.values(), schema.GoTemplate)
+	if err != nil {
+		return "", fmt.Errorf("render prompt %s: %w", name, err)
+	}
+	return strings.TrimSpace(msgs[0].Content), nil
+}
+
+// SubAgents 返回子 agent 的列表, 描述取自 <name>_description 模板
+func SubAgents(ctx context.Context, locale string, names ...string) ([]SubAgent, error) {
+	agents := make([]SubAgent, 0, len(names))
+	for _, name := range names {
+		desc, err := Render(ctx, name+"_description", Vars{Locale: locale})
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, SubAgent{Name: name, Description: desc})
+	}
+	return agents, nil
+}
@@ -0,0 +1,91 @@
+package prompts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	t.Cleanup(func() { Configure("", "") })
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	for env, want := range map[string]string{"en_US.UTF-8": LocaleEN, "zh_CN.UTF-8": LocaleZH, "fr_FR": DefaultLocale, "": DefaultLocale} {
+		t.Setenv("LANG", env)
+		if got := Locale(); got != want {
+			t.Errorf("LANG=%q: Locale() = %q, want %q", env, got, want)
+		}
+	}
+	t.Setenv("LANG", "zh_CN.UTF-8")
+	Configure("", "EN")
+	if got := Locale(); got != LocaleEN {
+		t.Errorf("configured locale = %q, want en", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	ctx := context.Background()
+	vars := Vars{
+		OS:        "linux",
+		Cwd:       "/work",
+		Date:      "2025-01-01",
+		Tools:     []Tool{{Name: "bell", Description: "结束对话"}},
+		SubAgents: []SubAgent{{Name: "netizen", Description: "操作浏览器"}},
+	}
+	for _, locale := range []string{LocaleZH, LocaleEN} {
+		vars.Locale = locale
+		got, err := Render(ctx, Spaceman, vars)
+		if err != nil {
+			t.Fatalf("%s: %v", locale, err)
+		}
+		for _, want := range []string{"spaceman", "linux", "/work", "2025-01-01", "- bell: 结束对话", "- netizen: 操作浏览器"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s spaceman prompt does not contain %q:\n%s", locale, want, got)
+			}
+		}
+	}
+
+	if _, err := Render(ctx, "nope", Vars{Locale: LocaleZH}); err == nil {
+		t.Error("rendering an unknown template should fail")
+	}
+}
+
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, LocaleEN), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string]string{Netizen: "custom netizen on {{.os}}\n", Assistant: "solve {{.task}}"} {
+		if err := os.WriteFile(filepath.Join(dir, LocaleEN, name+".tmpl"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Configure(dir, "")
+	t.Cleanup(func() { Configure("", "") })
+
+	ctx := context.Background()
+	got, err := Render(ctx, Netizen, Vars{Locale: LocaleEN, OS: "darwin"})
+	if err != nil || got != "custom netizen on darwin" {
+		t.Errorf("overridden en template = %q, %v", got, err)
+	}
+	// 覆盖目录中没有的语言仍使用内置模板
+	got, err = Render(ctx, Netizen, Vars{Locale: LocaleZH})
+	if err != nil || strings.Contains(got, "custom") {
+		t.Errorf("builtin zh template = %q, %v", got, err)
+	}
+
+	if _, err := Render(ctx, Assistant, Vars{Locale: LocaleEN}); err == nil {
+		t.Error("rendering without the task variable should fail")
+	}
+	got, err = Render(ctx, Assistant, Vars{Locale: LocaleEN, Data: map[string]any{"task": "x"}})
+	if err != nil || got != "solve x" {
+		t.Errorf("assistant with task = %q, %v", got, err)
+	}
+
+	agents, err := SubAgents(ctx, LocaleEN, "netizen")
+	if err != nil || len(agents) != 1 || agents[0].Description == "" {
+		t.Errorf("SubAgents = %+v, %v", agents, err)
+	}
+}
@@ -0,0 +1,390 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	defaultAnthropicBaseURL   = "https://api.anthropic.com"
+	anthropicVersion          = "2023-06-01"
+	defaultAnthropicMaxTokens = 8192
+)
+
+// anthropicModel 直接调用 Anthropic Messages API
+type anthropicModel struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	modelID string
+	tools   []*schema.ToolInfo
+	caps    Capabilities
+}
+
+func newAnthropic(ctx context.Context, cfg config.Model) (ChatModel, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("anthropic provider requires token")
+	}
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+	// 兼容填写了带 v1 后缀的地址
+	baseURL = strings.TrimSuffix(baseURL, "/v1")
+	return &anthropicModel{
+		client:  http.DefaultClient,
+		baseURL: baseURL,
+		token:   cfg.Token,
+		modelID: cfg.ModelID,
+		caps: withCapabilities(Capabilities{
+			ToolCalling: true,
+			Vision:      true,
+			MaxContext:  200000,
+		}, cfg.Capabilities),
+	}, nil
+}
+
+func (am *anthropicModel) Capabilities() Capabilities {
+	return am.caps
+}
+
+func (am *anthropicModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	nm := *am
+	nm.tools = tools
+	return &nm, nil
+}
+
+func (am *anthropicModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	sr, err := am.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.ConcatMessageStream(sr)
+}
+
+func (am *anthropicModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	options := model.GetCommonOptions(&model.Options{Model: &am.modelID, Tools: am.tools}, opts...)
+	req, err := am.buildRequest(input, options)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, am.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("x-api-key", am.token)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := am.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("anthropic api status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	sr, sw := schema.Pipe[*schema.Message](16)
+	go func() {
+		defer resp.Body.Close()
+		defer sw.Close()
+		if err := readAnthropicEvents(resp.Body, sw); err != nil {
+			sw.Send(nil, err)
+		}
+	}()
+	return sr, nil
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Temperature *float32           `json:"temperature,omitempty"`
+	TopP        *float32           `json:"top_p,omitempty"`
+	Stop        []string           `json:"stop_sequences,omitempty"`
+	Stream      bool               `json:"stream"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	Source    *anthropicImage `json:"source,omitempty"`
+}
+
+// anthropicImage 是图片块的来源, data URL 以 base64 发送, 其他地址由 Anthropic 下载
+type anthropicImage struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+func (am *anthropicModel) buildRequest(input []*schema.Message, options *model.Options) (*anthropicRequest, error) {
+	req := &anthropicRequest{
+		Model:       *options.Model,
+		MaxTokens:   defaultAnthropicMaxTokens,
+		Temperature: options.Temperature,
+		TopP:        options.TopP,
+		Stop:        options.Stop,
+		Stream:      true,
+	}
+	if options.MaxTokens != nil {
+		req.MaxTokens = *options.MaxTokens
+	}
+
+	var system []string
+	for _, msg := range input {
+		var role string
+		var blocks []anthropicBlock
+		switch msg.Role {
+		case schema.System:
+			system = append(system, msg.Content)
+			continue
+		case schema.User:
+			role = "user"
+			var err error
+			if blocks, err = anthropicUserBlocks(msg); err != nil {
+				return nil, err
+			}
+		case schema.Assistant:
+			role = "assistant"
+			if strings.TrimSpace(msg.Content) != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				args := strings.TrimSpace(tc.Function.Arguments)
+				if args == "" {
+					args = "{}"
+				}
+				blocks = append(blocks, anthropicBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: json.RawMessage(args),
+				})
+			}
+		case schema.Tool:
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		default:
+			return nil, fmt.Errorf("unsupported role %q", msg.Role)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		// Messages API 要求 user/assistant 交替出现, 相邻的同角色消息需要合并
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, blocks...)
+			continue
+		}
+		req.Messages = append(req.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	for _, info := range options.Tools {
+		inputSchema := any(map[string]any{"type": "object", "properties": map[string]any{}})
+		if info.ParamsOneOf != nil {
+			s, err := info.ParamsOneOf.ToOpenAPIV3()
+			if err != nil {
+				return nil, fmt.Errorf("convert %s params: %w", info.Name, err)
+			}
+			if s != nil {
+				inputSchema = s
+			}
+		}
+		req.Tools = append(req.Tools, anthropicTool{
+			Name:        info.Name,
+			Description: info.Desc,
+			InputSchema: inputSchema,
+		})
+	}
+	return req, nil
+}
+
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// readAnthropicEvents 将 SSE 事件转换为 eino 的消息分片
+func readAnthropicEvents(r io.Reader, sw *schema.StreamWriter[*schema.Message]) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	// content block 下标 -> 工具调用序号
+	toolIndex := make(map[int]int)
+	var inputTokens int
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+
+		var chunk *schema.Message
+		switch ev.Type {
+		case "message_start":
+			inputTokens = ev.Message.Usage.InputTokens
+		case "content_block_start":
+			switch ev.ContentBlock.Type {
+			case "text":
+				if ev.ContentBlock.Text != "" {
+					chunk = &schema.Message{Role: schema.Assistant, Content: ev.ContentBlock.Text}
+				}
+			case "tool_use":
+				idx := len(toolIndex)
+				toolIndex[ev.Index] = idx
+				chunk = &schema.Message{Role: schema.Assistant, ToolCalls: []schema.ToolCall{{
+					Index:    &idx,
+					ID:       ev.ContentBlock.ID,
+					Type:     "function",
+					Function: schema.FunctionCall{Name: ev.ContentBlock.Name},
+				}}}
+			}
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				chunk = &schema.Message{Role: schema.Assistant, Content: ev.Delta.Text}
+			case "input_json_delta":
+				idx, ok := toolIndex[ev.Index]
+				if !ok {
+					continue
+				}
+				chunk = &schema.Message{Role: schema.Assistant, ToolCalls: []schema.ToolCall{{
+					Index:    &idx,
+					Function: schema.FunctionCall{Arguments: ev.Delta.PartialJSON},
+				}}}
+			}
+		case "message_delta":
+			chunk = &schema.Message{Role: schema.Assistant, ResponseMeta: &schema.ResponseMeta{
+				FinishReason: ev.Delta.StopReason,
+				Usage: &schema.TokenUsage{
+					PromptTokens:     inputTokens,
+					CompletionTokens: ev.Usage.OutputTokens,
+					TotalTokens:      inputTokens + ev.Usage.OutputTokens,
+				},
+			}}
+		case "error":
+			return fmt.Errorf("anthropic stream error: %s: %s", ev.Error.Type, ev.Error.Message)
+		case "message_stop":
+			return nil
+		}
+		if chunk != nil {
+			if closed := sw.Send(chunk, nil); closed {
+				return nil
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// anthropicUserBlocks 把用户消息的文本与 MultiContent 中的文字、图片转换为内容块
+func anthropicUserBlocks(msg *schema.Message) ([]anthropicBlock, error) {
+	var blocks []anthropicBlock
+	// Messages API 拒绝空的文本块
+	if strings.TrimSpace(msg.Content) != "" {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+	}
+	for _, part := range msg.MultiContent {
+		switch part.Type {
+		case schema.ChatMessagePartTypeText:
+			if strings.TrimSpace(part.Text) != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: part.Text})
+			}
+		case schema.ChatMessagePartTypeImageURL:
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return nil, errors.New("image part without url")
+			}
+			src, err := anthropicImageSource(part.ImageURL)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: src})
+		default:
+			return nil, fmt.Errorf("unsupported content part %q", part.Type)
+		}
+	}
+	return blocks, nil
+}
+
+// anthropicImageSource 解析 data:<mime>;base64,<data> 形式的内联图片, http(s) 地址原样传递
+func anthropicImageSource(img *schema.ChatMessageImageURL) (*anthropicImage, error) {
+	if rest, ok := strings.CutPrefix(img.URL, "data:"); ok {
+		meta, data, ok := strings.Cut(rest, ",")
+		mediaType, base64, _ := strings.Cut(meta, ";")
+		if !ok || base64 != "base64" {
+			return nil, errors.New("image data url must be base64 encoded")
+		}
+		if mediaType == "" {
+			mediaType = img.MIMEType
+		}
+		return &anthropicImage{Type: "base64", MediaType: mediaType, Data: data}, nil
+	}
+	if strings.HasPrefix(img.URL, "https://") || strings.HasPrefix(img.URL, "http://") {
+		return &anthropicImage{Type: "url", URL: img.URL}, nil
+	}
+	return nil, fmt.Errorf("unsupported image url %.32q", img.URL)
+}
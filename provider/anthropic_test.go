@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestAnthropicImages(t *testing.T) {
+	am := &anthropicModel{modelID: "claude"}
+	opts := model.GetCommonOptions(&model.Options{Model: &am.modelID})
+	msg := &schema.Message{Role: schema.User, MultiContent: []schema.ChatMessagePart{
+		{Type: schema.ChatMessagePartTypeText, Text: "what is this"},
+		{Type: schema.ChatMessagePartTypeImageURL, ImageURL: &schema.ChatMessageImageURL{URL: "data:image/png;base64,iVBORw0K"}},
+		{Type: schema.ChatMessagePartTypeImageURL, ImageURL: &schema.ChatMessageImageURL{URL: "https://example.com/cat.jpg"}},
+	}}
+	req, err := am.buildRequest([]*schema.Message{msg}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(req.Messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"role":"user","content":[{"type":"text","text":"what is this"},` +
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0K"}},` +
+		`{"type":"image","source":{"type":"url","url":"https://example.com/cat.jpg"}}]}]`
+	if string(data) != want {
+		t.Errorf("messages =\n%s\nwant\n%s", data, want)
+	}
+
+	// parts that cannot be sent are reported instead of being dropped
+	for _, part := range []schema.ChatMessagePart{
+		{Type: schema.ChatMessagePartTypeImageURL, ImageURL: &schema.ChatMessageImageURL{URL: "file:///tmp/cat.jpg"}},
+		{Type: schema.ChatMessagePartTypeAudioURL, AudioURL: &schema.ChatMessageAudioURL{URL: "https://example.com/a.wav"}},
+	} {
+		bad := &schema.Message{Role: schema.User, MultiContent: []schema.ChatMessagePart{part}}
+		if _, err := am.buildRequest([]*schema.Message{bad}, opts); err == nil {
+			t.Errorf("expected an error for %+v", part)
+		}
+	}
+}
+
+func TestAnthropicBuildRequest(t *testing.T) {
+	am := &anthropicModel{modelID: "claude"}
+	input := []*schema.Message{
+		schema.SystemMessage("you are spaceman"),
+		schema.SystemMessage("project rules"),
+		schema.UserMessage("list the files"),
+		schema.AssistantMessage("", []schema.ToolCall{{ID: "call_1", Function: schema.FunctionCall{Name: "dir_reader", Arguments: `{"dirname":"."}`}}}),
+		schema.ToolMessage("main.go", "call_1"),
+		schema.UserMessage(" "),
+		schema.AssistantMessage("done", []schema.ToolCall{{ID: "call_2", Function: schema.FunctionCall{Name: "bell"}}}),
+	}
+	req, err := am.buildRequest(input, model.GetCommonOptions(&model.Options{Model: &am.modelID}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.System != "you are spaceman\n\nproject rules" || req.MaxTokens != defaultAnthropicMaxTokens || !req.Stream {
+		t.Errorf("request = %+v", req)
+	}
+	data, err := json.Marshal(req.Messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tool result and the blank user message are merged into one user turn without an empty text block
+	want := `[{"role":"user","content":[{"type":"text","text":"list the files"}]},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"call_1","name":"dir_reader","input":{"dirname":"."}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"call_1","content":"main.go"}]},` +
+		`{"role":"assistant","content":[{"type":"text","text":"done"},{"type":"tool_use","id":"call_2","name":"bell","input":{}}]}]`
+	if string(data) != want {
+		t.Errorf("messages =\n%s\nwant\n%s", data, want)
+	}
+
+	if _, err := am.buildRequest([]*schema.Message{{Role: "function"}}, model.GetCommonOptions(&model.Options{Model: &am.modelID})); err == nil {
+		t.Error("expected an error for an unsupported role")
+	}
+}
+
+const anthropicStream = `event: message_start
+data: {"type":"message_start","message":{"usage":{"input_tokens":12}}}
+
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"let me "}}
+
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"look"}}
+
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"file_reader"}}
+
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"filename\":"}}
+
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"go.mod\"}"}}
+
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}
+
+data: {"type":"message_stop"}
+`
+
+func TestAnthropicStream(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("content-type", "text/event-stream")
+		w.Write([]byte(anthropicStream))
+	}))
+	defer srv.Close()
+
+	cm, err := newAnthropic(context.Background(), config.Model{BaseURL: srv.URL + "/v1", Token: "secret", ModelID: "claude"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := cm.Generate(context.Background(), []*schema.Message{schema.UserMessage("read go.mod")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["model"] != "claude" {
+		t.Errorf("request body = %v", body)
+	}
+	if msg.Content != "let me look" || len(msg.ToolCalls) != 1 {
+		t.Fatalf("message = %+v", msg)
+	}
+	if tc := msg.ToolCalls[0]; tc.ID != "toolu_1" || tc.Function.Name != "file_reader" || tc.Function.Arguments != `{"filename":"go.mod"}` {
+		t.Errorf("tool call = %+v", tc)
+	}
+	if meta := msg.ResponseMeta; meta == nil || meta.FinishReason != "tool_use" || meta.Usage.TotalTokens != 19 {
+		t.Errorf("response meta = %+v", meta)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n"))
+	}))
+	defer srv.Close()
+
+	cm, err := newAnthropic(context.Background(), config.Model{BaseURL: srv.URL, Token: "secret", ModelID: "claude"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cm.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")}); err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/cloudwego/eino-ext/libs/acl/openai"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// NewEmbedder 根据配置创建向量模型, 目前支持 OpenAI 兼容接口、Azure 与 ollama
+func NewEmbedder(ctx context.Context, cfg config.Model) (embedding.Embedder, error) {
+	if cfg.ModelID == "" {
+		return nil, fmt.Errorf("embedding model_id is required")
+	}
+	conf := &openai.EmbeddingConfig{
+		BaseURL: cfg.BaseURL,
+		APIKey:  cfg.Token,
+		Model:   cfg.ModelID,
+	}
+	switch name := strings.ToLower(strings.TrimSpace(cfg.Provider)); name {
+	case "", OpenAI:
+	case Azure:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("azure provider requires base_url")
+		}
+		conf.ByAzure, conf.APIVersion = true, cfg.APIVersion
+		if conf.APIVersion == "" {
+			conf.APIVersion = defaultAzureVersion
+		}
+	case Ollama:
+		if conf.BaseURL == "" {
+			conf.BaseURL = defaultOllamaBaseURL
+		}
+		if conf.APIKey == "" {
+			conf.APIKey = "ollama"
+		}
+	default:
+		return nil, fmt.Errorf("provider %q does not support embeddings", cfg.Provider)
+	}
+	e, err := openai.NewEmbeddingClient(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("create embedding model: %w", err)
+	}
+	return e, nil
+}
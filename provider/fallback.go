@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ExtraModelKey 记录实际应答的模型, 写入消息的 Extra 中
+const ExtraModelKey = "cosmica_model"
+
+// member 是模型链中的一个模型
+type member struct {
+	name  string
+	model model.ToolCallingChatModel
+}
+
+// fallback 按顺序调用模型链, 出错时自动切换到下一个模型
+type fallback struct {
+	members []member
+	caps    Capabilities
+}
+
+// NewChain 根据模型链创建支持自动回退的模型
+// 模型链的能力取所有模型的交集, 保证切换模型后对话历史依然可用
+func NewChain(ctx context.Context, chain []config.Model) (ChatModel, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("empty model chain")
+	}
+	fb := &fallback{}
+	for i, cfg := range chain {
+		// 同一个模型可能以不同的配置出现多次, 优先使用 models 中的名称区分
+		name := cfg.Name
+		if name == "" {
+			name = cfg.ModelID
+		}
+		cm, err := New(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("model chain[%d] %s: %w", i, name, err)
+		}
+		caps := cm.Capabilities()
+		if i == 0 {
+			fb.caps = caps
+		} else {
+			fb.caps.ToolCalling = fb.caps.ToolCalling && caps.ToolCalling
+			fb.caps.Vision = fb.caps.Vision && caps.Vision
+			if caps.MaxContext > 0 && (fb.caps.MaxContext == 0 || caps.MaxContext < fb.caps.MaxContext) {
+				fb.caps.MaxContext = caps.MaxContext
+			}
+		}
+		fb.members = append(fb.members, member{name: name, model: cm})
+	}
+	return fb, nil
+}
+
+func (fb *fallback) Capabilities() Capabilities {
+	return fb.caps
+}
+
+func (fb *fallback) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	nfb := &fallback{caps: fb.caps, members: make([]member, 0, len(fb.members))}
+	for _, m := range fb.members {
+		bound, err := m.model.WithTools(tools)
+		if err != nil {
+			return nil, fmt.Errorf("bind tools to %s: %w", m.name, err)
+		}
+		nfb.members = append(nfb.members, member{name: m.name, model: bound})
+	}
+	return nfb, nil
+}
+
+func (fb *fallback) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	var errs []error
+	for i, m := range fb.members {
+		msg, err := m.model.Generate(ctx, input, opts...)
+		if err == nil {
+			setModel(msg, m.name)
+			return msg, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		fb.logFailover(ctx, i, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Stream 在收到第一个分片前出错时切换模型, 已经开始输出后的错误直接返回给调用方
+func (fb *fallback) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	var errs []error
+	for i, m := range fb.members {
+		sr, first, err := openStream(ctx, m.model, input, opts...)
+		if err == nil {
+			return fb.relay(sr, first, m.name), nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		fb.logFailover(ctx, i, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// openStream 建立流并读取第一个分片, 以便尽早发现限流等错误
+func openStream(ctx context.Context, cm model.BaseChatModel, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], *schema.Message, error) {
+	sr, err := cm.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	first, err := sr.Recv()
+	if err != nil && err != io.EOF {
+		sr.Close()
+		return nil, nil, err
+	}
+	if err == io.EOF {
+		// 空流
+		return sr, nil, nil
+	}
+	return sr, first, nil
+}
+
+// relay 把第一个分片与剩余的流重新拼接, 并在第一个分片上标记模型名称
+func (fb *fallback) relay(sr *schema.StreamReader[*schema.Message], first *schema.Message, name string) *schema.StreamReader[*schema.Message] {
+	out, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sr.Close()
+		defer sw.Close()
+		if first == nil {
+			first = &schema.Message{Role: schema.Assistant}
+		}
+		setModel(first, name)
+		if closed := sw.Send(first, nil); closed {
+			return
+		}
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				return
+			}
+			if closed := sw.Send(chunk, err); closed || err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (fb *fallback) logFailover(ctx context.Context, i int, err error) {
+	if i+1 < len(fb.members) {
+		slog.WarnContext(ctx, "model failed, falling back", "model", fb.members[i].name, "next", fb.members[i+1].name, "error", err)
+	}
+}
+
+func setModel(msg *schema.Message, name string) {
+	if msg == nil {
+		return
+	}
+	if msg.Extra == nil {
+		msg.Extra = make(map[string]any)
+	}
+	msg.Extra[ExtraModelKey] = name
+}
+
+// ModelOf 返回应答消息实际使用的模型, 未记录时返回空字符串
+func ModelOf(msg *schema.Message) string {
+	if msg == nil || msg.Extra == nil {
+		return ""
+	}
+	name, _ := msg.Extra[ExtraModelKey].(string)
+	return name
+}
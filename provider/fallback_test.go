@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/provider/replay"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// brokenModel accepts the request but fails before sending the first chunk, like a rate limited stream.
+type brokenModel struct{}
+
+func (brokenModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return brokenModel{}, nil
+}
+
+func (brokenModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return nil, errors.New("429 too many requests")
+}
+
+func (brokenModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](1)
+	sw.Send(nil, errors.New("429 too many requests"))
+	sw.Close()
+	return sr, nil
+}
+
+func init() {
+	Register("broken", func(ctx context.Context, cfg config.Model) (ChatModel, error) {
+		return &capable{ToolCallingChatModel: brokenModel{}, caps: Capabilities{ToolCalling: true}}, nil
+	})
+}
+
+// newTestChain builds the routing chain of spaceman from models that all share the same model ID.
+func newTestChain(t *testing.T, members map[string]config.Model, order ...string) ChatModel {
+	t.Helper()
+	cfg := &config.Config{Models: members, Routing: map[string][]string{"spaceman": order}}
+	chain, err := cfg.ModelChain("spaceman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := NewChain(context.Background(), chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+func replayModel(t *testing.T, turns ...replay.Turn) config.Model {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := (&replay.Fixture{Turns: turns}).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	return config.Model{Provider: Replay, ModelID: "qwen", Fixture: path}
+}
+
+func TestFallbackAfterFailedPrimary(t *testing.T) {
+	cm := newTestChain(t, map[string]config.Model{
+		"primary": replayModel(t, replay.Fail("rate limited")),
+		"backup":  replayModel(t, replay.Reply("hello")),
+	}, "primary", "backup")
+	msg, err := cm.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both members are qwen, the config name tells which one answered
+	if msg.Content != "hello" || ModelOf(msg) != "backup" {
+		t.Errorf("message = %q from %q", msg.Content, ModelOf(msg))
+	}
+}
+
+func TestFallbackAfterFailedStreamStart(t *testing.T) {
+	cm := newTestChain(t, map[string]config.Model{
+		"primary": {Provider: "broken", ModelID: "qwen"},
+		"backup":  replayModel(t, replay.Reply("hello")),
+	}, "primary", "backup")
+	sr, err := cm.Stream(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := schema.ConcatMessageStream(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "hello" || ModelOf(msg) != "backup" {
+		t.Errorf("message = %q from %q", msg.Content, ModelOf(msg))
+	}
+
+	// once the first chunk was sent the error goes to the caller, the backup is not asked
+	backup := replayModel(t, replay.Reply("hello"))
+	cm = newTestChain(t, map[string]config.Model{
+		"primary": replayModel(t, replay.Turn{Chunks: []*schema.Message{schema.AssistantMessage("hel", nil)}, Error: "connection reset"}),
+		"backup":  backup,
+	}, "primary", "backup")
+	sr, err = cm.Stream(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schema.ConcatMessageStream(sr); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestFallbackAllMembersFail(t *testing.T) {
+	cm := newTestChain(t, map[string]config.Model{
+		"primary": {Provider: "broken", ModelID: "qwen"},
+		"backup":  replayModel(t, replay.Fail("overloaded")),
+	}, "primary", "backup")
+	_, err := cm.Stream(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err == nil || !strings.Contains(err.Error(), "primary: 429") || !strings.Contains(err.Error(), "backup: replay: overloaded") {
+		t.Errorf("stream err = %v", err)
+	}
+	if _, err := cm.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")}); err == nil {
+		t.Error("expected generate to fail when every member fails")
+	}
+}
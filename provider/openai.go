@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+)
+
+const (
+	defaultOllamaBaseURL = "http://localhost:11434/v1"
+	defaultAzureVersion  = "2024-10-21"
+)
+
+// newOpenAI 适用于 OpenAI 及所有兼容其接口的服务(vLLM, LM Studio, llama.cpp 等)
+func newOpenAI(ctx context.Context, cfg config.Model) (ChatModel, error) {
+	cm, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		BaseURL: cfg.BaseURL,
+		APIKey:  cfg.Token,
+		Model:   cfg.ModelID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &capable{
+		ToolCallingChatModel: cm,
+		caps: withCapabilities(Capabilities{
+			ToolCalling: true,
+			Vision:      true,
+			MaxContext:  128000,
+		}, cfg.Capabilities),
+	}, nil
+}
+
+func newAzure(ctx context.Context, cfg config.Model) (ChatModel, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("azure provider requires base_url")
+	}
+	apiVersion := cfg.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAzureVersion
+	}
+	cm, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		ByAzure:    true,
+		BaseURL:    cfg.BaseURL,
+		APIVersion: apiVersion,
+		APIKey:     cfg.Token,
+		Model:      cfg.ModelID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &capable{
+		ToolCallingChatModel: cm,
+		caps: withCapabilities(Capabilities{
+			ToolCalling: true,
+			Vision:      true,
+			MaxContext:  128000,
+		}, cfg.Capabilities),
+	}, nil
+}
+
+// newOllama 使用 ollama 提供的 OpenAI 兼容接口
+func newOllama(ctx context.Context, cfg config.Model) (ChatModel, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+	token := cfg.Token
+	if token == "" {
+		// ollama 不校验 key, 但 OpenAI 客户端要求非空
+		token = "ollama"
+	}
+	cm, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		BaseURL: baseURL,
+		APIKey:  token,
+		Model:   cfg.ModelID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &capable{
+		ToolCallingChatModel: cm,
+		caps: withCapabilities(Capabilities{
+			ToolCalling: true,
+			Vision:      false,
+			MaxContext:  8192,
+		}, cfg.Capabilities),
+	}, nil
+}
@@ -0,0 +1,116 @@
+// Package provider 屏蔽不同模型提供方的差异, agent 只依赖这里返回的 ChatModel
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/provider/replay"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	OpenAI    = "openai"
+	Azure     = "azure"
+	Anthropic = "anthropic"
+	Ollama    = "ollama"
+	Replay    = "replay"
+)
+
+// Capabilities 描述模型具备的能力, agent 据此调整自身行为
+type Capabilities struct {
+	// ToolCalling 是否支持工具调用
+	ToolCalling bool
+	// Vision 是否支持图片输入
+	Vision bool
+	// MaxContext 上下文窗口大小(token), 0 表示未知
+	MaxContext int
+}
+
+// ChatModel 是带有能力声明的 model.ToolCallingChatModel
+type ChatModel interface {
+	model.ToolCallingChatModel
+	Capabilities() Capabilities
+}
+
+// Factory 根据配置创建某个提供方的模型
+type Factory func(ctx context.Context, cfg config.Model) (ChatModel, error)
+
+var factories = map[string]Factory{
+	OpenAI:    newOpenAI,
+	Azure:     newAzure,
+	Anthropic: newAnthropic,
+	Ollama:    newOllama,
+	Replay:    newReplay,
+}
+
+// Register 注册新的模型提供方, 同名提供方会被覆盖
+func Register(name string, f Factory) {
+	factories[strings.ToLower(name)] = f
+}
+
+// Names 返回所有已注册的提供方名称
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 根据 agent 配置创建模型, provider 为空时视为 OpenAI 兼容接口
+func New(ctx context.Context, cfg config.Model) (ChatModel, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if name == "" {
+		name = OpenAI
+	}
+	f, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, available: %s", cfg.Provider, strings.Join(Names(), ", "))
+	}
+	cm, err := f(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create %s model: %w", name, err)
+	}
+	if cfg.Record != "" {
+		cm = &capable{ToolCallingChatModel: replay.NewRecorder(cm, cfg.Record), caps: cm.Capabilities()}
+	}
+	return cm, nil
+}
+
+// withCapabilities 用配置中的显式声明覆盖默认能力
+func withCapabilities(def Capabilities, override config.Capabilities) Capabilities {
+	if override.ToolCalling != nil {
+		def.ToolCalling = *override.ToolCalling
+	}
+	if override.Vision != nil {
+		def.Vision = *override.Vision
+	}
+	if override.MaxContext > 0 {
+		def.MaxContext = override.MaxContext
+	}
+	return def
+}
+
+// capable 为 model.ToolCallingChatModel 附加能力声明
+type capable struct {
+	model.ToolCallingChatModel
+	caps Capabilities
+}
+
+func (c *capable) Capabilities() Capabilities {
+	return c.caps
+}
+
+func (c *capable) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	m, err := c.ToolCallingChatModel.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &capable{ToolCallingChatModel: m, caps: c.caps}, nil
+}
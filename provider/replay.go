@@ -0,0 +1,26 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/provider/replay"
+)
+
+// newReplay 回放录制好的会话, 不访问任何模型服务
+func newReplay(ctx context.Context, cfg config.Model) (ChatModel, error) {
+	if cfg.Fixture == "" {
+		return nil, errors.New("replay provider requires fixture")
+	}
+	fx, err := replay.Load(cfg.Fixture)
+	if err != nil {
+		return nil, err
+	}
+	return &capable{
+		ToolCallingChatModel: replay.NewChatModel(fx),
+		caps: withCapabilities(Capabilities{
+			ToolCalling: true,
+		}, cfg.Capabilities),
+	}, nil
+}
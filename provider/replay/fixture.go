@@ -0,0 +1,123 @@
+// Package replay 提供按脚本回放应答的 ChatModel, 以及把真实会话录制为脚本的 Recorder
+// 用于在没有模型服务的情况下离线测试 agent 循环、工具与子 agent 委派
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Fixture 是一次会话中模型的全部应答, 按调用顺序排列
+type Fixture struct {
+	Turns []Turn `json:"turns"`
+}
+
+// Turn 是模型的一次应答
+type Turn struct {
+	// Request 录制时模型收到的输入, 回放时仅用于排查问题
+	Request []*schema.Message `json:"request,omitempty"`
+	// Chunks 流式输出的分片, 非流式调用时返回拼接后的消息
+	Chunks []*schema.Message `json:"chunks"`
+	// Error 不为空时本次调用返回该错误
+	Error string `json:"error,omitempty"`
+}
+
+// Load 从 JSON 文件中读取脚本
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+	var fx Fixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	return &fx, nil
+}
+
+// Save 把脚本写入 JSON 文件
+func (fx *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal fixture: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create fixture dir: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	return nil
+}
+
+// Reply 构造一次应答, content 会被切分成多个分片以模拟流式输出
+func Reply(content string, toolCalls ...schema.ToolCall) Turn {
+	var chunks []*schema.Message
+	for _, part := range split(content, 8) {
+		chunks = append(chunks, &schema.Message{Role: schema.Assistant, Content: part})
+	}
+	for i, tc := range toolCalls {
+		idx := i
+		tc.Index = &idx
+		if tc.Type == "" {
+			tc.Type = "function"
+		}
+		if tc.ID == "" {
+			tc.ID = fmt.Sprintf("call_%d", i)
+		}
+		chunks = append(chunks, &schema.Message{Role: schema.Assistant, ToolCalls: []schema.ToolCall{tc}})
+	}
+	if len(chunks) == 0 {
+		chunks = append(chunks, &schema.Message{Role: schema.Assistant})
+	}
+	return Turn{Chunks: chunks}
+}
+
+// Fail 构造一次返回错误的应答
+func Fail(err string) Turn {
+	return Turn{Error: err}
+}
+
+// ToolCall 构造一次工具调用, args 会被编码为 JSON
+func ToolCall(name string, args any) schema.ToolCall {
+	var arguments string
+	switch v := args.(type) {
+	case nil:
+		arguments = "{}"
+	case string:
+		arguments = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			panic(fmt.Sprintf("replay: marshal tool call args: %v", err))
+		}
+		arguments = string(data)
+	}
+	return schema.ToolCall{
+		Type:     "function",
+		Function: schema.FunctionCall{Name: name, Arguments: arguments},
+	}
+}
+
+// split 按 rune 数切分字符串
+func split(s string, size int) []string {
+	var parts []string
+	for len(s) > 0 {
+		n, i := 0, 0
+		for i < len(s) && n < size {
+			_, w := utf8.DecodeRuneInString(s[i:])
+			i += w
+			n++
+		}
+		parts = append(parts, s[:i])
+		s = s[i:]
+	}
+	return parts
+}
@@ -0,0 +1,124 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrExhausted 脚本中的应答已全部回放完毕
+var ErrExhausted = errors.New("replay: no more recorded turns")
+
+var _ model.ToolCallingChatModel = (*ChatModel)(nil)
+
+// ChatModel 按顺序回放脚本中的应答, 每次 Generate/Stream 消耗一个 Turn
+type ChatModel struct {
+	state *state
+	tools []*schema.ToolInfo
+}
+
+// state 在 WithTools 派生出的实例之间共享, 保证回放顺序一致
+type state struct {
+	mu    sync.Mutex
+	turns []Turn
+	next  int
+	calls []Call
+}
+
+// Call 记录模型收到的一次调用
+type Call struct {
+	Input []*schema.Message
+	Tools []*schema.ToolInfo
+}
+
+// NewChatModel 创建回放模型
+func NewChatModel(fx *Fixture) *ChatModel {
+	return &ChatModel{state: &state{turns: fx.Turns}}
+}
+
+// NewScripted 使用给定的应答创建回放模型
+func NewScripted(turns ...Turn) *ChatModel {
+	return NewChatModel(&Fixture{Turns: turns})
+}
+
+func (cm *ChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return &ChatModel{state: cm.state, tools: tools}, nil
+}
+
+// BindTools 实现 model.ChatModel, 便于替换旧的模型实现
+func (cm *ChatModel) BindTools(tools []*schema.ToolInfo) error {
+	cm.tools = tools
+	return nil
+}
+
+func (cm *ChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	turn, err := cm.take(input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if turn.Error != "" {
+		return nil, turn.err()
+	}
+	return schema.ConcatMessages(turn.Chunks)
+}
+
+func (cm *ChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	turn, err := cm.take(input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if turn.Error != "" && len(turn.Chunks) == 0 {
+		return nil, turn.err()
+	}
+	sr, sw := schema.Pipe[*schema.Message](len(turn.Chunks) + 1)
+	for _, c := range turn.Chunks {
+		// 复制分片, 避免调用方修改脚本内容
+		cp := *c
+		sw.Send(&cp, nil)
+	}
+	// 录制时流在中途出错, 回放时同样在输出分片后返回错误
+	if turn.Error != "" {
+		sw.Send(nil, turn.err())
+	}
+	sw.Close()
+	return sr, nil
+}
+
+// Calls 返回模型收到的所有调用, 用于断言 agent 发送给模型的内容
+func (cm *ChatModel) Calls() []Call {
+	cm.state.mu.Lock()
+	defer cm.state.mu.Unlock()
+	return append([]Call(nil), cm.state.calls...)
+}
+
+// Remaining 返回尚未回放的应答数量
+func (cm *ChatModel) Remaining() int {
+	cm.state.mu.Lock()
+	defer cm.state.mu.Unlock()
+	return len(cm.state.turns) - cm.state.next
+}
+
+func (cm *ChatModel) take(input []*schema.Message, opts ...model.Option) (Turn, error) {
+	options := model.GetCommonOptions(&model.Options{Tools: cm.tools}, opts...)
+	s := cm.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{
+		Input: append([]*schema.Message(nil), input...),
+		Tools: options.Tools,
+	})
+	if s.next >= len(s.turns) {
+		return Turn{}, ErrExhausted
+	}
+	turn := s.turns[s.next]
+	s.next++
+	return turn, nil
+}
+
+func (t Turn) err() error {
+	return fmt.Errorf("replay: %s", t.Error)
+}
@@ -0,0 +1,131 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+var _ model.ToolCallingChatModel = (*Recorder)(nil)
+
+// Recorder 代理真实模型, 并把每次调用的输入与应答追加写入脚本文件
+type Recorder struct {
+	inner model.ToolCallingChatModel
+	log   *recording
+}
+
+// recording 在 WithTools 派生出的实例之间共享
+type recording struct {
+	mu   sync.Mutex
+	path string
+	fx   Fixture
+}
+
+var (
+	claimedMu sync.Mutex
+	// claimed 本进程中正在录制的文件
+	claimed = make(map[string]bool)
+)
+
+// NewRecorder 创建录制模型, 每完成一次调用就把完整脚本写入 path
+// path 已被其他 Recorder 使用时(例如沿用同一配置的子 agent)依次改用 name-2.json、name-3.json 等文件, 避免互相覆盖
+func NewRecorder(inner model.ToolCallingChatModel, path string) *Recorder {
+	return &Recorder{inner: inner, log: &recording{path: claim(path)}}
+}
+
+// Path 返回录制写入的文件
+func (r *Recorder) Path() string {
+	return r.log.path
+}
+
+// claim 返回尚未被占用的录制文件并占用它
+func claim(path string) string {
+	claimedMu.Lock()
+	defer claimedMu.Unlock()
+	key := path
+	if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	}
+	if !claimed[key] {
+		claimed[key] = true
+		return path
+	}
+	ext := filepath.Ext(path)
+	base, keyBase := strings.TrimSuffix(path, ext), strings.TrimSuffix(key, ext)
+	for i := 2; ; i++ {
+		if k := fmt.Sprintf("%s-%d%s", keyBase, i, ext); !claimed[k] {
+			claimed[k] = true
+			p := fmt.Sprintf("%s-%d%s", base, i, ext)
+			slog.Info("recording file is in use, recording to another file", "path", path, "file", p)
+			return p
+		}
+	}
+}
+
+func (r *Recorder) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	inner, err := r.inner.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{inner: inner, log: r.log}, nil
+}
+
+func (r *Recorder) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	msg, err := r.inner.Generate(ctx, input, opts...)
+	turn := Turn{Request: input}
+	if err != nil {
+		turn.Error = err.Error()
+	} else {
+		turn.Chunks = []*schema.Message{msg}
+	}
+	r.log.append(turn)
+	return msg, err
+}
+
+func (r *Recorder) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, err := r.inner.Stream(ctx, input, opts...)
+	if err != nil {
+		r.log.append(Turn{Request: input, Error: err.Error()})
+		return nil, err
+	}
+	out, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sr.Close()
+		defer sw.Close()
+		turn := Turn{Request: input}
+		defer func() { r.log.append(turn) }()
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				turn.Error = err.Error()
+				sw.Send(nil, err)
+				return
+			}
+			cp := *chunk
+			turn.Chunks = append(turn.Chunks, &cp)
+			if closed := sw.Send(chunk, nil); closed {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (rec *recording) append(turn Turn) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.fx.Turns = append(rec.fx.Turns, turn)
+	if err := rec.fx.Save(rec.path); err != nil {
+		slog.Error("save recording failed", "path", rec.path, "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package replay
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+	inner := NewScripted(
+		Reply("我看看目录", ToolCall("dir_reader", map[string]string{"dirname": "."})),
+		Reply("看完了", ToolCall("bell", nil)),
+		Fail("rate limited"),
+	)
+	rec := NewRecorder(inner, path)
+	bound, err := rec.WithTools([]*schema.ToolInfo{{Name: "bell"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	question := []*schema.Message{schema.UserMessage("看看当前目录")}
+	sr, err := bound.Stream(ctx, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := schema.ConcatMessageStream(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := bound.Generate(ctx, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bound.Stream(ctx, question); err == nil {
+		t.Fatal("expected the recorded error")
+	}
+
+	fx, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fx.Turns) != 3 || len(fx.Turns[0].Request) != 1 || fx.Turns[2].Error != "replay: rate limited" {
+		t.Fatalf("fixture = %+v", fx)
+	}
+
+	// 回放录制的脚本得到与真实模型相同的应答
+	player := NewChatModel(fx)
+	sr, err = player.Stream(ctx, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := schema.ConcatMessageStream(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Content != first.Content || len(replayed.ToolCalls) != 1 || replayed.ToolCalls[0].Function.Arguments != first.ToolCalls[0].Function.Arguments {
+		t.Errorf("replayed %+v, recorded %+v", replayed, first)
+	}
+	if msg, err := player.Generate(ctx, question); err != nil || msg.Content != second.Content {
+		t.Errorf("replayed %+v, %v", msg, err)
+	}
+	if _, err := player.Generate(ctx, question); err == nil {
+		t.Error("expected the recorded error to be replayed")
+	}
+	if _, err := player.Generate(ctx, question); err != ErrExhausted {
+		t.Errorf("err = %v, want ErrExhausted", err)
+	}
+}
+
+func TestRecordersDoNotShareFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	parent := NewRecorder(NewScripted(Reply("parent")), path)
+	child := NewRecorder(NewScripted(Reply("child")), path)
+	if parent.Path() != path || child.Path() != filepath.Join(filepath.Dir(path), "session-2.json") {
+		t.Fatalf("paths = %s, %s", parent.Path(), child.Path())
+	}
+	for _, r := range []*Recorder{parent, child} {
+		if _, err := r.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for want, p := range map[string]string{"parent": parent.Path(), "child": child.Path()} {
+		fx, err := Load(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fx.Turns) != 1 || fx.Turns[0].Chunks[0].Content != want {
+			t.Errorf("%s = %+v", p, fx.Turns)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package repl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory 输入历史最多保留的条数
+const maxHistory = 1000
+
+// History 是持久化的输入历史, 每条消息以 JSON 字符串的形式占文件的一行
+// 它实现了 term.History, 但行编辑器记录的单行会被忽略, 消息读完后由 Reader 统一记录
+type History struct {
+	path    string
+	entries []string
+}
+
+// DefaultHistoryFile 返回默认的历史文件 ~/.cosmica_history
+func DefaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cosmica_history")
+}
+
+// LoadHistory 读取历史文件, 文件不存在时从空历史开始; path 为空时不持久化
+func LoadHistory(path string) (*History, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("expand %s: %w", path, err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry string
+		// 跳过损坏的行, 不影响其余历史
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry != "" {
+			h.entries = append(h.entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		if err := h.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Record 记录一条消息并追加到历史文件, 空消息与重复的上一条不记录
+func (h *History) Record(msg string) error {
+	if strings.TrimSpace(msg) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == msg) {
+		return nil
+	}
+	h.entries = append(h.entries, msg)
+	if h.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+	defer f.Close()
+	return writeEntries(f, []string{msg})
+}
+
+// rewrite 用内存中的历史覆盖文件
+func (h *History) rewrite() error {
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("rewrite history: %w", err)
+	}
+	defer f.Close()
+	return writeEntries(f, h.entries)
+}
+
+func writeEntries(f *os.File, entries []string) error {
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		data, _ := json.Marshal(e)
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	return nil
+}
+
+// Add 忽略行编辑器逐行的记录, 粘贴的多行内容以整条消息记录
+func (h *History) Add(string) {}
+
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// At 返回倒数第 idx 条消息, 行编辑器只能编辑单行, 多行消息以空格连接
+func (h *History) At(idx int) string {
+	return strings.ReplaceAll(h.entries[len(h.entries)-1-idx], "\n", " ")
+}
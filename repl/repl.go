@@ -0,0 +1,261 @@
+// Package repl 为逐行对话读取用户输入: 终端上支持行编辑、持久化的输入历史与括号粘贴,
+// 多行粘贴合并为一条消息; 以 """ 开始的块模式或 $EDITOR 可以编写更长的问题
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	// BlockDelimiter 单独一行的 """ 开始或结束多行输入
+	BlockDelimiter = `"""`
+	// continuationPrompt 多行输入未结束时的提示符
+	continuationPrompt = "... "
+)
+
+// ErrInterrupted 用户在输入时按下了 ctrl+c, 已输入的内容被丢弃
+var ErrInterrupted = errors.New("interrupted")
+
+// Reader 读取用户输入的消息
+type Reader struct {
+	prompt  string
+	out     io.Writer
+	history *History
+
+	// 以下字段仅终端使用, fd 为 -1 时不切换终端模式
+	term *term.Terminal
+	fd   int
+	keys *interruptReader
+	// read 终端已经读到的行数, 与 keys 中记录的回车序号对应
+	read int
+
+	// lines 不是终端时逐行读取输入, 整个会话共用一个缓冲
+	lines *bufio.Reader
+}
+
+// NewReader 从 in 读取输入, in 是终端时启用行编辑并以 h 作为输入历史, h 可以为 nil
+// 不是终端时(例如管道)输入不记入历史
+func NewReader(in io.Reader, out io.Writer, prompt string, h *History) *Reader {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return newTerminalReader(struct {
+			io.Reader
+			io.Writer
+		}{in, out}, int(f.Fd()), prompt, h)
+	}
+	return &Reader{prompt: prompt, out: out, lines: bufio.NewReader(in), fd: -1}
+}
+
+func newTerminalReader(rw io.ReadWriter, fd int, prompt string, h *History) *Reader {
+	if h == nil {
+		h = &History{}
+	}
+	keys := &interruptReader{r: rw}
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{keys, rw}, prompt)
+	t.History = h
+	return &Reader{prompt: prompt, out: rw, history: h, term: t, fd: fd, keys: keys}
+}
+
+// SetCompleter 设置按 tab 时的补全函数, pos 为光标在 line 中的字节下标, 仅终端上生效
+func (r *Reader) SetCompleter(complete func(line string, pos int) (newLine string, newPos int, ok bool)) {
+	if r.term == nil {
+		return
+	}
+	r.term.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return complete(line, pos)
+	}
+}
+
+// ReadMessage 读取一条消息, 输入结束时返回 io.EOF, 在终端上按 ctrl+c 丢弃正在输入的消息并返回 ErrInterrupted
+// 粘贴的多行内容与随后输入的一行合并为一条消息, 在 """ 块中换行不会发送消息
+func (r *Reader) ReadMessage() (string, error) {
+	readLine := r.readPipeLine
+	if r.term != nil {
+		restore, err := r.makeRaw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+		r.term.SetBracketedPasteMode(true)
+		defer r.term.SetBracketedPasteMode(false)
+		r.term.SetPrompt(r.prompt)
+		readLine = r.readTerminalLine
+	} else {
+		io.WriteString(r.out, r.prompt)
+	}
+
+	msg, err := collect(readLine)
+	if err != nil {
+		return "", err
+	}
+	if r.history != nil {
+		if err := r.history.Record(msg); err != nil {
+			slog.Warn("save input history failed", "error", err)
+		}
+	}
+	return msg, nil
+}
+
+// Confirm 显示 question 并读取一行回答, 回答 y 或 yes 时返回 true, 按 ctrl+c 视为拒绝
+func (r *Reader) Confirm(question string) (bool, error) {
+	prompt := question + " [y/N] "
+	var (
+		answer string
+		err    error
+	)
+	if r.term != nil {
+		restore, rawErr := r.makeRaw()
+		if rawErr != nil {
+			return false, rawErr
+		}
+		defer restore()
+		r.term.SetPrompt(prompt)
+		answer, _, err = r.readTerminalLine()
+	} else {
+		io.WriteString(r.out, prompt)
+		answer, _, err = r.readPipeLine()
+	}
+	if errors.Is(err, ErrInterrupted) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// makeRaw 只在读取输入时进入原始模式, 回答仍按普通模式输出
+func (r *Reader) makeRaw() (restore func(), err error) {
+	if r.fd < 0 {
+		return func() {}, nil
+	}
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() { term.Restore(r.fd, state) }, nil
+}
+
+// collect 按行读取直到组成一条消息
+func collect(readLine func() (line string, pasted bool, err error)) (string, error) {
+	var lines []string
+	block := false
+	for {
+		line, pasted, err := readLine()
+		if err != nil {
+			// 输入在块或粘贴中途结束时发送已读到的内容
+			if errors.Is(err, io.EOF) && len(lines) > 0 {
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
+		}
+		switch {
+		case pasted:
+			lines = append(lines, line)
+		case strings.TrimSpace(line) == BlockDelimiter:
+			if block {
+				return strings.Join(lines, "\n"), nil
+			}
+			block = true
+		case block:
+			lines = append(lines, line)
+		default:
+			if line != "" || len(lines) > 0 {
+				lines = append(lines, line)
+			}
+			return strings.Join(lines, "\n"), nil
+		}
+	}
+}
+
+func (r *Reader) readTerminalLine() (string, bool, error) {
+	line, err := r.term.ReadLine()
+	r.term.SetPrompt(continuationPrompt)
+	if err == nil || errors.Is(err, term.ErrPasteIndicator) {
+		r.read++
+		if r.keys.interrupted(r.read) {
+			return "", false, ErrInterrupted
+		}
+	}
+	if errors.Is(err, term.ErrPasteIndicator) {
+		return line, true, nil
+	}
+	return line, false, err
+}
+
+// clearLine 清空当前行并回车的按键: ctrl+a 回到行首, ctrl+k 删除到行尾
+var clearLine = []byte{1, 11, '\r'}
+
+// interruptReader 把终端输入中的 ctrl+c 换成 clearLine, 并记录这些回车的序号
+// 原始模式下 ctrl+c 不再产生 SIGINT, term.Terminal 读到它时与 ctrl+d 一样返回 io.EOF, 还会丢掉同一次读到的后续按键
+type interruptReader struct {
+	r   io.Reader
+	buf []byte
+	// enters 已读到的回车数, interrupts 为其中由 ctrl+c 换成的回车的序号, 递增
+	enters     int
+	interrupts []int
+	cr         bool
+}
+
+func (ir *interruptReader) Read(p []byte) (int, error) {
+	if len(ir.buf) == 0 {
+		n, err := ir.r.Read(p)
+		if n == 0 {
+			return 0, err
+		}
+		for _, b := range p[:n] {
+			switch {
+			case b == 3:
+				ir.buf = append(ir.buf, clearLine...)
+				ir.enters++
+				ir.interrupts = append(ir.interrupts, ir.enters)
+			case b == '\r' || b == '\n' && !ir.cr:
+				// term.Terminal 把 \r\n 当作一次回车
+				ir.buf = append(ir.buf, b)
+				ir.enters++
+			default:
+				ir.buf = append(ir.buf, b)
+			}
+			ir.cr = b == '\r' || b == 3
+		}
+	}
+	n := copy(p, ir.buf)
+	ir.buf = ir.buf[n:]
+	return n, nil
+}
+
+// interrupted 报告第 line 个回车是否由 ctrl+c 换成
+func (ir *interruptReader) interrupted(line int) bool {
+	for len(ir.interrupts) > 0 && ir.interrupts[0] < line {
+		ir.interrupts = ir.interrupts[1:]
+	}
+	if len(ir.interrupts) > 0 && ir.interrupts[0] == line {
+		ir.interrupts = ir.interrupts[1:]
+		return true
+	}
+	return false
+}
+
+func (r *Reader) readPipeLine() (string, bool, error) {
+	line, err := r.lines.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", false, err
+	}
+	return strings.TrimRight(line, "\r\n"), false, nil
+}
@@ -0,0 +1,157 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, r *Reader) []string {
+	t.Helper()
+	var msgs []string
+	for {
+		msg, err := r.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			return msgs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestPipeBlocks(t *testing.T) {
+	in := "hello\n\n\"\"\"\nline1\n\n  line2\n\"\"\"\nlast"
+	var out bytes.Buffer
+	got := readAll(t, NewReader(strings.NewReader(in), &out, "> ", nil))
+	want := []string{"hello", "", "line1\n\n  line2", "last"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if strings.Count(out.String(), "> ") != 5 {
+		t.Errorf("prompts = %q", out.String())
+	}
+}
+
+// fakeTerminal 以固定的按键作为输入, 输出写入缓冲
+type fakeTerminal struct {
+	io.Reader
+	out bytes.Buffer
+}
+
+func (f *fakeTerminal) Write(p []byte) (int, error) {
+	return f.out.Write(p)
+}
+
+func TestTerminalPasteAndHistory(t *testing.T) {
+	h, err := LoadHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := "\x1b[200~panic: boom\rgoroutine 1\r\x1b[201~what happened?\r" + // 粘贴两行后输入一行
+		"\x1b[A\r" // 上箭头取回上一条消息
+	r := newTerminalReader(&fakeTerminal{Reader: strings.NewReader(keys)}, -1, "> ", h)
+	got := readAll(t, r)
+	want := []string{"panic: boom\ngoroutine 1\nwhat happened?", "panic: boom goroutine 1 what happened?"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if h.Len() != 2 {
+		t.Errorf("history has %d entries, want one per message", h.Len())
+	}
+}
+
+func TestTerminalCtrlC(t *testing.T) {
+	// ctrl+c 丢弃正在输入的行与未结束的块, 同一次读到的后续按键照常处理, ctrl+d 结束输入
+	keys := "draft\x03first\r\"\"\"\rin block\r\x03second\r\x04"
+	r := newTerminalReader(&fakeTerminal{Reader: strings.NewReader(keys)}, -1, "> ", nil)
+	var got []string
+	for {
+		msg, err := r.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, ErrInterrupted) {
+			got = append(got, "^C")
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg)
+	}
+	want := []string{"^C", "first", "^C", "second"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"one", "two\nlines", "two\nlines", " "} {
+		if err := h.Record(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err = LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != 2 || h.At(0) != "two lines" || h.At(1) != "one" {
+		t.Errorf("reloaded history = %q", h.entries)
+	}
+
+	var b strings.Builder
+	for i := range maxHistory + 10 {
+		fmt.Fprintf(&b, "%q\n", fmt.Sprint(i))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = LoadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != maxHistory || h.At(h.Len()-1) != "10" {
+		t.Errorf("history not truncated: %d entries, oldest %q", h.Len(), h.At(h.Len()-1))
+	}
+}
+
+func TestEdit(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i s/draft/edited/")
+	got, err := Edit("a draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "a edited" {
+		t.Errorf("Edit = %q", got)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	r := newTerminalReader(&fakeTerminal{Reader: strings.NewReader("y\rno\r\x03")}, -1, "> ", nil)
+	for _, want := range []bool{true, false, false} {
+		if got, err := r.Confirm("run ls?"); err != nil || got != want {
+			t.Errorf("Confirm = %v, %v, want %v", got, err, want)
+		}
+	}
+
+	var out bytes.Buffer
+	r = NewReader(strings.NewReader("yes\n"), &out, "> ", nil)
+	if ok, err := r.Confirm("run ls?"); !ok || err != nil || out.String() != "run ls? [y/N] " {
+		t.Errorf("Confirm = %v, %v, prompt %q", ok, err, out.String())
+	}
+	if _, err := r.Confirm("run ls?"); !errors.Is(err, io.EOF) {
+		t.Errorf("Confirm at the end of input = %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+// Package search 提供本地检索用到的分词、BM25 与向量相似度, 不依赖外部服务,
+// 长期记忆与代码检索在没有向量模型时都退回到 BM25
+package search
+
+import (
+	"maps"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 的参数, 使用常见的默认值
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Hit 是一条检索结果
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Rank 按分数从高到低排序, 分数相同时按 ID 排序, limit 大于 0 时只保留前 limit 条
+func Rank(hits []Hit, limit int) []Hit {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Tokenize 把文本切分为检索用的词: 英文与数字按单词切分并转为小写, 标识符额外拆出
+// 驼峰与下划线分隔的部分(HTTPServer 得到 httpserver、http 与 server), 汉字按相邻两字切分
+func Tokenize(text string) []string {
+	var tokens []string
+	var word, han []rune
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		tokens = append(tokens, strings.ToLower(string(word)))
+		if parts := splitIdent(word); len(parts) > 1 {
+			tokens = append(tokens, parts...)
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// splitIdent 按下划线与大小写变化拆分标识符, 返回小写的各部分
+func splitIdent(word []rune) []string {
+	var parts []string
+	start := 0
+	emit := func(end int) {
+		if end > start {
+			parts = append(parts, strings.ToLower(string(word[start:end])))
+		}
+	}
+	for i, r := range word {
+		switch {
+		case r == '_':
+			emit(i)
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			prev := word[i-1]
+			// fooBar 在 B 前切分, HTTPServer 在 S 前切分
+			nextLower := i+1 < len(word) && unicode.IsLower(word[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				emit(i)
+				start = i
+			}
+		}
+	}
+	emit(len(word))
+	return parts
+}
+
+// BM25 是内存中的 BM25 倒排统计, 文档以 ID 标识, 不是并发安全的
+type BM25 struct {
+	docs     map[string]bm25Doc
+	df       map[string]int
+	totalLen int
+}
+
+type bm25Doc struct {
+	tf     map[string]int
+	length int
+}
+
+// NewBM25 创建空的 BM25 索引
+func NewBM25() *BM25 {
+	return &BM25{docs: make(map[string]bm25Doc), df: make(map[string]int)}
+}
+
+// Clone 返回索引的副本, 修改副本不影响原索引
+func (x *BM25) Clone() *BM25 {
+	// 文档的词频加入后不再修改, 可以共用
+	return &BM25{docs: maps.Clone(x.docs), df: maps.Clone(x.df), totalLen: x.totalLen}
+}
+
+// Len 返回索引中的文档数
+func (x *BM25) Len() int {
+	return len(x.docs)
+}
+
+// Add 加入文档, ID 已存在时替换原文档
+func (x *BM25) Add(id, text string) {
+	x.Remove(id)
+	tokens := Tokenize(text)
+	doc := bm25Doc{tf: make(map[string]int), length: len(tokens)}
+	for _, t := range tokens {
+		if doc.tf[t] == 0 {
+			x.df[t]++
+		}
+		doc.tf[t]++
+	}
+	x.docs[id] = doc
+	x.totalLen += doc.length
+}
+
+// Remove 删除文档, ID 不存在时什么也不做
+func (x *BM25) Remove(id string) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for t := range doc.tf {
+		if x.df[t]--; x.df[t] == 0 {
+			delete(x.df, t)
+		}
+	}
+	x.totalLen -= doc.length
+	delete(x.docs, id)
+}
+
+// Search 返回与 query 相关的文档, 只包含至少命中一个词的文档
+func (x *BM25) Search(query string, limit int) []Hit {
+	if len(x.docs) == 0 {
+		return nil
+	}
+	terms := make(map[string]bool)
+	for _, t := range Tokenize(query) {
+		terms[t] = true
+	}
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / n
+	var hits []Hit
+	for id, doc := range x.docs {
+		var score float64
+		for t := range terms {
+			tf := float64(doc.tf[t])
+			if tf == 0 {
+				continue
+			}
+			df := float64(x.df[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.length)/avgLen))
+		}
+		if score > 0 {
+			hits = append(hits, Hit{ID: id, Score: score})
+		}
+	}
+	return Rank(hits, limit)
+}
+
+// Cosine 返回两个向量的余弦相似度, 长度不同或为零向量时返回 0
+func Cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for text, want := range map[string][]string{
+		"Hello, World!":     {"hello", "world"},
+		"HTTPServer":        {"httpserver", "http", "server"},
+		"read_file fooBar2": {"read_file", "read", "file", "foobar2", "foo", "bar2"},
+		"数据库密码在vault里":      {"数据", "据库", "库密", "密码", "码在", "vault", "里"},
+		"  ":                nil,
+		"go test ./...":     {"go", "test"},
+	} {
+		if got := Tokenize(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestBM25(t *testing.T) {
+	x := NewBM25()
+	x.Add("a", "the database password is stored in vault")
+	x.Add("b", "the user prefers tabs over spaces")
+	x.Add("c", "deploy with make deploy, the database runs on port 5432")
+	x.Add("d", "用户喜欢简短的回答")
+
+	hits := x.Search("where is the database password", 0)
+	if len(hits) != 3 || hits[0].ID != "a" || hits[1].ID != "c" {
+		t.Errorf("hits = %+v", hits)
+	}
+	if hits := x.Search("简短回答", 1); len(hits) != 1 || hits[0].ID != "d" {
+		t.Errorf("chinese hits = %+v", hits)
+	}
+	if hits := x.Search("kubernetes", 0); len(hits) != 0 {
+		t.Errorf("unrelated query matched %+v", hits)
+	}
+
+	clone := x.Clone()
+	x.Remove("a")
+	x.Add("c", "deploy with make deploy")
+	if hits := x.Search("database", 0); len(hits) != 0 || x.Len() != 3 {
+		t.Errorf("after remove and replace: hits %+v, len %d", hits, x.Len())
+	}
+	// 修改原索引不影响副本
+	if hits := clone.Search("database", 0); len(hits) != 2 || clone.Len() != 4 {
+		t.Errorf("clone: hits %+v, len %d", hits, clone.Len())
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := Cosine([]float64{1, 0}, []float64{1, 0}); math.Abs(got-1) > 1e-9 {
+		t.Errorf("same direction = %v", got)
+	}
+	if got := Cosine([]float64{1, 0}, []float64{0, 2}); got != 0 {
+		t.Errorf("orthogonal = %v", got)
+	}
+	if got := Cosine([]float64{1}, []float64{1, 2}); got != 0 {
+		t.Errorf("different length = %v", got)
+	}
+}
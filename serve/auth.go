@@ -0,0 +1,56 @@
+package serve
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TokenEnv 未通过 -token 指定时从该环境变量读取访问令牌
+const TokenEnv = "COSMICA_TOKEN"
+
+// ResolveToken 检查监听地址并返回访问令牌
+// 非回环地址(包括 ":8080" 这类监听所有网卡的地址)必须显式指定令牌; 回环地址未指定时随机生成一个
+func ResolveToken(addr, token string) (string, error) {
+	if token != "" {
+		return token, nil
+	}
+	if !isLoopback(addr) {
+		return "", fmt.Errorf("listen on %s: a token is required for non-loopback addresses, set -token or %s", addr, TokenEnv)
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// RequireToken 要求每个请求携带 Authorization: Bearer <token>
+func RequireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,46 @@
+package serve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bootun/cosmica/serve"
+)
+
+func TestResolveToken(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:8080", "localhost:8080", "[::1]:8080"} {
+		token, err := serve.ResolveToken(addr, "")
+		if err != nil || len(token) != 32 {
+			t.Errorf("%s: token %q, err %v", addr, token, err)
+		}
+	}
+	for _, addr := range []string{":8080", "0.0.0.0:8080", "192.168.1.2:8080"} {
+		if _, err := serve.ResolveToken(addr, ""); err == nil {
+			t.Errorf("%s: expected an error without a token", addr)
+		}
+		if token, err := serve.ResolveToken(addr, "secret"); err != nil || token != "secret" {
+			t.Errorf("%s: token %q, err %v", addr, token, err)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	h := serve.RequireToken("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Authorization %q: status %d, want %d", header, rec.Code, want)
+		}
+	}
+}
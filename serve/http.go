@@ -0,0 +1,278 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/schema"
+)
+
+// AgentFactory 创建名为 name 的 agent
+// observer 与 approver 必须传给 agent(例如 common.WithObserver 与 common.WithApprover), 服务据此转发输出并审批工具调用
+type AgentFactory func(ctx context.Context, name string, observer agent.Observer, approver agent.Approver) (agent.Agent, error)
+
+// HTTPOption 定制 HTTP 服务
+type HTTPOption func(*HTTPServer)
+
+// WithApproval 调用这些工具前需要客户端批准, "*" 表示所有工具
+func WithApproval(toolNames ...string) HTTPOption {
+	return func(s *HTTPServer) {
+		for _, name := range toolNames {
+			s.approval[name] = true
+			s.approval[tools.ModelName(name)] = true
+		}
+	}
+}
+
+// HTTPServer 通过 HTTP 提供会话接口, 助手输出与工具调用以 SSE 推送
+//
+//	POST   /v1/sessions                           创建会话 {"agent": "spaceman"}
+//	GET    /v1/sessions/{id}                      查询会话及对话历史
+//	DELETE /v1/sessions/{id}                      结束会话
+//	POST   /v1/sessions/{id}/messages             发送消息 {"content": "..."}, 在后台处理
+//	GET    /v1/sessions/{id}/events               订阅事件(SSE), 支持 Last-Event-ID 续传
+//	POST   /v1/sessions/{id}/approvals/{approval} 批准或拒绝工具调用 {"approve": true}
+//	POST   /v1/sessions/{id}/cancel               取消正在处理的消息
+//
+// 同时提供 OpenAI 兼容的 /v1/chat/completions 与 /v1/models, 每个 agent 对应一个模型
+type HTTPServer struct {
+	factory  AgentFactory
+	approval map[string]bool
+	agents   []string
+	mux      *http.ServeMux
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	nextID   int
+}
+
+// NewHTTPServer 创建 HTTP 服务, 每个会话通过 factory 创建独立的 agent
+func NewHTTPServer(factory AgentFactory, opts ...HTTPOption) *HTTPServer {
+	s := &HTTPServer{
+		factory:  factory,
+		approval: make(map[string]bool),
+		agents:   []string{agent.AgentSpaceman, agent.AgentNetizen},
+		mux:      http.NewServeMux(),
+		sessions: make(map[string]*session),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("POST /v1/sessions", s.createSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}", s.withSession(s.getSession))
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.withSession(s.deleteSession))
+	s.mux.HandleFunc("POST /v1/sessions/{id}/messages", s.withSession(s.postMessage))
+	s.mux.HandleFunc("GET /v1/sessions/{id}/events", s.withSession(s.streamEvents))
+	s.mux.HandleFunc("POST /v1/sessions/{id}/approvals/{approval}", s.withSession(s.approve))
+	s.mux.HandleFunc("POST /v1/sessions/{id}/cancel", s.withSession(s.cancel))
+	s.mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	s.mux.HandleFunc("GET /v1/models", s.models)
+	return s
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close 取消所有正在处理的消息并结束所有会话
+func (s *HTTPServer) Close() error {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.mu.Unlock()
+	var errs []error
+	for _, sess := range sessions {
+		errs = append(errs, sess.close())
+	}
+	return errors.Join(errs...)
+}
+
+type createSessionRequest struct {
+	Agent string `json:"agent"`
+}
+
+type sessionResponse struct {
+	ID       string            `json:"id"`
+	Agent    string            `json:"agent"`
+	Busy     bool              `json:"busy"`
+	Messages []*schema.Message `json:"messages,omitempty"`
+}
+
+func (s *HTTPServer) createSession(w http.ResponseWriter, r *http.Request) {
+	var req createSessionRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Agent == "" {
+		req.Agent = agent.AgentSpaceman
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.mu.Unlock()
+
+	sess := newSession(id, req.Agent, s.needsApproval)
+	a, err := s.factory(r.Context(), req.Agent, agent.ObserverFunc(sess.onEvent), sess.waitApproval)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("create agent: %w", err))
+		return
+	}
+	sess.agent = a
+
+	s.mu.Lock()
+	s.sessions[id] = sess
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, sessionResponse{ID: id, Agent: req.Agent})
+}
+
+func (s *HTTPServer) withSession(h func(http.ResponseWriter, *http.Request, *session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		sess, ok := s.sessions[r.PathValue("id")]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.New("session not found"))
+			return
+		}
+		h(w, r, sess)
+	}
+}
+
+func (s *HTTPServer) getSession(w http.ResponseWriter, r *http.Request, sess *session) {
+	history, busy := sess.snapshot()
+	writeJSON(w, http.StatusOK, sessionResponse{ID: sess.id, Agent: sess.agentName, Busy: busy, Messages: history})
+}
+
+func (s *HTTPServer) deleteSession(w http.ResponseWriter, r *http.Request, sess *session) {
+	s.mu.Lock()
+	delete(s.sessions, sess.id)
+	s.mu.Unlock()
+	if err := sess.close(); err != nil {
+		slog.Warn("close session failed", "session", sess.id, "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type postMessageRequest struct {
+	Content string `json:"content"`
+}
+
+func (s *HTTPServer) postMessage(w http.ResponseWriter, r *http.Request, sess *session) {
+	var req postMessageRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Content == "" {
+		writeError(w, http.StatusBadRequest, errors.New("content is required"))
+		return
+	}
+	if err := sess.start(req.Content); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, sessionResponse{ID: sess.id, Agent: sess.agentName, Busy: true})
+}
+
+func (s *HTTPServer) streamEvents(w http.ResponseWriter, r *http.Request, sess *session) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	var after int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		after, _ = strconv.ParseInt(v, 10, 64)
+	}
+	ch, backlog := sess.subscribe(after)
+	defer sess.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, ev := range backlog {
+		writeEvent(w, ev)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				// 订阅者跟不上事件或会话已结束, 客户端可以用 Last-Event-ID 重新订阅
+				return
+			}
+			writeEvent(w, ev)
+			flusher.Flush()
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+type approveRequest struct {
+	Approve bool `json:"approve"`
+}
+
+func (s *HTTPServer) approve(w http.ResponseWriter, r *http.Request, sess *session) {
+	var req approveRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !sess.resolve(r.PathValue("approval"), req.Approve) {
+		writeError(w, http.StatusNotFound, errors.New("approval not found"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) cancel(w http.ResponseWriter, r *http.Request, sess *session) {
+	if !sess.cancelTurn() {
+		writeError(w, http.StatusConflict, errors.New("session is idle"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *HTTPServer) needsApproval(call schema.ToolCall) bool {
+	return s.approval["*"] || s.approval[call.Function.Name]
+}
+
+func decodeBody(r *http.Request, v any) error {
+	if r.Body == nil {
+		return nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return fmt.Errorf("decode request: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,187 @@
+package serve_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/agent/common"
+	"github.com/bootun/cosmica/provider/replay"
+	"github.com/bootun/cosmica/serve"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/tools/base"
+	"github.com/bootun/cosmica/tools/file"
+	"github.com/cloudwego/eino/schema"
+)
+
+type sseEvent struct {
+	id   string
+	typ  string
+	data map[string]any
+}
+
+// newAPI serves a SpaceMan driven by the scripted model.
+func newAPI(t *testing.T, m *replay.ChatModel, opts ...serve.HTTPOption) string {
+	factory := func(ctx context.Context, name string, observer agent.Observer, approver agent.Approver) (agent.Agent, error) {
+		ts, err := tools.NewToolSet(base.NewBell(), file.NewDirReader())
+		if err != nil {
+			return nil, err
+		}
+		return common.NewSpaceMan(ctx, common.WithChatModel(m), common.WithToolSet(ts), common.WithOutput(io.Discard), common.WithObserver(observer), common.WithApprover(approver))
+	}
+	s := serve.NewHTTPServer(factory, opts...)
+	hs := httptest.NewServer(s)
+	t.Cleanup(func() {
+		hs.Close()
+		s.Close()
+	})
+	return hs.URL
+}
+
+func do(t *testing.T, method, url, body string, wantStatus int) map[string]any {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, url, resp.StatusCode, wantStatus, data)
+	}
+	var out map[string]any
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+// subscribe opens the event stream of a session and returns a function that
+// reads the next event.
+func subscribe(t *testing.T, url string) func() sseEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	sc := bufio.NewScanner(resp.Body)
+	return func() sseEvent {
+		t.Helper()
+		var ev sseEvent
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "" && ev.typ != "":
+				return ev
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.typ = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data)
+			}
+		}
+		t.Fatalf("event stream ended: %v", sc.Err())
+		return ev
+	}
+}
+
+func TestHTTPSessionStreamsEvents(t *testing.T) {
+	m := replay.NewScripted(
+		replay.Reply("你好", replay.ToolCall("bell", nil)),
+	)
+	base := newAPI(t, m)
+	sess := do(t, http.MethodPost, base+"/v1/sessions", `{"agent":"spaceman"}`, http.StatusCreated)
+	url := base + "/v1/sessions/" + sess["id"].(string)
+
+	next := subscribe(t, url+"/events")
+	do(t, http.MethodPost, url+"/messages", `{"content":"hi"}`, http.StatusAccepted)
+
+	var types []string
+	for {
+		ev := next()
+		types = append(types, ev.typ)
+		if ev.typ == serve.EventDone {
+			if ev.data["content"] != "你好" {
+				t.Errorf("done content = %v", ev.data["content"])
+			}
+			break
+		}
+	}
+	if got := strings.Join(types, ","); got != "token,tool_call,tool_result,done" {
+		t.Errorf("events = %s", got)
+	}
+
+	got := do(t, http.MethodGet, url, "", http.StatusOK)
+	if msgs := got["messages"].([]any); len(msgs) != 4 {
+		t.Errorf("history has %d messages, want 4", len(msgs))
+	}
+	do(t, http.MethodDelete, url, "", http.StatusNoContent)
+	do(t, http.MethodGet, url, "", http.StatusNotFound)
+}
+
+func TestHTTPApprovalAndCancel(t *testing.T) {
+	m := replay.NewScripted(
+		replay.Reply("", replay.ToolCall("dir_reader", `{"dirname":"."}`)),
+		replay.Reply("不看了", replay.ToolCall("bell", nil)),
+		replay.Reply("", replay.ToolCall("dir_reader", `{"dirname":"."}`)),
+	)
+	base := newAPI(t, m, serve.WithApproval("dir_reader"))
+	sess := do(t, http.MethodPost, base+"/v1/sessions", ``, http.StatusCreated)
+	url := base + "/v1/sessions/" + sess["id"].(string)
+	next := subscribe(t, url+"/events")
+
+	// a rejected call is reported to the model instead of running the tool
+	do(t, http.MethodPost, url+"/messages", `{"content":"list files"}`, http.StatusAccepted)
+	do(t, http.MethodPost, url+"/messages", `{"content":"again"}`, http.StatusConflict)
+	ev := next()
+	for ev.typ != serve.EventApproval {
+		ev = next()
+	}
+	do(t, http.MethodPost, url+"/approvals/nope", `{"approve":true}`, http.StatusNotFound)
+	do(t, http.MethodPost, url+"/approvals/"+ev.data["id"].(string), `{"approve":false}`, http.StatusNoContent)
+	for ev.typ != serve.EventDone {
+		ev = next()
+		if ev.typ == serve.EventToolResult && ev.data["name"] == "dir_reader" && ev.data["rejected"] != true {
+			t.Error("rejected tool was invoked")
+		}
+	}
+	history := m.Calls()[1].Input
+	if last := history[len(history)-1]; last.Role != schema.Tool || !strings.Contains(last.Content, "拒绝") {
+		t.Errorf("model was not told about the rejection: %+v", last)
+	}
+
+	// cancelling while waiting for approval ends the turn and keeps the history
+	do(t, http.MethodPost, url+"/messages", `{"content":"list files"}`, http.StatusAccepted)
+	for ev.typ != serve.EventApproval {
+		ev = next()
+	}
+	do(t, http.MethodPost, url+"/cancel", ``, http.StatusAccepted)
+	for ev.typ != serve.EventCancelled {
+		ev = next()
+	}
+	got := do(t, http.MethodGet, url, "", http.StatusOK)
+	if msgs := got["messages"].([]any); len(msgs) != 6 || got["busy"] != false {
+		t.Errorf("after cancel: %d messages, busy %v", len(msgs), got["busy"])
+	}
+	do(t, http.MethodPost, url+"/cancel", ``, http.StatusConflict)
+}
@@ -0,0 +1,116 @@
+// Package serve 让外部程序通过网络协议使用 cosmica 的 agent 与工具
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/utils/logging"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	mcpServerName    = "cosmica"
+	mcpServerVersion = "0.1.0"
+)
+
+// MCPAgent 是发布为 MCP 工具的 agent
+type MCPAgent struct {
+	Name        string
+	Description string
+	// Create 每次调用都会创建新的 agent, 任务之间互不影响
+	Create agent.CreateAgentFunc
+}
+
+// NewMCPServer 创建 MCP 服务, 每个 agent 发布为一个接收 task 参数的工具
+// ts 不为 nil 时, 其中已启用的工具也会以发送给模型的名称一并发布
+func NewMCPServer(agents []MCPAgent, ts *tools.ToolSet) (*server.MCPServer, error) {
+	s := server.NewMCPServer(mcpServerName, mcpServerVersion,
+		server.WithToolCapabilities(false),
+		server.WithRecovery(),
+	)
+	for _, a := range agents {
+		s.AddTool(mcp.NewTool(a.Name,
+			mcp.WithDescription(a.Description),
+			mcp.WithString("task", mcp.Required(), mcp.Description("the task for the agent to solve")),
+		), agentHandler(a.Create))
+	}
+	if ts == nil {
+		return s, nil
+	}
+	for _, info := range ts.Infos() {
+		raw := json.RawMessage(`{"type":"object","properties":{}}`)
+		params, err := info.ParamsOneOf.ToOpenAPIV3()
+		if err != nil {
+			return nil, fmt.Errorf("convert params of tool %s: %w", info.Name, err)
+		}
+		if params != nil {
+			if raw, err = json.Marshal(params); err != nil {
+				return nil, fmt.Errorf("marshal params of tool %s: %w", info.Name, err)
+			}
+		}
+		s.AddTool(mcp.NewToolWithRawSchema(info.Name, info.Desc, raw), toolHandler(ts, info.Name))
+	}
+	return s, nil
+}
+
+// agentHandler 创建 agent 执行任务, 返回 agent 最后给出的回答
+func agentHandler(create agent.CreateAgentFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		task := req.GetString("task", "")
+		if strings.TrimSpace(task) == "" {
+			return mcp.NewToolResultError("参数 task 不能为空"), nil
+		}
+		ctx = withSessionAttr(ctx)
+		a, err := create(ctx, task)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("create agent", err), nil
+		}
+		if c, ok := a.(io.Closer); ok {
+			defer c.Close()
+		}
+		history, err := a.HandleQuestion(ctx, task, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("handle task", err), nil
+		}
+		summary := agent.Summary(history)
+		if summary == "" {
+			summary = "the agent finished without an answer"
+		}
+		return mcp.NewToolResultText(summary), nil
+	}
+}
+
+// withSessionAttr 把 MCP 客户端的会话 ID 加到日志属性中
+func withSessionAttr(ctx context.Context) context.Context {
+	if sess := server.ClientSessionFromContext(ctx); sess != nil {
+		return logging.WithAttrs(ctx, slog.String("session", sess.SessionID()))
+	}
+	return ctx
+}
+
+// toolHandler 通过工具集调用工具, 与 agent 调用工具时一样先校验参数
+func toolHandler(ts *tools.ToolSet, name string) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := "{}"
+		if raw := req.GetRawArguments(); raw != nil {
+			data, err := json.Marshal(raw)
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr("marshal arguments", err), nil
+			}
+			args = string(data)
+		}
+		res, err := ts.Invoke(withSessionAttr(ctx), name, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(res), nil
+	}
+}
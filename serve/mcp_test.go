@@ -0,0 +1,118 @@
+package serve_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/serve"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/tools/base"
+	"github.com/bootun/cosmica/tools/file"
+	"github.com/bootun/cosmica/tools/mcp"
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type echoAgent struct{}
+
+func (echoAgent) HandleQuestion(ctx context.Context, question string, history []*schema.Message) ([]*schema.Message, error) {
+	return append(history,
+		schema.UserMessage(question),
+		schema.AssistantMessage("done: "+question, nil),
+	), nil
+}
+
+// attach serves the MCP server over HTTP and attaches it with the MCP client
+// the agents use, so both ends are exercised.
+func attach(t *testing.T, agents []serve.MCPAgent, exposed *tools.ToolSet) *tools.ToolSet {
+	s, err := serve.NewMCPServer(agents, exposed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := server.NewTestStreamableHTTPServer(s)
+	t.Cleanup(hs.Close)
+
+	ts, err := tools.NewToolSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := mcp.Attach(context.Background(), ts, map[string]config.MCPServer{"cosmica": {URL: hs.URL + "/mcp"}})
+	if len(servers) != 1 {
+		t.Fatalf("attached %d servers, want 1", len(servers))
+	}
+	t.Cleanup(func() { servers[0].Close() })
+	return ts
+}
+
+func TestMCPServerAgents(t *testing.T) {
+	ts := attach(t, []serve.MCPAgent{
+		{
+			Name:        "echo",
+			Description: "echo the task",
+			Create: func(ctx context.Context, task string) (agent.Agent, error) {
+				return echoAgent{}, nil
+			},
+		},
+		{
+			Name:        "broken",
+			Description: "fails to start",
+			Create: func(ctx context.Context, task string) (agent.Agent, error) {
+				return nil, errors.New("no model configured")
+			},
+		},
+	}, nil)
+	ctx := context.Background()
+
+	if got := strings.Join(ts.Names(), ","); got != "mcp.cosmica.broken,mcp.cosmica.echo" && got != "mcp.cosmica.echo,mcp.cosmica.broken" {
+		t.Fatalf("names = %s", got)
+	}
+	got, err := ts.Invoke(ctx, "mcp.cosmica.echo", `{"task":"say hi"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "done: say hi" {
+		t.Errorf("echo = %q", got)
+	}
+	if _, err := ts.Invoke(ctx, "mcp.cosmica.echo", `{"task":" "}`); err == nil {
+		t.Error("empty task: want error")
+	}
+	if _, err := ts.Invoke(ctx, "mcp.cosmica.broken", `{"task":"x"}`); err == nil || !strings.Contains(err.Error(), "no model configured") {
+		t.Errorf("broken: err = %v", err)
+	}
+}
+
+func TestMCPServerTools(t *testing.T) {
+	exposed, err := tools.NewToolSet(base.NewBell(), file.NewDirReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exposed.Disable("bell"); err != nil {
+		t.Fatal(err)
+	}
+	ts := attach(t, nil, exposed)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(ts.Names(), ","); got != "mcp.cosmica.dir_reader" {
+		t.Fatalf("names = %s, want only enabled tools", got)
+	}
+	got, err := ts.Invoke(context.Background(), "mcp.cosmica.dir_reader", `{"dirname":"`+filepath.ToSlash(dir)+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "hello.txt") {
+		t.Errorf("dir_reader = %q", got)
+	}
+	// the exposed schema is enforced by the client before calling the server
+	if _, err := ts.Invoke(context.Background(), "mcp.cosmica.dir_reader", `{}`); err == nil {
+		t.Error("missing dirname: want error")
+	}
+}
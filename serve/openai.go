@@ -0,0 +1,319 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/utils/logging"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxTraceResult 工具调用轨迹中结果的最大长度
+const maxTraceResult = 200
+
+var completionID atomic.Int64
+
+// WithAgents 指定 /v1/models 列出的 agent, 默认为 spaceman 与 netizen
+func WithAgents(names ...string) HTTPOption {
+	return func(s *HTTPServer) {
+		s.agents = names
+	}
+}
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	// StreamOptions.IncludeUsage 为 true 时在流结束前单独发送一个带用量的分片
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	// CosmicaTrace 为 true 时在回答中以引用块标注工具调用及其结果
+	CosmicaTrace bool `json:"cosmica_trace"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type contentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// text 返回消息的文本, content 可以是字符串或 content part 数组, 非文本部分被忽略
+func (m chatMessage) text() (string, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s, nil
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", fmt.Errorf("unsupported content of %s message", m.Role)
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// toHistory 把请求中的消息转换为对话历史与最后一个问题
+func toHistory(msgs []chatMessage) ([]*schema.Message, string, error) {
+	if len(msgs) == 0 || msgs[len(msgs)-1].Role != "user" {
+		return nil, "", errors.New("the last message must come from the user")
+	}
+	var history []*schema.Message
+	for _, m := range msgs {
+		text, err := m.text()
+		if err != nil {
+			return nil, "", err
+		}
+		switch m.Role {
+		case "system", "developer":
+			history = append(history, schema.SystemMessage(text))
+		case "user":
+			history = append(history, schema.UserMessage(text))
+		case "assistant":
+			history = append(history, schema.AssistantMessage(text, nil))
+		default:
+			// agent 在服务端调用自己的工具, 客户端的工具消息没有意义
+		}
+	}
+	question := history[len(history)-1].Content
+	return history[:len(history)-1], question, nil
+}
+
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int        `json:"index"`
+	Message      *chatReply `json:"message,omitempty"`
+	Delta        *chatReply `json:"delta,omitempty"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+type chatReply struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// chatCompletions 以 OpenAI Chat Completions 接口运行 agent
+// 每个请求创建新的 agent, agent 在服务端完成工具调用, 只把回答返回给客户端
+// 需要批准的工具在这里无法批准, 一律拒绝
+func (s *HTTPServer) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatCompletionRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	if !slices.Contains(s.agents, req.Model) {
+		writeOpenAIError(w, http.StatusNotFound, "model_not_found", fmt.Errorf("model %q does not exist", req.Model))
+		return
+	}
+	history, question, err := toHistory(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	resp := chatCompletion{
+		ID:      fmt.Sprintf("chatcmpl-%d", completionID.Add(1)),
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+	var content strings.Builder
+	write := func(text string) {
+		content.WriteString(text)
+	}
+	var flusher http.Flusher
+	started := false
+	if req.Stream {
+		var ok bool
+		if flusher, ok = w.(http.Flusher); !ok {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", errors.New("streaming unsupported"))
+			return
+		}
+		resp.Object = "chat.completion.chunk"
+		write = func(text string) {
+			delta := &chatReply{Content: text}
+			if !started {
+				// 第一个分片带上角色, 同时开始响应
+				delta.Role = "assistant"
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+			resp.Choices = []chatChoice{{Delta: delta}}
+			writeChunk(w, resp)
+			flusher.Flush()
+		}
+	}
+
+	// 用量累计所有模型调用, 包括子 agent; 提供方都没有返回用量时不填写
+	var usage *chatUsage
+	// 只返回顶层 agent 的回答, 子 agent 的输出不属于回答
+	observer := agent.ObserverFunc(func(ctx context.Context, ev agent.Event) {
+		if ev, ok := ev.(agent.StepFinished); ok && ev.Usage != nil {
+			if usage == nil {
+				usage = &chatUsage{}
+			}
+			usage.PromptTokens += ev.Usage.PromptTokens
+			usage.CompletionTokens += ev.Usage.CompletionTokens
+			usage.TotalTokens += ev.Usage.TotalTokens
+		}
+		if ev.EventSource().Depth > 0 {
+			return
+		}
+		switch ev := ev.(type) {
+		case agent.TokenDelta:
+			write(ev.Content)
+		case agent.ToolCallRequested:
+			if req.CosmicaTrace {
+				write(fmt.Sprintf("\n\n> tool call: %s %s\n\n", ev.Call.Function.Name, ev.Call.Function.Arguments))
+			}
+		case agent.ToolResult:
+			if req.CosmicaTrace {
+				result := ev.Result
+				switch {
+				case ev.Rejected:
+					result = "rejected"
+				case ev.Err != nil:
+					result = "error: " + ev.Err.Error()
+				}
+				write(fmt.Sprintf("> tool result: %s: %s\n\n", ev.Call.Function.Name, quoteTrace(result)))
+			}
+		}
+	})
+	approver := func(_ context.Context, call schema.ToolCall) bool {
+		return !s.needsApproval(call)
+	}
+
+	// 每个请求都是独立的会话, 以响应 ID 关联日志
+	ctx := logging.WithAttrs(r.Context(), slog.String("session", resp.ID))
+	a, err := s.factory(ctx, req.Model, observer, approver)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", fmt.Errorf("create agent: %w", err))
+		return
+	}
+	if c, ok := a.(io.Closer); ok {
+		defer c.Close()
+	}
+	_, err = a.HandleQuestion(ctx, question, history)
+
+	if !req.Stream {
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", err)
+			return
+		}
+		stop := "stop"
+		resp.Object = "chat.completion"
+		resp.Choices = []chatChoice{{
+			Message:      &chatReply{Role: "assistant", Content: content.String()},
+			FinishReason: &stop,
+		}}
+		resp.Usage = usage
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	if err != nil {
+		// 已经开始输出后无法再改变状态码, 以错误事件结束流
+		if !started {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", err)
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", openAIError(err, "server_error"))
+		flusher.Flush()
+		return
+	}
+	if !started {
+		write("")
+	}
+	stop := "stop"
+	resp.Choices = []chatChoice{{Delta: &chatReply{}, FinishReason: &stop}}
+	writeChunk(w, resp)
+	if req.StreamOptions.IncludeUsage && usage != nil {
+		resp.Choices = []chatChoice{}
+		resp.Usage = usage
+		writeChunk(w, resp)
+	}
+	io.WriteString(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+type modelList struct {
+	Object string      `json:"object"`
+	Data   []modelInfo `json:"data"`
+}
+
+type modelInfo struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// models 把每个 agent 列为一个模型
+func (s *HTTPServer) models(w http.ResponseWriter, r *http.Request) {
+	list := modelList{Object: "list", Data: make([]modelInfo, 0, len(s.agents))}
+	for _, name := range s.agents {
+		list.Data = append(list.Data, modelInfo{ID: name, Object: "model", OwnedBy: "cosmica"})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func writeChunk(w io.Writer, chunk chatCompletion) {
+	data, _ := json.Marshal(chunk)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
+// quoteTrace 截断工具结果并保持在同一个引用块内
+func quoteTrace(result string) string {
+	if r := []rune(result); len(r) > maxTraceResult {
+		result = string(r[:maxTraceResult]) + "..."
+	}
+	return strings.ReplaceAll(strings.TrimSpace(result), "\n", "\n> ")
+}
+
+func openAIError(err error, typ string) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"error": map[string]string{"message": err.Error(), "type": typ},
+	})
+	return data
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, typ string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(openAIError(err, typ))
+}
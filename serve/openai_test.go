@@ -0,0 +1,125 @@
+package serve_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/provider/replay"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestChatCompletions(t *testing.T) {
+	done := replay.Reply("看完了", replay.ToolCall("bell", nil))
+	done.Chunks[len(done.Chunks)-1].ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25}}
+	m := replay.NewScripted(
+		replay.Reply("我看看", replay.ToolCall("dir_reader", `{"dirname":"."}`)),
+		done,
+	)
+	base := newAPI(t, m)
+
+	resp := do(t, http.MethodPost, base+"/v1/chat/completions", `{
+		"model": "spaceman",
+		"messages": [
+			{"role": "system", "content": "answer in english"},
+			{"role": "user", "content": "你好"},
+			{"role": "assistant", "content": "你好!"},
+			{"role": "user", "content": [{"type": "text", "text": "看看当前目录"}]}
+		]
+	}`, http.StatusOK)
+	choice := resp["choices"].([]any)[0].(map[string]any)
+	if got := choice["message"].(map[string]any)["content"]; got != "我看看看完了" {
+		t.Errorf("content = %q", got)
+	}
+	if choice["finish_reason"] != "stop" || resp["object"] != "chat.completion" {
+		t.Errorf("unexpected response %v", resp)
+	}
+
+	// only the second reply reports usage
+	if usage, _ := resp["usage"].(map[string]any); usage["prompt_tokens"] != 20.0 || usage["total_tokens"] != 25.0 {
+		t.Errorf("usage = %v", resp["usage"])
+	}
+
+	// the client history is kept, the agent's own system prompt comes first and the client's follows it
+	input := m.Calls()[0].Input
+	if len(input) != 5 || input[0].Role != schema.System || input[0].Content == "answer in english" ||
+		input[1].Role != schema.System || input[1].Content != "answer in english" ||
+		input[2].Content != "你好" || input[4].Content != "看看当前目录" {
+		t.Errorf("model input = %v", input)
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	m := replay.NewScripted(
+		replay.Reply("我看看", replay.ToolCall("dir_reader", `{"dirname":"."}`)),
+		replay.Reply("看完了", replay.ToolCall("bell", nil)),
+	)
+	base := newAPI(t, m)
+
+	resp, err := http.Post(base+"/v1/chat/completions", "application/json", strings.NewReader(`{
+		"model": "spaceman",
+		"stream": true,
+		"cosmica_trace": true,
+		"messages": [{"role": "user", "content": "看看当前目录"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var finish any
+	done := false
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Delta        struct{ Content string } `json:"delta"`
+				FinishReason any                      `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("object = %q", chunk.Object)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		finish = chunk.Choices[0].FinishReason
+	}
+	if !done || finish != "stop" {
+		t.Errorf("stream did not finish properly: done %v, finish %v", done, finish)
+	}
+	got := content.String()
+	for _, want := range []string{"我看看", "> tool call: dir_reader", "> tool result: dir_reader:", "> tool call: bell", "看完了"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("content %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestChatCompletionsErrors(t *testing.T) {
+	base := newAPI(t, replay.NewScripted())
+	do(t, http.MethodPost, base+"/v1/chat/completions", `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`, http.StatusNotFound)
+	do(t, http.MethodPost, base+"/v1/chat/completions", `{"model":"spaceman","messages":[{"role":"assistant","content":"hi"}]}`, http.StatusBadRequest)
+
+	models := do(t, http.MethodGet, base+"/v1/models", "", http.StatusOK)
+	var ids []string
+	for _, m := range models["data"].([]any) {
+		ids = append(ids, m.(map[string]any)["id"].(string))
+	}
+	if got := strings.Join(ids, ","); got != "spaceman,netizen" {
+		t.Errorf("models = %s", got)
+	}
+}
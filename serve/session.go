@@ -0,0 +1,289 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/utils/logging"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 事件类型
+const (
+	EventToken      = "token"
+	EventToolCall   = "tool_call"
+	EventToolResult = "tool_result"
+	EventApproval   = "approval"
+	EventSubAgent   = "sub_agent"
+	EventDone       = "done"
+	EventCancelled  = "cancelled"
+	EventError      = "error"
+)
+
+const (
+	// maxBacklog 每个会话保留的最近事件数, 用于断线续传
+	maxBacklog = 4096
+	// subscriberBuffer 订阅者缓冲区大小, 缓冲区满时断开订阅者, 避免拖慢 agent
+	subscriberBuffer = 256
+)
+
+// Event 是推送给客户端的事件, ID 在会话内递增
+type Event struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+type tokenData struct {
+	Content string `json:"content"`
+}
+
+type toolCallData struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type toolResultData struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Result   string `json:"result,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Rejected bool   `json:"rejected,omitempty"`
+}
+
+type subAgentData struct {
+	Name string `json:"name"`
+	Task string `json:"task"`
+}
+
+type approvalData struct {
+	ID       string       `json:"id"`
+	ToolCall toolCallData `json:"tool_call"`
+}
+
+type doneData struct {
+	Content string `json:"content"`
+}
+
+type errorData struct {
+	Message string `json:"message"`
+}
+
+// session 是一个会话, 同一时间只处理一条消息
+type session struct {
+	id        string
+	agentName string
+	agent     agent.Agent
+	// needsApproval 判断工具调用是否需要客户端批准
+	needsApproval func(schema.ToolCall) bool
+
+	mu      sync.Mutex
+	history []*schema.Message
+	// cancel 不为 nil 表示正在处理消息
+	cancel     context.CancelFunc
+	events     []Event
+	lastID     int64
+	subs       map[chan Event]struct{}
+	approvals  map[string]chan bool
+	approvalID int
+}
+
+func newSession(id, agentName string, needsApproval func(schema.ToolCall) bool) *session {
+	return &session{
+		id:            id,
+		agentName:     agentName,
+		needsApproval: needsApproval,
+		subs:          make(map[chan Event]struct{}),
+		approvals:     make(map[string]chan bool),
+	}
+}
+
+// onEvent 把顶层 agent 的事件转为推送给客户端的事件, 子 agent 只推送其创建
+func (s *session) onEvent(ctx context.Context, ev agent.Event) {
+	if ev.EventSource().Depth > 0 {
+		return
+	}
+	switch ev := ev.(type) {
+	case agent.TokenDelta:
+		s.emit(EventToken, tokenData{Content: ev.Content})
+	case agent.ToolCallRequested:
+		s.emit(EventToolCall, newToolCallData(ev.Call))
+	case agent.ToolResult:
+		data := toolResultData{ID: ev.Call.ID, Name: ev.Call.Function.Name, Result: ev.Result, Rejected: ev.Rejected}
+		if ev.Err != nil {
+			data.Error = ev.Err.Error()
+		}
+		s.emit(EventToolResult, data)
+	case agent.SubAgentSpawned:
+		s.emit(EventSubAgent, subAgentData{Name: ev.Name, Task: ev.Task})
+	}
+}
+
+func newToolCallData(call schema.ToolCall) toolCallData {
+	return toolCallData{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments}
+}
+
+// start 在后台处理一条消息, 会话正在处理其他消息时返回错误
+func (s *session) start(question string) error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return errors.New("session is busy")
+	}
+	ctx, cancel := context.WithCancel(logging.WithAttrs(context.Background(), slog.String("session", s.id)))
+	s.cancel = cancel
+	history := s.history
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		newHistory, err := s.agent.HandleQuestion(ctx, question, history)
+		s.mu.Lock()
+		s.cancel = nil
+		// 出错或被取消的消息不计入历史, 避免留下没有结果的工具调用
+		if err == nil {
+			s.history = newHistory
+		}
+		s.mu.Unlock()
+		switch {
+		case err == nil:
+			s.emit(EventDone, doneData{Content: agent.Summary(newHistory)})
+		case ctx.Err() != nil:
+			s.emit(EventCancelled, nil)
+		default:
+			s.emit(EventError, errorData{Message: err.Error()})
+		}
+	}()
+	return nil
+}
+
+// cancelTurn 取消正在处理的消息, 会话空闲时返回 false
+func (s *session) cancelTurn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+func (s *session) snapshot() ([]*schema.Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.history, s.cancel != nil
+}
+
+func (s *session) waitApproval(ctx context.Context, call schema.ToolCall) bool {
+	if !s.needsApproval(call) {
+		return true
+	}
+	ch := make(chan bool, 1)
+	s.mu.Lock()
+	s.approvalID++
+	id := strconv.Itoa(s.approvalID)
+	s.approvals[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.approvals, id)
+		s.mu.Unlock()
+	}()
+
+	s.emit(EventApproval, approvalData{ID: id, ToolCall: newToolCallData(call)})
+	select {
+	case ok := <-ch:
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// resolve 答复等待中的审批, 审批不存在时返回 false
+func (s *session) resolve(id string, approve bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.approvals[id]
+	if !ok {
+		return false
+	}
+	delete(s.approvals, id)
+	ch <- approve
+	return true
+}
+
+func (s *session) emit(typ string, data any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	ev := Event{ID: s.lastID, Type: typ, Data: data}
+	s.events = append(s.events, ev)
+	if len(s.events) > maxBacklog {
+		s.events = s.events[len(s.events)-maxBacklog:]
+	}
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe 返回 ID 大于 after 的历史事件以及接收后续事件的通道
+func (s *session) subscribe(after int64) (chan Event, []Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var backlog []Event
+	for _, ev := range s.events {
+		if ev.ID > after {
+			backlog = append(backlog, ev)
+		}
+	}
+	ch := make(chan Event, subscriberBuffer)
+	s.subs[ch] = struct{}{}
+	return ch, backlog
+}
+
+func (s *session) unsubscribe(ch chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// close 取消正在处理的消息, 断开所有订阅者并释放 agent 持有的资源
+func (s *session) close() error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	s.mu.Unlock()
+	if c, ok := s.agent.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func writeEvent(w io.Writer, ev Event) {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		data, _ = json.Marshal(errorData{Message: err.Error()})
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+}
@@ -0,0 +1,106 @@
+// Package telemetry 把 agent 记录的 OpenTelemetry span 导出到 OTLP 接收端或本地文件
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bootun/cosmica/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+)
+
+const (
+	defaultServiceName = "cosmica"
+	defaultFile        = "traces.jsonl"
+)
+
+// Setup 按配置创建导出 span 的 TracerProvider 并设为全局, agent 通过全局 Tracer 记录 span
+// 返回的函数在退出前导出剩余的 span 并关闭导出器; 未配置导出方式时不做任何事
+func Setup(ctx context.Context, cfg config.Telemetry) (func(context.Context) error, error) {
+	var opt sdktrace.TracerProviderOption
+	switch cfg.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err := newOTLPExporter(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		opt = sdktrace.WithBatcher(exporter)
+	case "file":
+		exporter, err := newFileExporter(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		// 本地文件写入很快, 同步导出保证进程被中断时 span 不丢失
+		opt = sdktrace.WithSyncer(exporter)
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+
+	name := cfg.ServiceName
+	if name == "" {
+		name = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(name)))
+	if err != nil {
+		return nil, fmt.Errorf("create resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(opt, sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func newOTLPExporter(ctx context.Context, cfg config.Telemetry) (sdktrace.SpanExporter, error) {
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// fileExporter 每个 span 写一行 JSON, 关闭导出器时关闭文件
+type fileExporter struct {
+	*stdouttrace.Exporter
+	file *os.File
+}
+
+func newFileExporter(path string) (sdktrace.SpanExporter, error) {
+	if path == "" {
+		path = defaultFile
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file: %w", err)
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("create file exporter: %w", err)
+	}
+	return &fileExporter{Exporter: exporter, file: f}, nil
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	if err := e.Exporter.Shutdown(ctx); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
@@ -0,0 +1,56 @@
+package telemetry_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/telemetry"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupWritesSpansToFile(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := telemetry.Setup(context.Background(), config.Telemetry{Exporter: "file", File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	_, child := otel.Tracer("test").Start(ctx, "child")
+	child.End()
+	parent.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var span struct{ Name string }
+		if err := json.Unmarshal(sc.Bytes(), &span); err != nil {
+			t.Fatalf("line is not json: %v", err)
+		}
+		names = append(names, span.Name)
+	}
+	if len(names) != 2 || names[0] != "child" || names[1] != "parent" {
+		t.Errorf("spans = %v", names)
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := telemetry.Setup(context.Background(), config.Telemetry{Exporter: "zipkin"}); err == nil {
+		t.Error("want error")
+	}
+}
@@ -2,7 +2,7 @@ package base
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
@@ -10,6 +10,8 @@ import (
 
 const (
 	FinishFlag = "[finish]"
+	// BellName 是 bell 工具的名称, agent 只能通过调用它结束对话
+	BellName = "bell"
 )
 
 func NewBell() *bell {
@@ -20,7 +22,7 @@ type bell struct{}
 
 func (s *bell) Info(ctx context.Context) (*schema.ToolInfo, error) {
 	return &schema.ToolInfo{
-		Name: "bell",
+		Name: BellName,
 		Desc: `当且仅当出现以下任何一种情况时必须调用:
 1.答案已完整给出，对话可结束。
 2.已向用户提出问题或澄清请求，需要等待用户回复才能继续。
@@ -33,7 +35,7 @@ func (s *bell) Info(ctx context.Context) (*schema.ToolInfo, error) {
 }
 
 func (s *bell) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
-	log.Printf("任务已完成")
+	slog.DebugContext(ctx, "task finished")
 	return FinishFlag, nil
 }
 
@@ -0,0 +1,20 @@
+package base_test
+
+import (
+	"testing"
+
+	"github.com/bootun/cosmica/tools/base"
+	"github.com/bootun/cosmica/tools/tooltest"
+)
+
+func TestConformance(t *testing.T) {
+	tooltest.Conformance(t, base.NewBell(), tooltest.Case{
+		Name: "finish",
+		Args: "{}",
+		Check: func(t testing.TB, out string) {
+			if out != base.FinishFlag {
+				t.Errorf("got %q, want %q", out, base.FinishFlag)
+			}
+		},
+	})
+}
@@ -0,0 +1,146 @@
+package codesearch
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxChunkLines 超过该行数的函数或段落会被拆开, 保证片段易读
+const maxChunkLines = 120
+
+// Chunk 文件中可检索的一段, 通常是一个函数、类型或段落
+type Chunk struct {
+	// Name 描述片段, 例如 "func (s *Store) Save" 或 "class Parser"
+	Name  string `json:"name"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+	// Embedding 向量模型调用成功之前为空
+	Embedding []float64 `json:"embedding,omitempty"`
+}
+
+// chunkFile 把源文件切分为片段, Go 按声明切分, 其他语言按看起来像定义的行切分
+func chunkFile(path string, src []byte) []Chunk {
+	lines := strings.Split(strings.TrimRight(string(src), "\n"), "\n")
+	if filepath.Ext(path) == ".go" {
+		if chunks, err := goChunks(path, src, lines); err == nil {
+			return chunks
+		}
+	}
+	return genericChunks(path, lines)
+}
+
+// goChunks 每个顶层声明及其文档注释为一个片段, 包声明与 import 很少能回答问题, 不计入
+func goChunks(path string, src []byte, lines []string) ([]Chunk, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []Chunk
+	for _, d := range f.Decls {
+		start, name := d.Pos(), ""
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			name = "func " + d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = fmt.Sprintf("func (%s) %s", types.ExprString(d.Recv.List[0].Type), d.Name.Name)
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			name = d.Tok.String() + " " + strings.Join(specNames(d), ", ")
+		}
+		chunks = append(chunks, split(name, lines, fset.Position(start).Line, fset.Position(d.End()).Line)...)
+	}
+	return chunks, nil
+}
+
+func specNames(d *ast.GenDecl) []string {
+	var names []string
+	for _, s := range d.Specs {
+		switch s := s.(type) {
+		case *ast.TypeSpec:
+			names = append(names, s.Name.Name)
+		case *ast.ValueSpec:
+			for _, n := range s.Names {
+				names = append(names, n.Name)
+			}
+		}
+	}
+	return names
+}
+
+// definition 匹配常见语言中函数、类型或类的起始行, 包含缩进一层的方法
+var definition = regexp.MustCompile(`^\s{0,4}((export|pub(\([a-z]+\))?|public|private|protected|internal|static|async|abstract|final|override|default)\s+)*(def|class|function|fn|func|fun|impl|struct|enum|trait|interface|module|object|type)\s+[A-Za-z_$]`)
+
+// heading 匹配 markdown 标题, 标题是文档中一个段落的开始
+var heading = regexp.MustCompile(`^#{1,6}\s`)
+
+// genericChunks 在每个定义行或标题行开始新的片段
+func genericChunks(path string, lines []string) []Chunk {
+	boundary := definition
+	if ext := filepath.Ext(path); ext == ".md" || ext == ".markdown" {
+		boundary = heading
+	}
+	var chunks []Chunk
+	start, name := 1, filepath.Base(path)
+	for i, line := range lines {
+		if !boundary.MatchString(line) {
+			continue
+		}
+		if i > 0 {
+			chunks = append(chunks, split(name, lines, start, i)...)
+		}
+		start, name = i+1, chunkName(line)
+	}
+	return append(chunks, split(name, lines, start, len(lines))...)
+}
+
+func chunkName(line string) string {
+	line = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line), "{:"))
+	if len(line) > 80 {
+		line = strings.ToValidUTF8(line[:80], "")
+	}
+	return line
+}
+
+// split 把第 start 到 end 行(从 1 开始, 包含 end)切分为不超过 maxChunkLines 行的片段
+func split(name string, lines []string, start, end int) []Chunk {
+	if end > len(lines) {
+		end = len(lines)
+	}
+	var chunks []Chunk
+	for part := 1; start <= end; part++ {
+		stop := min(start+maxChunkLines-1, end)
+		text := strings.Join(lines[start-1:stop], "\n")
+		if strings.TrimSpace(text) != "" {
+			c := Chunk{Name: name, Start: start, End: stop, Text: text}
+			if part > 1 {
+				c.Name = fmt.Sprintf("%s (part %d)", name, part)
+			}
+			chunks = append(chunks, c)
+		}
+		start = stop + 1
+	}
+	return chunks
+}
+
+// isBinary 报告 data 是否像二进制文件
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
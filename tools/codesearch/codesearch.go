@@ -0,0 +1,62 @@
+package codesearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+const (
+	defaultLimit = 8
+	// maxSnippetLines 结果只保留开头几行, 其余内容 agent 可以用 file_reader 读取
+	maxSnippetLines = 30
+)
+
+// NewTool 返回基于 x 的 code_search 工具
+func NewTool(x *Index) tool.InvokableTool {
+	return tools.MustNewTypedTool("code_search", "search the code of the current workspace by meaning or keywords, "+
+		"returns the most related functions, types and sections as snippets with file paths and line ranges. "+
+		"Prefer it over listing directories and reading whole files when looking for where something is implemented", x.search)
+}
+
+type searchParams struct {
+	Query string `json:"query" desc:"what you are looking for, e.g. 'where tool calls are retried' or identifiers like 'SwitchModel'" required:"true"`
+	Dir   string `json:"dir" desc:"only search files under this directory, relative to the workspace root"`
+	Limit int    `json:"limit" desc:"maximum number of snippets to return, defaults to 8"`
+}
+
+func (x *Index) search(ctx context.Context, params searchParams) (string, error) {
+	if strings.TrimSpace(params.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	results, err := x.Search(ctx, params.Query, params.Dir, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "no matching code", nil
+	}
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s:%d-%d %s\n", r.Path, r.Start, r.End, r.Name)
+		lines := strings.Split(r.Text, "\n")
+		for j, line := range lines {
+			if j == maxSnippetLines {
+				fmt.Fprintf(&b, "... (%d more lines)\n", len(lines)-j)
+				break
+			}
+			fmt.Fprintf(&b, "%d\t%s\n", r.Start+j, line)
+		}
+	}
+	return b.String(), nil
+}
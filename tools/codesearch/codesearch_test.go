@@ -0,0 +1,239 @@
+package codesearch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+const storeGo = `package store
+
+import "os"
+
+// Store keeps values on disk.
+type Store struct {
+	path string
+}
+
+// Save writes the value to the store file.
+func (s *Store) Save(value string) error {
+	return os.WriteFile(s.path, []byte(value), 0o644)
+}
+
+func helper() {}
+`
+
+const parserPy = `import re
+
+class Tokenizer:
+    def split(self, text):
+        return re.split(r"\s+", text)
+
+def parse_config(path):
+    with open(path) as f:
+        return f.read()
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChunkFile(t *testing.T) {
+	var names []string
+	for _, c := range chunkFile("store.go", []byte(storeGo)) {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, "; "); got != "type Store; func (*Store) Save; func helper" {
+		t.Errorf("go chunks = %s", got)
+	}
+	save := chunkFile("store.go", []byte(storeGo))[1]
+	if save.Start != 10 || save.End != 13 || !strings.HasPrefix(save.Text, "// Save writes") {
+		t.Errorf("Save chunk = %+v", save)
+	}
+
+	names = nil
+	for _, c := range chunkFile("parser.py", []byte(parserPy)) {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, "; "); got != "parser.py; class Tokenizer; def split(self, text); def parse_config(path)" {
+		t.Errorf("python chunks = %s", got)
+	}
+
+	long := strings.Repeat("x\n", maxChunkLines+10)
+	if chunks := chunkFile("notes.txt", []byte(long)); len(chunks) != 2 || chunks[1].Start != maxChunkLines+1 || chunks[1].Name != "notes.txt (part 2)" {
+		t.Errorf("long file chunks = %+v", chunks)
+	}
+}
+
+func TestIndexIsIncremental(t *testing.T) {
+	ctx := context.Background()
+	root, indexFile := t.TempDir(), filepath.Join(t.TempDir(), "index.json")
+	writeFiles(t, root, map[string]string{
+		"store/store.go":          storeGo,
+		"scripts/parser.py":       parserPy,
+		"node_modules/x/index.js": "function ignored() {}",
+		".git/config.yml":         "ignored: true",
+	})
+	x, err := Open(root, indexFile, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := x.Refresh(ctx); err != nil || n != 2 {
+		t.Fatalf("first refresh indexed %d files, %v", n, err)
+	}
+	results, err := x.Search(ctx, "save value to disk", "", 1)
+	if err != nil || len(results) != 1 || results[0].Path != "store/store.go" || results[0].Name != "func (*Store) Save" {
+		t.Fatalf("results = %+v, %v", results, err)
+	}
+
+	// a reopened index only re-chunks files that changed
+	x, err = Open(root, indexFile, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := x.Refresh(ctx); err != nil || n != 0 {
+		t.Fatalf("refresh without changes indexed %d files, %v", n, err)
+	}
+	writeFiles(t, root, map[string]string{"scripts/parser.py": parserPy + "\ndef load_yaml(path):\n    pass\n"})
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(filepath.Join(root, "scripts/parser.py"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "store/store.go")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := x.Refresh(ctx); err != nil || n != 2 {
+		t.Fatalf("refresh after changes indexed %d files, %v", n, err)
+	}
+	results, err = x.Search(ctx, "yaml", "", 0)
+	if err != nil || len(results) != 1 || results[0].Name != "def load_yaml(path)" || results[0].Start != 11 {
+		t.Errorf("results = %+v, %v", results, err)
+	}
+	if results, _ := x.Search(ctx, "Store", "", 0); len(results) != 0 {
+		t.Errorf("deleted file is still searched: %+v", results)
+	}
+	if results, _ := x.Search(ctx, "tokenizer", "store", 0); len(results) != 0 {
+		t.Errorf("dir filter ignored: %+v", results)
+	}
+}
+
+func TestSearchRefreshesInBackground(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"store.go": storeGo})
+	x, err := Open(root, filepath.Join(t.TempDir(), "index.json"), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first search builds the index before searching
+	if results, err := x.Search(ctx, "Save", "", 1); err != nil || len(results) != 1 {
+		t.Fatalf("results = %+v, %v", results, err)
+	}
+	writeFiles(t, root, map[string]string{"scripts/parser.py": parserPy})
+	if results, _ := x.Search(ctx, "tokenizer", "", 0); len(results) != 0 {
+		t.Fatalf("refreshed before refreshInterval passed: %+v", results)
+	}
+
+	// a stale index is searched as is while it is refreshed in the background
+	x.mu.Lock()
+	x.refreshed = time.Now().Add(-refreshInterval)
+	x.mu.Unlock()
+	// holding refreshMu keeps the background refresh from finishing before the search
+	x.refreshMu.Lock()
+	results, _ := x.Search(ctx, "tokenizer", "", 0)
+	x.refreshMu.Unlock()
+	if len(results) != 0 {
+		t.Fatalf("search waited for the refresh: %+v", results)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		x.mu.Lock()
+		done := !x.refreshing
+		x.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if results, err := x.Search(ctx, "tokenizer", "", 0); err != nil || len(results) == 0 || results[0].Path != "scripts/parser.py" {
+		t.Errorf("results after refresh = %+v, %v", results, err)
+	}
+}
+
+// topicEmbedder embeds a text by the topics it mentions.
+type topicEmbedder struct {
+	fail bool
+}
+
+var topics = [][]string{{"persist", "disk", "writefile", "save"}, {"split", "token", "regex"}}
+
+func (e *topicEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	if e.fail {
+		return nil, errors.New("embedding service unavailable")
+	}
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float64, len(topics))
+		for j, words := range topics {
+			for _, w := range words {
+				vectors[i][j] += float64(strings.Count(strings.ToLower(text), w))
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestIndexWithEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"store.go": storeGo, "parser.py": parserPy})
+	x, err := Open(root, filepath.Join(t.TempDir(), "index.json"), &topicEmbedder{}, "topics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "persist" only appears in the embedding space, BM25 alone finds nothing
+	results, err := x.Search(ctx, "persist", "", 1)
+	if err != nil || len(results) != 1 || results[0].Name != "func (*Store) Save" {
+		t.Errorf("results = %+v, %v", results, err)
+	}
+
+	x.embedder = &topicEmbedder{fail: true}
+	results, err = x.Search(ctx, "tokenizer", "", 1)
+	if err != nil || len(results) != 1 || results[0].Name != "class Tokenizer" {
+		t.Errorf("fallback results = %+v, %v", results, err)
+	}
+}
+
+func TestTool(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"store.go": storeGo})
+	x, err := Open(root, filepath.Join(t.TempDir(), "index.json"), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := NewTool(x).InvokableRun(context.Background(), `{"query":"Save","limit":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "store.go:10-13 func (*Store) Save\n10\t// Save writes the value to the store file.\n11\tfunc (s *Store) Save(value string) error {\n"
+	if !strings.HasPrefix(out, want) {
+		t.Errorf("output:\n%s", out)
+	}
+}
@@ -0,0 +1,38 @@
+package codesearch
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/tools/tooltest"
+)
+
+func TestConformance(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"store.go": storeGo})
+	x, err := Open(root, filepath.Join(t.TempDir(), "index.json"), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tooltest.Conformance(t, NewTool(x),
+		tooltest.Case{
+			Name: "search",
+			Args: `{"query":"Save"}`,
+			Check: func(t testing.TB, out string) {
+				if !strings.HasPrefix(out, "store.go:10-13 func (*Store) Save\n") {
+					t.Errorf("got %q", out)
+				}
+			},
+		},
+		tooltest.Case{
+			Name: "no_match",
+			Args: `{"query":"kubernetes"}`,
+			Check: func(t testing.TB, out string) {
+				if out != "no matching code" {
+					t.Errorf("got %q", out)
+				}
+			},
+		},
+	)
+}
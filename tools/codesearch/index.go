@@ -0,0 +1,470 @@
+// Package codesearch 提供 code_search 工具: 从工作区的本地索引中按相关度检索代码片段, agent 不必在大仓库中逐个文件查找
+package codesearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootun/cosmica/search"
+	"github.com/bootun/cosmica/utils/fsutil"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+const (
+	// maxFileSize 跳过过大的生成文件或数据文件, 它们不适合作为片段
+	maxFileSize = 512 << 10
+	// maxFiles 索引的文件数上限, 避免误把家目录之类的大目录整个索引
+	maxFiles = 20000
+	// embedBatch 每次发给向量模型的片段数
+	embedBatch = 64
+	// rrfK 融合 BM25 与向量排名时削弱靠前名次的权重
+	rrfK = 60
+	// refreshInterval 两次后台刷新之间的最短间隔
+	refreshInterval = 5 * time.Second
+)
+
+// skipDirs 除隐藏目录外不索引的目录
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	"__pycache__":  true,
+}
+
+// sourceExts 会被索引的文件类型
+var sourceExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true,
+	".java": true, ".kt": true, ".scala": true, ".rs": true, ".c": true, ".h": true, ".cc": true,
+	".cpp": true, ".hpp": true, ".cs": true, ".rb": true, ".php": true, ".swift": true, ".lua": true,
+	".sh": true, ".sql": true, ".proto": true, ".md": true, ".markdown": true, ".yaml": true,
+	".yml": true, ".toml": true, ".tmpl": true, ".vue": true, ".svelte": true,
+}
+
+// fileEntry 单个文件的索引状态
+type fileEntry struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Chunks  []*Chunk  `json:"chunks"`
+}
+
+// indexFile 索引文件的格式
+type indexFile struct {
+	Root string `json:"root"`
+	// EmbeddingModel 计算已存储向量所用的模型
+	EmbeddingModel string                `json:"embedding_model,omitempty"`
+	Files          map[string]*fileEntry `json:"files"`
+}
+
+// Index 根目录下源文件的片段索引
+// 首次检索时构建, 之后最多每 refreshInterval 在后台刷新一次, 检索使用刷新前的索引
+type Index struct {
+	root     string
+	path     string
+	embedder embedding.Embedder
+	model    string
+	// fileModel 索引文件中记录的向量模型, 未配置向量模型时保留
+	fileModel string
+
+	// refreshMu 串行执行刷新, 刷新在当前快照的副本上进行
+	refreshMu sync.Mutex
+	// mu 保护以下字段, 快照发布后不再修改, 检索打分时不需要持有锁
+	mu         sync.Mutex
+	snap       *snapshot
+	refreshed  time.Time
+	refreshing bool
+}
+
+// snapshot 一次刷新后的索引内容
+type snapshot struct {
+	files map[string]*fileEntry
+	bm25  *search.BM25
+}
+
+// Result 检索命中的片段
+type Result struct {
+	Path string
+	Chunk
+	Score float64
+}
+
+// DefaultIndexFile 返回 root 在用户缓存目录中的索引文件, 不向工作区写入任何文件
+func DefaultIndexFile(root string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, "cosmica", "code", filepath.Base(root)+"-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+// Open 加载 root 存放在 path 的索引, path 为空时使用 DefaultIndexFile
+// embedder 为 nil 时只用 BM25 检索; model 是向量模型的名称, 模型变化时重新计算向量
+func Open(root, path string, embedder embedding.Embedder, model string) (*Index, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", root, err)
+	}
+	if path == "" {
+		path = DefaultIndexFile(root)
+	}
+	x := &Index{root: root, path: path, embedder: embedder, model: model, snap: &snapshot{files: make(map[string]*fileEntry), bm25: search.NewBM25()}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	var f indexFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Root != root {
+		// 损坏的或不属于 root 的索引从头重建
+		slog.Warn("discard code index", "path", path, "error", err)
+		return x, nil
+	}
+	x.fileModel = f.EmbeddingModel
+	for rel, entry := range f.Files {
+		for _, c := range entry.Chunks {
+			if embedder != nil && f.EmbeddingModel != model {
+				c.Embedding = nil
+			}
+		}
+		x.snap.addFile(rel, entry)
+	}
+	return x, nil
+}
+
+// Root 返回被索引的目录
+func (x *Index) Root() string {
+	return x.root
+}
+
+// Refresh 重新切分上次刷新后变化的文件, 移除已删除的文件, 有变化时保存索引
+// 返回重新索引或移除的文件数
+func (x *Index) Refresh(ctx context.Context) (int, error) {
+	x.refreshMu.Lock()
+	defer x.refreshMu.Unlock()
+	cur := x.current()
+	updates := make(map[string]*fileEntry)
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(x.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无法读取的目录直接跳过, 不让整个索引失败
+			if d != nil && d.IsDir() && path != x.root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != x.root && (strings.HasPrefix(name, ".") || skipDirs[name]) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !sourceExts[strings.ToLower(filepath.Ext(name))] {
+			return nil
+		}
+		if len(seen) >= maxFiles {
+			return fs.SkipAll
+		}
+		rel, err := filepath.Rel(x.root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if old, ok := cur.files[rel]; ok && old.ModTime.Equal(info.ModTime()) && old.Size == info.Size() {
+			return nil
+		}
+		entry := &fileEntry{ModTime: info.ModTime(), Size: info.Size()}
+		if info.Size() <= maxFileSize {
+			if data, err := os.ReadFile(path); err == nil && !isBinary(data) {
+				for _, c := range chunkFile(rel, data) {
+					entry.Chunks = append(entry.Chunks, &c)
+				}
+			}
+		}
+		updates[rel] = entry
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("walk %s: %w", x.root, err)
+	}
+	var removed []string
+	for rel := range cur.files {
+		if !seen[rel] {
+			removed = append(removed, rel)
+		}
+	}
+	changed := len(updates) + len(removed)
+	embedded := x.embedMissing(ctx, cur, updates)
+	if changed == 0 && !embedded {
+		x.mu.Lock()
+		x.refreshed = time.Now()
+		x.mu.Unlock()
+		return 0, nil
+	}
+
+	next := cur.clone()
+	for _, rel := range removed {
+		next.removeFile(rel)
+	}
+	for rel, entry := range updates {
+		next.removeFile(rel)
+		next.addFile(rel, entry)
+	}
+	x.mu.Lock()
+	x.snap, x.refreshed = next, time.Now()
+	x.mu.Unlock()
+	if err := x.save(next); err != nil {
+		return changed, err
+	}
+	return changed, nil
+}
+
+// embedMissing 计算仍缺少的向量, 返回是否新增了向量
+// 已发布的片段不会被修改: cur 中缺少向量的文件先复制到 updates; 失败只记录日志, 检索退回只用 BM25
+func (x *Index) embedMissing(ctx context.Context, cur *snapshot, updates map[string]*fileEntry) bool {
+	if x.embedder == nil {
+		return false
+	}
+	for rel, entry := range cur.files {
+		if _, ok := updates[rel]; ok {
+			continue
+		}
+		for _, c := range entry.Chunks {
+			if len(c.Embedding) == 0 {
+				updates[rel] = entry.clone()
+				break
+			}
+		}
+	}
+	var missing []*Chunk
+	for _, entry := range updates {
+		for _, c := range entry.Chunks {
+			if len(c.Embedding) == 0 {
+				missing = append(missing, c)
+			}
+		}
+	}
+	added := false
+	for start := 0; start < len(missing); start += embedBatch {
+		batch := missing[start:min(start+embedBatch, len(missing))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.Name + "\n" + c.Text
+		}
+		vectors, err := x.embedder.EmbedStrings(ctx, texts)
+		if err == nil && len(vectors) != len(batch) {
+			err = fmt.Errorf("embedding model returned %d vectors for %d texts", len(vectors), len(batch))
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "embed code chunks failed", "error", err)
+			return added
+		}
+		for i, c := range batch {
+			c.Embedding = vectors[i]
+		}
+		added = true
+	}
+	return added
+}
+
+// Search 返回与 query 相关的至多 limit 个片段, dir 不为空时只检索该目录(相对根目录)下的文件
+func (x *Index) Search(ctx context.Context, query, dir string, limit int) ([]Result, error) {
+	if err := x.refreshIfStale(ctx); err != nil {
+		return nil, err
+	}
+	snap := x.current()
+	prefix := ""
+	if dir = strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/"); dir != "" && dir != "." {
+		prefix = dir + "/"
+	}
+	inScope := func(id string) bool {
+		return prefix == "" || strings.HasPrefix(id, prefix)
+	}
+
+	// 分别用 BM25 与向量(可用时)排序, 再融合两个排名
+	scores := make(map[string]float64)
+	var ranked []search.Hit
+	for _, h := range snap.bm25.Search(query, 0) {
+		if inScope(h.ID) {
+			ranked = append(ranked, h)
+		}
+	}
+	for i, h := range ranked {
+		scores[h.ID] += 1.0 / float64(rrfK+i+1)
+	}
+	if vectorHits := x.vectorSearch(ctx, snap, query, inScope); len(vectorHits) > 0 {
+		for i, h := range vectorHits {
+			scores[h.ID] += 1.0 / float64(rrfK+i+1)
+		}
+	}
+	hits := make([]search.Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, search.Hit{ID: id, Score: score})
+	}
+	hits = search.Rank(hits, limit)
+
+	results := make([]Result, 0, len(hits))
+	for _, h := range hits {
+		rel, c := snap.chunk(h.ID)
+		if c != nil {
+			results = append(results, Result{Path: rel, Chunk: *c, Score: h.Score})
+		}
+	}
+	return results, nil
+}
+
+// refreshIfStale 首次检索前构建索引, 之后上次刷新早于 refreshInterval 时在后台刷新
+// 检索不等待刷新完成, 变化在刷新结束后的检索中体现
+func (x *Index) refreshIfStale(ctx context.Context) error {
+	x.mu.Lock()
+	built := !x.refreshed.IsZero()
+	stale := built && !x.refreshing && time.Since(x.refreshed) >= refreshInterval
+	if stale {
+		x.refreshing = true
+	}
+	x.mu.Unlock()
+	if !built {
+		_, err := x.Refresh(ctx)
+		return err
+	}
+	if stale {
+		go func() {
+			// 刷新的生命周期长于发起它的工具调用
+			ctx := context.WithoutCancel(ctx)
+			if _, err := x.Refresh(ctx); err != nil {
+				slog.WarnContext(ctx, "refresh code index failed", "error", err)
+			}
+			x.mu.Lock()
+			x.refreshing = false
+			x.mu.Unlock()
+		}()
+	}
+	return nil
+}
+
+// current 返回最新发布的快照
+func (x *Index) current() *snapshot {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.snap
+}
+
+// vectorSearch 按与 query 的相似度为有向量的片段排序, 没有向量模型或调用失败时返回空
+func (x *Index) vectorSearch(ctx context.Context, snap *snapshot, query string, inScope func(id string) bool) []search.Hit {
+	if x.embedder == nil {
+		return nil
+	}
+	vectors, err := x.embedder.EmbedStrings(ctx, []string{query})
+	if err != nil || len(vectors) != 1 {
+		slog.WarnContext(ctx, "embed code search query failed", "error", err)
+		return nil
+	}
+	var hits []search.Hit
+	for rel, entry := range snap.files {
+		for _, c := range entry.Chunks {
+			id := chunkID(rel, c)
+			if len(c.Embedding) > 0 && inScope(id) {
+				hits = append(hits, search.Hit{ID: id, Score: search.Cosine(vectors[0], c.Embedding)})
+			}
+		}
+	}
+	// 只有最接近的片段参与融合, 排在后面的只是噪声
+	return search.Rank(hits, 50)
+}
+
+// clone 返回 s 的副本, 修改副本不影响在 s 上进行的检索
+func (s *snapshot) clone() *snapshot {
+	return &snapshot{files: maps.Clone(s.files), bm25: s.bm25.Clone()}
+}
+
+func (s *snapshot) addFile(rel string, entry *fileEntry) {
+	s.files[rel] = entry
+	for _, c := range entry.Chunks {
+		s.bm25.Add(chunkID(rel, c), rel+" "+c.Name+"\n"+c.Text)
+	}
+}
+
+func (s *snapshot) removeFile(rel string) {
+	entry, ok := s.files[rel]
+	if !ok {
+		return
+	}
+	for _, c := range entry.Chunks {
+		s.bm25.Remove(chunkID(rel, c))
+	}
+	delete(s.files, rel)
+}
+
+// chunk 按 chunkID 生成的 id 查找片段
+func (s *snapshot) chunk(id string) (string, *Chunk) {
+	rel, line, ok := strings.Cut(id, "#")
+	if !ok {
+		return "", nil
+	}
+	start, err := strconv.Atoi(line)
+	if err != nil || s.files[rel] == nil {
+		return "", nil
+	}
+	for _, c := range s.files[rel].Chunks {
+		if c.Start == start {
+			return rel, c
+		}
+	}
+	return "", nil
+}
+
+// clone 复制条目及其片段, 向量可以加到副本上
+func (e *fileEntry) clone() *fileEntry {
+	cp := &fileEntry{ModTime: e.ModTime, Size: e.Size, Chunks: make([]*Chunk, len(e.Chunks))}
+	for i, c := range e.Chunks {
+		c := *c
+		cp.Chunks[i] = &c
+	}
+	return cp
+}
+
+// chunkID 以文件与起始行标识片段
+func chunkID(rel string, c *Chunk) string {
+	return rel + "#" + strconv.Itoa(c.Start)
+}
+
+// save 把 snap 写入索引文件, 写入是原子的, 崩溃不会留下不完整的索引
+func (x *Index) save(snap *snapshot) error {
+	f := indexFile{Root: x.root, EmbeddingModel: x.fileModel, Files: snap.files}
+	if x.embedder != nil {
+		f.EmbeddingModel = x.model
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("encode index: %w", err)
+	}
+	if err := fsutil.WriteFile(x.path, data); err != nil {
+		return fmt.Errorf("save index: %w", err)
+	}
+	return nil
+}
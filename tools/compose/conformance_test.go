@@ -0,0 +1,47 @@
+package compose_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/tools/compose"
+	"github.com/bootun/cosmica/tools/tooltest"
+	"github.com/cloudwego/eino/schema"
+)
+
+// stubAgent 把收到的问题原样作为结论返回
+type stubAgent struct{}
+
+func (stubAgent) HandleQuestion(ctx context.Context, question string, history []*schema.Message) ([]*schema.Message, error) {
+	history = append(history,
+		schema.UserMessage(question),
+		schema.AssistantMessage("done: "+question, nil),
+	)
+	return history, nil
+}
+
+func TestConformance(t *testing.T) {
+	creator := compose.NewAgentCreator(func(ctx context.Context, task string) (agent.Agent, error) {
+		return stubAgent{}, nil
+	})
+	tooltest.Conformance(t, creator,
+		tooltest.Case{
+			Name: "delegate",
+			Args: `{"name":"netizen","task":"look it up"}`,
+			Check: func(t testing.TB, out string) {
+				// 任务经 assistant 模板包装后作为问题交给子 agent
+				if !strings.HasPrefix(out, "done: ") || !strings.Contains(out, "look it up") || !strings.Contains(out, "netizen") {
+					t.Errorf("got %q", out)
+				}
+			},
+		},
+		tooltest.Case{
+			Name:        "unknown_assistant",
+			Args:        `{"name":"nobody","task":"look it up"}`,
+			WantErr:     true,
+			ErrContains: []string{"name"},
+		},
+	)
+}
@@ -4,37 +4,49 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"slices"
+	"strings"
 
 	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/prompts"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 )
 
 type agentCreator struct {
 	createFunc agent.CreateAgentFunc
+	// names lists the assistants createFunc can create.
+	names []string
 }
 
 func NewAgentCreator(createFunc agent.CreateAgentFunc) *agentCreator {
-	return &agentCreator{createFunc: createFunc}
+	return &agentCreator{createFunc: createFunc, names: []string{agent.AgentNetizen}}
+}
+
+// SubAgents returns the names of the assistants tasks can be delegated to.
+func (ac *agentCreator) SubAgents() []string {
+	return ac.names
 }
 
 func (ac *agentCreator) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	// The description and the assistant list come from the create_agent prompt template.
+	locale := prompts.Locale()
+	subAgents, err := prompts.SubAgents(ctx, locale, ac.names...)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := prompts.Render(ctx, prompts.CreateAgent, prompts.Vars{Locale: locale, SubAgents: subAgents})
+	if err != nil {
+		return nil, err
+	}
 	return &schema.ToolInfo{
 		Name: "create_agent",
-		Desc: `create an assistant to help you solve task. 
-You can specify the tools that the assistant can use, define the problem it wants to solve, and the assistant will return the final result to you. 
-Generally speaking, tasks assigned to assistants should not be too complex, otherwise assistants may not be able to handle the work well. 
-If there are really complex tasks, you can try breaking them down into small tasks and assigning each task to an assistant to execute.
-
-this is the assistant list:
-- netizen: a netizen who can operate the browser and answer questions, if you want to use the browser, you can create netizen assistant.
-`,
+		Desc: desc,
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
 			"name": {
 				Desc:     "the name of the assistant",
 				Type:     schema.String,
-				Enum:     []string{"netizen"},
+				Enum:     ac.names,
 				Required: true,
 			},
 			"task": {
@@ -52,7 +64,10 @@ func (ac *agentCreator) InvokableRun(ctx context.Context, argumentsInJSON string
 		return "", fmt.Errorf("parse params: %w", err)
 	}
 
-	agent, err := ac.createFunc(ctx, param.Task)
+	if src, ok := agent.SourceFrom(ctx); ok {
+		agent.Emit(ctx, agent.SubAgentSpawned{Source: src, Name: param.Name, Task: param.Task})
+	}
+	assistant, err := ac.createFunc(ctx, param.Task)
 	if err != nil {
 		return "", fmt.Errorf("create agent: %w", err)
 	}
@@ -68,18 +83,27 @@ func (ac *agentCreator) InvokableRun(ctx context.Context, argumentsInJSON string
 	// if err = agent.AddTools(ts); err != nil {
 	// 	return "", fmt.Errorf("bind tools: %w", err)
 	// }
-	res, err := agent.HandleQuestion(ctx, param.Task, []*schema.Message{
-		schema.SystemMessage("You are an assistant to help solve the task, after completing all tasks, you need to summarize the content and results of the tasks and call the tool to end the conversation"),
+	// The assistant template wraps the task into the question, so the assistant keeps
+	// its own system prompt and the project instructions.
+	question, err := prompts.Render(ctx, prompts.Assistant, prompts.Vars{
+		Data: map[string]any{"name": param.Name, "task": param.Task},
 	})
+	if err != nil {
+		return "", fmt.Errorf("render assistant prompt: %w", err)
+	}
+	res, err := assistant.HandleQuestion(ctx, question, nil)
 	if err != nil {
 		return "", fmt.Errorf("handle question: %w", err)
 	}
-	log.Printf("res: %v", res)
-	panic("not implemented") // TODO: Implement this function and return the appropriate dat
-	return "", nil
+	// the assistant was asked to summarize before ending the conversation
+	if summary := agent.Summary(res); summary != "" {
+		return summary, nil
+	}
+	return "the assistant finished without a summary", nil
 }
 
 type createAgentParams struct {
+	Name string `json:"name"`
 	Task string `json:"task"`
 }
 
@@ -88,5 +112,11 @@ func (ac *agentCreator) parseParams(argumentsInJSON string) (*createAgentParams,
 	if err := json.Unmarshal([]byte(argumentsInJSON), &param); err != nil {
 		return nil, fmt.Errorf("unmarshal json: %w", err)
 	}
+	if !slices.Contains(ac.names, param.Name) {
+		return nil, fmt.Errorf("param name must be one of [%s], got %q", strings.Join(ac.names, ", "), param.Name)
+	}
+	if strings.TrimSpace(param.Task) == "" {
+		return nil, fmt.Errorf("param task is required")
+	}
 	return &param, nil
 }
@@ -0,0 +1,62 @@
+package tools_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/agent/common"
+	"github.com/bootun/cosmica/provider/replay"
+)
+
+// conformanceCovered 是各工具包中 TestConformance 覆盖的工具, MCP 工具由 tools/mcp 覆盖
+var conformanceCovered = map[string]string{
+	"bell":           "tools/base",
+	"file_reader":    "tools/file",
+	"dir_reader":     "tools/file",
+	"shell_executor": "tools/shell",
+	"create_agent":   "tools/compose",
+	"memory_save":    "tools/memory",
+	"memory_search":  "tools/memory",
+	"memory_delete":  "tools/memory",
+	"code_search":    "tools/codesearch",
+	"go_symbols":     "tools/gocode",
+	"go_definition":  "tools/gocode",
+	"go_references":  "tools/gocode",
+	"go_type":        "tools/gocode",
+}
+
+// TestConformanceCoversSpacemanTools 启用全部可选工具构建 spaceman, 每个工具都要有一致性测试
+func TestConformanceCoversSpacemanTools(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	files := map[string]string{
+		"config.yml": "memory:\n  file: " + filepath.Join(dir, "memory.json") +
+			"\ncode_search:\n  index_file: " + filepath.Join(dir, "index.json") + "\n",
+		"go.mod": "module example.com/conformance\n\ngo 1.22\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	sm, err := common.NewSpaceMan(context.Background(), common.WithChatModel(replay.NewScripted()), common.WithOutput(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.(io.Closer).Close()
+	names := sm.(agent.ToolManager).Tools().Names()
+	if len(names) < len(conformanceCovered) {
+		t.Errorf("spaceman has only %d tools: %v", len(names), names)
+	}
+	for _, name := range names {
+		if conformanceCovered[name] == "" {
+			t.Errorf("tool %s has no conformance cases", name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/tools/file"
+	"github.com/bootun/cosmica/tools/tooltest"
+)
+
+func TestFileReaderConformance(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(name, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tooltest.Conformance(t, file.NewFileReader(),
+		tooltest.Case{
+			Name: "whole_file",
+			Args: `{"filename":"` + filepath.ToSlash(name) + `"}`,
+			Check: func(t testing.TB, out string) {
+				if out != "one\ntwo\nthree\n" {
+					t.Errorf("got %q", out)
+				}
+			},
+		},
+		tooltest.Case{
+			Name: "line_range",
+			Args: `{"filename":"` + filepath.ToSlash(name) + `","line":"L2-L3"}`,
+			Check: func(t testing.TB, out string) {
+				if out != "two\nthree\n" {
+					t.Errorf("got %q", out)
+				}
+			},
+		},
+		tooltest.Case{
+			Name:        "bad_line_range",
+			Args:        `{"filename":"` + filepath.ToSlash(name) + `","line":"2-3"}`,
+			WantErr:     true,
+			ErrContains: []string{"L{start}-L{end}"},
+		},
+		tooltest.Case{
+			Name:        "missing_file",
+			Args:        `{"filename":"` + filepath.ToSlash(filepath.Join(dir, "missing.txt")) + `"}`,
+			WantErr:     true,
+			ErrContains: []string{file.ErrFileNotExist.Error()},
+		},
+	)
+}
+
+func TestDirReaderConformance(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tooltest.Conformance(t, file.NewDirReader(),
+		tooltest.Case{
+			Name: "list",
+			Args: `{"dirname":"` + filepath.ToSlash(dir) + `"}`,
+			Check: func(t testing.TB, out string) {
+				if !strings.Contains(out, `"a.txt"`) || !strings.Contains(out, `"sub/"`) {
+					t.Errorf("got %s", out)
+				}
+			},
+		},
+		tooltest.Case{
+			Name:        "missing_dir",
+			Args:        `{"dirname":"` + filepath.ToSlash(filepath.Join(dir, "missing")) + `"}`,
+			WantErr:     true,
+			ErrContains: []string{file.ErrDirNotExist.Error()},
+		},
+	)
+}
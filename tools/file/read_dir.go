@@ -8,48 +8,27 @@ import (
 	"os"
 	"strings"
 
+	"github.com/bootun/cosmica/tools"
 	"github.com/cloudwego/eino/components/tool"
-	"github.com/cloudwego/eino/schema"
 )
 
 var (
 	ErrDirNotExist = errors.New("directory not exist")
 )
 
-// NewDirReader returns a new dirReader instance.
-func NewDirReader() *dirReader {
-	return &dirReader{}
+// NewDirReader returns a tool that lists the entries of a directory.
+func NewDirReader() tool.InvokableTool {
+	return tools.MustNewTypedTool("dir_reader", "list all files in a directory", readDir)
 }
 
-type dirReader struct{}
-
-func (dr *dirReader) Info(ctx context.Context) (*schema.ToolInfo, error) {
-	return &schema.ToolInfo{
-		Name: "dir_reader",
-		Desc: "list all files in a directory",
-		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
-			"dirname": {
-				Desc:     "directory path you want to read",
-				Type:     schema.String,
-				Required: true,
-			},
-			// "recursive": {
-			// 	Desc:     "whether to list files recursively in subdirectories",
-			// 	Type:     schema.Boolean,
-			// 	Required: false,
-			// },
-		}),
-	}, nil
+type dirReaderParams struct {
+	Dirname string `json:"dirname" desc:"directory path you want to read" required:"true"`
+	// Recursive bool `json:"recursive" desc:"whether to list files recursively in subdirectories"`
 }
 
-func (dr *dirReader) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
-	params, err := dr.parseDirReaderParams(argumentsInJSON)
-	if err != nil {
-		return "", fmt.Errorf("解析参数失败: %w", err)
-	}
-
+func readDir(ctx context.Context, params dirReaderParams) (string, error) {
 	if strings.TrimSpace(params.Dirname) == "" {
-		return "", fmt.Errorf("目录名不能为空")
+		return "", fmt.Errorf("参数 dirname 不能为空")
 	}
 
 	// Check if directory exists
@@ -113,16 +92,3 @@ func (dr *dirReader) InvokableRun(ctx context.Context, argumentsInJSON string, o
 
 	return string(result), nil
 }
-
-type dirReaderParams struct {
-	Dirname string `json:"dirname"`
-	// Recursive bool   `json:"recursive"`
-}
-
-func (dr *dirReader) parseDirReaderParams(argumentsInJSON string) (*dirReaderParams, error) {
-	var params dirReaderParams
-	if err := json.Unmarshal([]byte(argumentsInJSON), &params); err != nil {
-		return nil, err
-	}
-	return &params, nil
-}
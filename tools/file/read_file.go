@@ -3,15 +3,14 @@ package file
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/bootun/cosmica/tools"
 	"github.com/cloudwego/eino/components/tool"
-	"github.com/cloudwego/eino/schema"
 )
 
 var (
@@ -19,40 +18,19 @@ var (
 	ErrInvalidLineArg = errors.New("line param must be in format L{start}-L{end}")
 )
 
-// NewFileReader returns a new fileReader instance.
-func NewFileReader() *fileReader {
-	return &fileReader{}
+// NewFileReader returns a tool that reads a file, optionally limited to a line range.
+func NewFileReader() tool.InvokableTool {
+	return tools.MustNewTypedTool("file_reader", "read file content as string format (supports partial read by line numbers)", readFile)
 }
 
-type fileReader struct{}
-
-func (fr *fileReader) Info(ctx context.Context) (*schema.ToolInfo, error) {
-	return &schema.ToolInfo{
-		Name: "file_reader",
-		Desc: "read file content as string format (supports partial read by line numbers)",
-		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
-			"filename": {
-				Desc:     "file name you want to read",
-				Type:     schema.String,
-				Required: true,
-			},
-			"line": {
-				Desc:     "line range you want to read, format is L{start}-L{end}. For example, L1-L200 means you want to read lines 1-200 (inclusive) of this file. If omitted the whole file is returned.",
-				Type:     schema.String,
-				Required: false,
-			},
-		}),
-	}, nil
+type fileReaderParams struct {
+	Filename string `json:"filename" desc:"file name you want to read" required:"true"`
+	Line     string `json:"line" desc:"line range you want to read, format is L{start}-L{end}. For example, L1-L200 means you want to read lines 1-200 (inclusive) of this file. If omitted the whole file is returned."`
 }
 
-func (fr *fileReader) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
-	params, err := fr.parseFileReaderParams(argumentsInJSON)
-	if err != nil {
-		return "", fmt.Errorf("解析参数失败: %w", err)
-	}
-
+func readFile(ctx context.Context, params fileReaderParams) (string, error) {
 	if strings.TrimSpace(params.Filename) == "" {
-		return "", fmt.Errorf("文件名不能为空")
+		return "", fmt.Errorf("参数 filename 不能为空")
 	}
 
 	// If no line param provided – return full file content.
@@ -110,19 +88,6 @@ func (fr *fileReader) InvokableRun(ctx context.Context, argumentsInJSON string,
 	return sb.String(), nil
 }
 
-type fileReaderParams struct {
-	Filename string `json:"filename"`
-	Line     string `json:"line"`
-}
-
-func (fr *fileReader) parseFileReaderParams(argumentsInJSON string) (*fileReaderParams, error) {
-	var params fileReaderParams
-	if err := json.Unmarshal([]byte(argumentsInJSON), &params); err != nil {
-		return nil, err
-	}
-	return &params, nil
-}
-
 // parseLineRange converts a string like "L10-L20" to numerical start,end values.
 func parseLineRange(arg string) (start, end int, err error) {
 	arg = strings.TrimSpace(arg)
@@ -0,0 +1,113 @@
+package gocode
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/tools/tooltest"
+)
+
+func TestConformance(t *testing.T) {
+	w, _ := newTestWorkspace(t)
+	cases := map[string][]tooltest.Case{
+		"go_symbols": {
+			{
+				Name: "package",
+				Args: `{"package":"store"}`,
+				Check: func(t testing.TB, out string) {
+					if !strings.Contains(out, "store/store.go:17 func New() *Store\n") {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+			{
+				Name:        "unknown_package",
+				Args:        `{"package":"nope"}`,
+				WantErr:     true,
+				ErrContains: []string{`"nope" not found`},
+			},
+		},
+		"go_definition": {
+			{
+				Name: "function",
+				Args: `{"symbol":"New"}`,
+				Check: func(t testing.TB, out string) {
+					if out != "store/store.go:17 func New() *Store\n// New returns an empty store.\n" {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+			{
+				Name:        "unknown_symbol",
+				Args:        `{"symbol":"Farewell"}`,
+				WantErr:     true,
+				ErrContains: []string{`"Farewell" not found`},
+			},
+			{
+				Name:        "empty_symbol",
+				Args:        `{"symbol":" "}`,
+				WantErr:     true,
+				ErrContains: []string{"symbol is required"},
+			},
+		},
+		"go_references": {
+			{
+				Name: "method",
+				Args: `{"symbol":"Store.Get"}`,
+				Check: func(t testing.TB, out string) {
+					if !strings.HasPrefix(out, "1 references\nmain.go:14:16: ") {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+			{
+				Name: "unused",
+				Args: `{"symbol":"Store.Len"}`,
+				Check: func(t testing.TB, out string) {
+					if out != "no references to Store.Len" {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+			{
+				Name:        "empty_symbol",
+				Args:        `{"symbol":""}`,
+				WantErr:     true,
+				ErrContains: []string{"symbol is required"},
+			},
+		},
+		"go_type": {
+			{
+				Name: "struct",
+				Args: `{"type":"Store"}`,
+				Check: func(t testing.TB, out string) {
+					if !strings.Contains(out, "(pointer receiver)\n") {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+			{
+				Name:        "not_a_type",
+				Args:        `{"type":"Store.Get"}`,
+				WantErr:     true,
+				ErrContains: []string{"is not a type"},
+			},
+			{
+				Name:        "empty_type",
+				Args:        `{"type":""}`,
+				WantErr:     true,
+				ErrContains: []string{"type is required"},
+			},
+		},
+	}
+	for _, tl := range NewTools(w) {
+		info, err := tl.Info(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(info.Name, func(t *testing.T) {
+			tooltest.Conformance(t, tl, cases[info.Name]...)
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package gocode
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/components/tool"
+	"golang.org/x/tools/go/packages"
+)
+
+// maxReferences 被大量使用的符号只列出这么多引用, 保证 go_references 的输出易读
+const maxReferences = 100
+
+// testsNote 附加在每个工具的描述后: 测试文件不会被加载, agent 应当搜索 _test.go 文件, 而不是相信空结果
+const testsNote = ". Test files (_test.go) are not analyzed, search them with other tools"
+
+// NewTools 返回基于 w 所在 Go 模块的 go_symbols、go_definition、go_references 与 go_type 工具
+func NewTools(w *Workspace) []tool.InvokableTool {
+	return []tool.InvokableTool{
+		tools.MustNewTypedTool("go_symbols", "list the types, functions, methods, variables and constants of a Go package with file:line locations"+testsNote, w.symbols),
+		tools.MustNewTypedTool("go_definition", "find where a Go symbol is defined, returns file:line, its declaration and doc comment. "+
+			"Symbols are written as Name, Type.Method, pkg.Name or pkg.Type.Field"+testsNote, w.definition),
+		tools.MustNewTypedTool("go_references", "find every reference to a Go symbol in the module with file:line and the source line. "+
+			"Symbols are written as Name, Type.Method, pkg.Name or pkg.Type.Field"+testsNote, w.references),
+		tools.MustNewTypedTool("go_type", "show the method set of a Go type and the interfaces of the module it implements, "+
+			"or, for an interface, the types of the module implementing it"+testsNote, w.typeInfo),
+	}
+}
+
+type symbolsParams struct {
+	Package  string `json:"package" desc:"import path, directory relative to the module root or package name, defaults to the package in the current directory"`
+	Exported bool   `json:"exported" desc:"only list exported symbols"`
+}
+
+func (w *Workspace) symbols(ctx context.Context, params symbolsParams) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pkgs, err := w.load(ctx)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, p := range pkgs {
+		if !w.matchPackage(p, params.Package) {
+			continue
+		}
+		fmt.Fprintf(&b, "package %s (%s)\n", p.Name, p.PkgPath)
+		scope := p.Types.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if params.Exported && !obj.Exported() {
+				continue
+			}
+			fmt.Fprintf(&b, "%s %s\n", w.position(obj.Pos()), describe(obj))
+			tn, ok := obj.(*types.TypeName)
+			if !ok || types.IsInterface(tn.Type()) {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				if m := named.Method(i); !params.Exported || m.Exported() {
+					fmt.Fprintf(&b, "%s %s\n", w.position(m.Pos()), describe(m))
+				}
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("package %q not found in the module", params.Package)
+	}
+	return b.String(), nil
+}
+
+type symbolParams struct {
+	Symbol string `json:"symbol" desc:"the symbol, e.g. NewServer, Server.Start, http.Server or mypkg.Server.Addr" required:"true"`
+}
+
+// resolve 加载包并查找符号, 没有匹配时返回错误
+func (w *Workspace) resolve(ctx context.Context, symbol string) ([]*packages.Package, []found, error) {
+	if strings.TrimSpace(symbol) == "" {
+		return nil, nil, fmt.Errorf("symbol is required")
+	}
+	pkgs, err := w.load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := w.lookup(pkgs, symbol)
+	if len(res) == 0 {
+		return nil, nil, fmt.Errorf("symbol %q not found in the module", symbol)
+	}
+	return pkgs, res, nil
+}
+
+func (w *Workspace) definition(ctx context.Context, params symbolParams) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, res, err := w.resolve(ctx, params.Symbol)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i, f := range res {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s\n", w.position(f.obj.Pos()), describe(f.obj))
+		if d := doc(f.pkg, f.obj); d != "" {
+			for _, line := range strings.Split(d, "\n") {
+				fmt.Fprintf(&b, "// %s\n", line)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func (w *Workspace) references(ctx context.Context, params symbolParams) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pkgs, res, err := w.resolve(ctx, params.Symbol)
+	if err != nil {
+		return "", err
+	}
+	targets := make(map[types.Object]bool, len(res))
+	for _, f := range res {
+		targets[f.obj] = true
+	}
+	var positions []token.Position
+	for _, p := range pkgs {
+		for ident, obj := range p.TypesInfo.Uses {
+			if targets[obj] || (obj != nil && targets[originOf(obj)]) {
+				positions = append(positions, w.fset.Position(ident.Pos()))
+			}
+		}
+	}
+	if len(positions) == 0 {
+		return fmt.Sprintf("no references to %s", params.Symbol), nil
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Filename != positions[j].Filename {
+			return positions[i].Filename < positions[j].Filename
+		}
+		return positions[i].Offset < positions[j].Offset
+	})
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d references\n", len(positions))
+	lines := make(map[string][]string)
+	for i, pos := range positions {
+		if i == maxReferences {
+			fmt.Fprintf(&b, "... %d more\n", len(positions)-i)
+			break
+		}
+		if _, ok := lines[pos.Filename]; !ok {
+			data, _ := os.ReadFile(pos.Filename)
+			lines[pos.Filename] = strings.Split(string(data), "\n")
+		}
+		src := ""
+		if l := lines[pos.Filename]; pos.Line-1 < len(l) {
+			src = strings.TrimSpace(l[pos.Line-1])
+		}
+		fmt.Fprintf(&b, "%s:%d:%d: %s\n", w.rel(pos.Filename), pos.Line, pos.Column, src)
+	}
+	return b.String(), nil
+}
+
+// originOf 把泛型函数、方法或字段的实例映射到其泛型声明
+func originOf(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+type typeParams struct {
+	Type string `json:"type" desc:"the type, e.g. Server or mypkg.Handler" required:"true"`
+}
+
+func (w *Workspace) typeInfo(ctx context.Context, params typeParams) (string, error) {
+	if strings.TrimSpace(params.Type) == "" {
+		return "", fmt.Errorf("type is required")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pkgs, res, err := w.resolve(ctx, params.Type)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, f := range res {
+		tn, ok := f.obj.(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s\n", w.position(tn.Pos()), describe(tn))
+		w.writeMethodSet(&b, tn.Type())
+		if iface, ok := tn.Type().Underlying().(*types.Interface); ok {
+			w.writeImplementations(&b, pkgs, iface)
+		} else {
+			w.writeInterfaces(&b, pkgs, tn)
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("%q is not a type", params.Type)
+	}
+	return b.String(), nil
+}
+
+// writeMethodSet 列出 T 与 *T 的方法, 标出只有 *T 才有的方法
+func (w *Workspace) writeMethodSet(b *strings.Builder, typ types.Type) {
+	valueSet := types.NewMethodSet(typ)
+	ptrSet := valueSet
+	if !types.IsInterface(typ) {
+		ptrSet = types.NewMethodSet(types.NewPointer(typ))
+	}
+	if ptrSet.Len() == 0 {
+		b.WriteString("no methods\n")
+		return
+	}
+	b.WriteString("methods:\n")
+	for i := 0; i < ptrSet.Len(); i++ {
+		m := ptrSet.At(i).Obj()
+		note := ""
+		if valueSet.Lookup(m.Pkg(), m.Name()) == nil {
+			note = " (pointer receiver)"
+		}
+		fmt.Fprintf(b, "  %s %s%s\n", w.position(m.Pos()), describe(m), note)
+	}
+}
+
+// writeImplementations 列出模块中实现了 iface 的具名类型
+func (w *Workspace) writeImplementations(b *strings.Builder, pkgs []*packages.Package, iface *types.Interface) {
+	var impls []string
+	for _, p := range pkgs {
+		scope := p.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || types.IsInterface(tn.Type()) || tn.IsAlias() {
+				continue
+			}
+			switch {
+			case types.Implements(tn.Type(), iface):
+				impls = append(impls, fmt.Sprintf("  %s %s.%s", w.position(tn.Pos()), p.Name, tn.Name()))
+			case types.Implements(types.NewPointer(tn.Type()), iface):
+				impls = append(impls, fmt.Sprintf("  %s *%s.%s", w.position(tn.Pos()), p.Name, tn.Name()))
+			}
+		}
+	}
+	if len(impls) == 0 {
+		b.WriteString("no implementations in the module\n")
+		return
+	}
+	b.WriteString("implemented by:\n" + strings.Join(impls, "\n") + "\n")
+}
+
+// writeInterfaces 列出 tn 或 *tn 实现的模块内接口以及 error
+func (w *Workspace) writeInterfaces(b *strings.Builder, pkgs []*packages.Package, tn *types.TypeName) {
+	var ifaces []string
+	check := func(name string, obj types.Object) {
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok || iface.NumMethods() == 0 || obj == types.Object(tn) {
+			return
+		}
+		switch {
+		case types.Implements(tn.Type(), iface):
+			ifaces = append(ifaces, fmt.Sprintf("  %s %s", w.position(obj.Pos()), name))
+		case types.Implements(types.NewPointer(tn.Type()), iface):
+			ifaces = append(ifaces, fmt.Sprintf("  %s %s (pointer receiver)", w.position(obj.Pos()), name))
+		}
+	}
+	check("error", types.Universe.Lookup("error"))
+	for _, p := range pkgs {
+		scope := p.Types.Scope()
+		for _, name := range scope.Names() {
+			if obj, ok := scope.Lookup(name).(*types.TypeName); ok {
+				check(p.Name+"."+name, obj)
+			}
+		}
+	}
+	if len(ifaces) == 0 {
+		b.WriteString("implements no interface of the module\n")
+		return
+	}
+	b.WriteString("implements:\n" + strings.Join(ifaces, "\n") + "\n")
+}
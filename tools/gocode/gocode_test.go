@@ -0,0 +1,234 @@
+package gocode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var module = map[string]string{
+	"go.mod": "module example.com/shop\n\ngo 1.22\n",
+	"store/store.go": `package store
+
+import "fmt"
+
+// Store keeps items in memory.
+type Store struct {
+	// Items are the stored items by name.
+	Items map[string]int
+}
+
+// Getter reads items.
+type Getter interface {
+	Get(name string) (int, error)
+}
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{Items: map[string]int{}}
+}
+
+// Get returns the count of name.
+func (s *Store) Get(name string) (int, error) {
+	n, ok := s.Items[name]
+	if !ok {
+		return 0, fmt.Errorf("no %s", name)
+	}
+	return n, nil
+}
+
+func (s Store) Len() int { return len(s.Items) }
+
+type notFound string
+
+func (e notFound) Error() string { return string(e) }
+`,
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/shop/store"
+)
+
+func main() {
+	s := store.New()
+	s.Items["apple"] = 1
+	var g store.Getter = s
+	fmt.Println(g.Get("apple"))
+	fmt.Println(s.Get("pear"))
+}
+`,
+}
+
+func newTestWorkspace(t *testing.T) (*Workspace, map[string]func(args string) string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range module {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := NewWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := make(map[string]func(args string) string)
+	for _, tl := range NewTools(w) {
+		info, err := tl.Info(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		run[info.Name] = func(args string) string {
+			t.Helper()
+			out, err := tl.InvokableRun(context.Background(), args)
+			if err != nil {
+				t.Fatalf("%s %s: %v", info.Name, args, err)
+			}
+			return out
+		}
+	}
+	return w, run
+}
+
+func TestSymbols(t *testing.T) {
+	_, run := newTestWorkspace(t)
+	out := run["go_symbols"](`{"package":"store"}`)
+	for _, want := range []string{
+		"package store (example.com/shop/store)\n",
+		"store/store.go:6 type Store struct\n",
+		"store/store.go:12 type Getter interface\n",
+		"store/store.go:17 func New() *Store\n",
+		"store/store.go:22 func (*Store) Get(name string) (int, error)\n",
+		"store/store.go:30 func (Store) Len() int\n",
+		"store/store.go:32 type notFound string\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("go_symbols does not contain %q:\n%s", want, out)
+		}
+	}
+	if out := run["go_symbols"](`{"package":"example.com/shop/store","exported":true}`); strings.Contains(out, "notFound") {
+		t.Errorf("unexported symbol listed:\n%s", out)
+	}
+	if out := run["go_symbols"](`{}`); !strings.HasPrefix(out, "package main (example.com/shop)\n") {
+		t.Errorf("default package:\n%s", out)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	_, run := newTestWorkspace(t)
+	for symbol, want := range map[string]string{
+		"New":             "store/store.go:17 func New() *Store\n// New returns an empty store.\n",
+		"store.Store.Get": "store/store.go:22 func (*Store) Get(name string) (int, error)\n// Get returns the count of name.\n",
+		"Store.Items":     "store/store.go:8 field Items map[string]int\n// Items are the stored items by name.\n",
+		"Getter.Get":      "store/store.go:13 func (Getter) Get(name string) (int, error)\n",
+	} {
+		if out := run["go_definition"](`{"symbol":"` + symbol + `"}`); out != want {
+			t.Errorf("go_definition %s = %q, want %q", symbol, out, want)
+		}
+	}
+
+	out := run["go_references"](`{"symbol":"Store.Get"}`)
+	want := "1 references\nmain.go:14:16: fmt.Println(s.Get(\"pear\"))\n"
+	if out != want {
+		t.Errorf("go_references = %q, want %q", out, want)
+	}
+	if out := run["go_references"](`{"symbol":"Items"}`); !strings.HasPrefix(out, "4 references\nmain.go:11:4: s.Items[\"apple\"] = 1\n") {
+		t.Errorf("go_references Items:\n%s", out)
+	}
+}
+
+func TestTypeInfo(t *testing.T) {
+	_, run := newTestWorkspace(t)
+	out := run["go_type"](`{"type":"Store"}`)
+	for _, want := range []string{
+		"store/store.go:22 func (*Store) Get(name string) (int, error) (pointer receiver)\n",
+		"store/store.go:30 func (Store) Len() int\n",
+		"implements:\n  store/store.go:12 store.Getter (pointer receiver)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("go_type Store does not contain %q:\n%s", want, out)
+		}
+	}
+	if out := run["go_type"](`{"type":"notFound"}`); !strings.Contains(out, "builtin error") {
+		t.Errorf("go_type notFound:\n%s", out)
+	}
+	if out := run["go_type"](`{"type":"store.Getter"}`); !strings.Contains(out, "implemented by:\n  store/store.go:6 *store.Store\n") {
+		t.Errorf("go_type Getter:\n%s", out)
+	}
+}
+
+func TestReloadsChangedFiles(t *testing.T) {
+	w, run := newTestWorkspace(t)
+	run["go_symbols"](`{"package":"store"}`)
+	path := filepath.Join(w.dir, "store", "extra.go")
+	if err := os.WriteFile(path, []byte("package store\n\nfunc Extra() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if out := run["go_definition"](`{"symbol":"Extra"}`); out != "store/extra.go:3 func Extra()\n" {
+		t.Errorf("go_definition Extra = %q", out)
+	}
+}
+
+func TestWorkspaceUsesModuleRoot(t *testing.T) {
+	w, _ := newTestWorkspace(t)
+	nested := filepath.Join(w.dir, "tools", "gen")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"go.mod":  "module example.com/gen\n\ngo 1.22\n",
+		"main.go": "package main\n\nfunc Generate() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(nested, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// opened in a package directory, the workspace still covers the whole module
+	sub, err := NewWorkspace(filepath.Join(w.dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.root != w.dir {
+		t.Errorf("root = %s, want %s", sub.root, w.dir)
+	}
+	ctx := context.Background()
+	invoke := func(name, args string) (string, error) {
+		t.Helper()
+		for _, tl := range NewTools(sub) {
+			if info, err := tl.Info(ctx); err == nil && info.Name == name {
+				return tl.InvokableRun(ctx, args)
+			}
+		}
+		t.Fatalf("no tool %s", name)
+		return "", nil
+	}
+	if out, err := invoke("go_symbols", `{}`); err != nil || !strings.HasPrefix(out, "package store (example.com/shop/store)\n") {
+		t.Errorf("default package = %q, %v", out, err)
+	}
+	// locations are relative to the directory the workspace was opened in
+	if out, err := invoke("go_definition", `{"symbol":"New"}`); err != nil || !strings.HasPrefix(out, "store.go:17 ") {
+		t.Errorf("go_definition New = %q, %v", out, err)
+	}
+	// the nested module is not part of the workspace
+	if _, err := invoke("go_definition", `{"symbol":"Generate"}`); err == nil {
+		t.Error("symbols of a nested module should not be found")
+	}
+
+	if _, err := NewWorkspace(t.TempDir()); !errors.Is(err, ErrNoModule) {
+		t.Errorf("outside a module: %v", err)
+	}
+}
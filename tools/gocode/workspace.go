@@ -0,0 +1,335 @@
+// Package gocode 基于 go/packages 提供理解 Go 代码的工具: 列出包的符号、查找定义与引用、查看方法集与接口实现, 结果都以 file:line 的位置给出
+package gocode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports |
+	packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo
+
+// ErrNoModule 不在 Go 模块中时由 ModuleRoot 与 NewWorkspace 返回
+var ErrNoModule = errors.New("not in a Go module")
+
+// Workspace 某个目录所在的 Go 模块中的包, 首次使用时加载, 有 Go 文件变化时重新加载, 不加载测试文件
+type Workspace struct {
+	// root 模块根目录, 只加载该模块的包
+	root string
+	// dir 打开工作区时所在的目录, 位置相对于它给出
+	dir string
+
+	mu    sync.Mutex
+	pkgs  []*packages.Package
+	fset  *token.FileSet
+	stamp string
+}
+
+// NewWorkspace 返回 dir 所在 Go 模块的工作区, dir 不在模块中时返回 ErrNoModule
+func NewWorkspace(dir string) (*Workspace, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", dir, err)
+	}
+	root, err := ModuleRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Workspace{root: root, dir: dir}, nil
+}
+
+// ModuleRoot 按 go env GOMOD 返回 dir 所在模块的根目录, 与 go 命令一样遵循 GO111MODULE 与 GOFLAGS, 不在模块中时返回 ErrNoModule
+func ModuleRoot(dir string) (string, error) {
+	cmd := exec.Command("go", "env", "GOMOD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go env GOMOD: %w", err)
+	}
+	// 禁用模块时 GOMOD 为空, 没有 go.mod 时为 os.DevNull
+	gomod := strings.TrimSpace(string(out))
+	if gomod == "" || gomod == os.DevNull {
+		return "", ErrNoModule
+	}
+	return filepath.Dir(gomod), nil
+}
+
+// load 返回模块中的包, 上次加载后有 Go 文件新增、删除或修改时重新加载
+func (w *Workspace) load(ctx context.Context) ([]*packages.Package, error) {
+	stamp, err := w.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	if w.pkgs != nil && stamp == w.stamp {
+		return w.pkgs, nil
+	}
+	fset := token.NewFileSet()
+	pkgs, err := packages.Load(&packages.Config{Context: ctx, Mode: loadMode, Dir: w.root, Fset: fset}, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("load packages: %w", err)
+	}
+	// 类型检查失败的包仍然有用, 忽略其中的错误
+	var loaded []*packages.Package
+	for _, p := range pkgs {
+		if p.Types != nil && p.TypesInfo != nil {
+			loaded = append(loaded, p)
+		}
+	}
+	if len(loaded) == 0 {
+		return nil, fmt.Errorf("no Go packages found in %s", w.root)
+	}
+	w.pkgs, w.fset, w.stamp = loaded, fset, stamp
+	return loaded, nil
+}
+
+// fingerprint 对模块中非测试 Go 文件与 go.mod 的名称、大小和修改时间计算哈希, 与 ./... 一样跳过嵌套的模块
+func (w *Workspace) fingerprint() (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path == w.root {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" || name == "node_modules" {
+				return fs.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if (!strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go")) && name != "go.mod" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("walk %s: %w", w.root, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// position 把 pos 格式化为相对于工作区的 file:line
+func (w *Workspace) position(pos token.Pos) string {
+	p := w.fset.Position(pos)
+	if !p.IsValid() {
+		// 只有 error 等预声明对象没有位置
+		return "builtin"
+	}
+	return fmt.Sprintf("%s:%d", w.rel(p.Filename), p.Line)
+}
+
+func (w *Workspace) rel(filename string) string {
+	if rel, err := filepath.Rel(w.dir, filename); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filename
+}
+
+// matchPackage 报告 pkg 是否与 pattern 匹配, pattern 可以是导入路径、路径后缀、相对于工作区的目录或包名
+func (w *Workspace) matchPackage(pkg *packages.Package, pattern string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(pattern), "./"), "/")
+	if pattern == "" || pattern == "." {
+		return len(pkg.GoFiles) > 0 && filepath.Dir(pkg.GoFiles[0]) == w.dir
+	}
+	if pkg.PkgPath == pattern || strings.HasSuffix(pkg.PkgPath, "/"+pattern) || pkg.Name == pattern {
+		return true
+	}
+	return len(pkg.GoFiles) > 0 && w.rel(filepath.Dir(pkg.GoFiles[0])) == pattern
+}
+
+// found 符号查询匹配到的对象
+type found struct {
+	obj types.Object
+	pkg *packages.Package
+}
+
+// lookup 解析写作 Name、Type.Member、pkg.Name 或 pkg.Type.Member 的符号, pkg 是包名或导入路径
+// 裸名称同时匹配包级对象与成员时优先包级对象
+func (w *Workspace) lookup(pkgs []*packages.Package, symbol string) []found {
+	symbol = strings.TrimSpace(strings.TrimPrefix(symbol, "*"))
+	parts := strings.Split(symbol, ".")
+	// github.com/x/y.Name 这样的导入路径在最后一个斜杠之前也有点号
+	if i := strings.LastIndex(symbol, "/"); i >= 0 {
+		rest := strings.Split(symbol[i:], ".")
+		parts = append([]string{symbol[:i] + rest[0]}, rest[1:]...)
+	}
+	var res []found
+	add := func(p *packages.Package, obj types.Object) {
+		if obj != nil {
+			res = append(res, found{obj: obj, pkg: p})
+		}
+	}
+	member := func(p *packages.Package, typeName, name string) {
+		tn, ok := p.Types.Scope().Lookup(typeName).(*types.TypeName)
+		if !ok {
+			return
+		}
+		typ := tn.Type()
+		if !types.IsInterface(typ) {
+			typ = types.NewPointer(typ)
+		}
+		obj, _, _ := types.LookupFieldOrMethod(typ, true, p.Types, name)
+		// 提升的成员在其声明处报告
+		add(p, obj)
+	}
+	for _, p := range pkgs {
+		switch len(parts) {
+		case 1:
+			add(p, p.Types.Scope().Lookup(parts[0]))
+		case 2:
+			if w.isPackage(p, parts[0]) {
+				add(p, p.Types.Scope().Lookup(parts[1]))
+			} else {
+				member(p, parts[0], parts[1])
+			}
+		case 3:
+			if w.isPackage(p, parts[0]) {
+				member(p, parts[1], parts[2])
+			}
+		}
+	}
+	if len(res) > 0 || len(parts) != 1 {
+		return dedup(res)
+	}
+	// 不是包级对象的裸名称可能是任意类型的方法或字段
+	for _, p := range pkgs {
+		for _, name := range p.Types.Scope().Names() {
+			if _, ok := p.Types.Scope().Lookup(name).(*types.TypeName); ok {
+				member(p, name, parts[0])
+			}
+		}
+	}
+	return dedup(res)
+}
+
+func (w *Workspace) isPackage(p *packages.Package, name string) bool {
+	return p.Name == name || p.PkgPath == name || strings.HasSuffix(p.PkgPath, "/"+name)
+}
+
+// dedup 去掉重复的对象, 例如提升到多个类型中的同一个方法
+func dedup(res []found) []found {
+	seen := make(map[types.Object]bool)
+	out := res[:0]
+	for _, f := range res {
+		if !seen[f.obj] {
+			seen[f.obj] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// describe 返回 obj 的单行声明, 类型名相对于其所在的包限定
+func describe(obj types.Object) string {
+	qf := types.RelativeTo(obj.Pkg())
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		kind := types.TypeString(obj.Type().Underlying(), qf)
+		switch obj.Type().Underlying().(type) {
+		case *types.Struct:
+			kind = "struct"
+		case *types.Interface:
+			kind = "interface"
+		}
+		return "type " + obj.Name() + " " + kind
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if recv := sig.Recv(); recv != nil {
+			return fmt.Sprintf("func (%s) %s%s", types.TypeString(recv.Type(), qf), obj.Name(), strings.TrimPrefix(types.TypeString(sig, qf), "func"))
+		}
+		return types.ObjectString(obj, qf)
+	case *types.Var:
+		if obj.IsField() {
+			return "field " + obj.Name() + " " + types.TypeString(obj.Type(), qf)
+		}
+	}
+	return types.ObjectString(obj, qf)
+}
+
+// doc 返回 obj 声明处的文档注释, 仅限已加载的文件
+func doc(pkg *packages.Package, obj types.Object) string {
+	for _, f := range pkg.Syntax {
+		if obj.Pos() < f.Pos() || obj.Pos() > f.End() {
+			continue
+		}
+		var text string
+		ast.Inspect(f, func(n ast.Node) bool {
+			if text != "" || n == nil || n.Pos() > obj.Pos() || n.End() < obj.Pos() {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Name.Pos() == obj.Pos() {
+					text = n.Doc.Text()
+				}
+			case *ast.GenDecl:
+				for _, spec := range n.Specs {
+					if declares(spec, obj.Pos()) {
+						// 只有一个 spec 时文档注释通常写在声明上
+						if text = specDoc(spec).Text(); text == "" {
+							text = n.Doc.Text()
+						}
+					}
+				}
+			case *ast.Field:
+				for _, name := range n.Names {
+					if name.Pos() == obj.Pos() && n.Doc != nil {
+						text = n.Doc.Text()
+					}
+				}
+			}
+			return true
+		})
+		return strings.TrimSpace(text)
+	}
+	return ""
+}
+
+// declares 报告 spec 是否在 pos 处声明了该名称
+func declares(spec ast.Spec, pos token.Pos) bool {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return spec.Name.Pos() == pos
+	case *ast.ValueSpec:
+		for _, name := range spec.Names {
+			if name.Pos() == pos {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return spec.Doc
+	case *ast.ValueSpec:
+		return spec.Doc
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package mcp 连接外部的 MCP (Model Context Protocol) 服务, 把其中的工具、资源与提示词模板作为 eino 工具提供给 agent
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Namespace MCP 工具在 ToolSet 中的命名空间前缀, 服务 github 的工具注册为 mcp.github.<tool>
+const Namespace = "mcp"
+
+// Server 已连接的 MCP 服务
+type Server struct {
+	name   string
+	client *client.Client
+	caps   mcpgo.ServerCapabilities
+}
+
+// Connect 按 cfg 启动或连接服务, 并完成 MCP 的初始化握手
+func Connect(ctx context.Context, name string, cfg config.MCPServer) (*Server, error) {
+	var c *client.Client
+	var err error
+	switch {
+	case cfg.Command != "":
+		env := os.Environ()
+		for k, v := range cfg.Env {
+			env = append(env, k+"="+v)
+		}
+		c, err = client.NewStdioMCPClient(cfg.Command, env, cfg.Args...)
+		if err != nil {
+			return nil, fmt.Errorf("start mcp server %s: %w", name, err)
+		}
+	case cfg.URL != "":
+		c, err = client.NewStreamableHttpClient(cfg.URL, transport.WithHTTPHeaders(cfg.Headers))
+		if err != nil {
+			return nil, fmt.Errorf("create mcp client %s: %w", name, err)
+		}
+		if err := c.Start(ctx); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("connect mcp server %s: %w", name, err)
+		}
+	default:
+		return nil, fmt.Errorf("mcp server %s: either command or url is required", name)
+	}
+
+	req := mcpgo.InitializeRequest{}
+	req.Params.ProtocolVersion = mcpgo.LATEST_PROTOCOL_VERSION
+	req.Params.ClientInfo = mcpgo.Implementation{Name: "cosmica", Version: "0.1.0"}
+	res, err := c.Initialize(ctx, req)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize mcp server %s: %w", name, err)
+	}
+	return &Server{name: name, client: c, caps: res.Capabilities}, nil
+}
+
+// Name 返回服务在配置中的名称
+func (s *Server) Name() string {
+	return s.name
+}
+
+// Namespace 返回服务的工具在 ToolSet 中的命名空间
+func (s *Server) Namespace() string {
+	return Namespace + tools.NamespaceSep + s.name
+}
+
+// Tools 列出服务的工具, 服务声明了资源或提示词能力时再加上浏览它们的工具
+func (s *Server) Tools(ctx context.Context) ([]tool.InvokableTool, error) {
+	var ts []tool.InvokableTool
+	if s.caps.Tools != nil {
+		res, err := s.client.ListTools(ctx, mcpgo.ListToolsRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("list tools of mcp server %s: %w", s.name, err)
+		}
+		for _, t := range res.Tools {
+			rt, err := newRemoteTool(s.client, t)
+			if err != nil {
+				return nil, fmt.Errorf("mcp server %s: %w", s.name, err)
+			}
+			ts = append(ts, rt)
+		}
+	}
+	if s.caps.Resources != nil {
+		ts = append(ts, s.resourceTools()...)
+	}
+	if s.caps.Prompts != nil {
+		ts = append(ts, s.promptTools()...)
+	}
+	return ts, nil
+}
+
+// Close 关闭连接, 通过 stdio 启动的服务进程也会停止
+func (s *Server) Close() error {
+	return s.client.Close()
+}
+
+// Attach 连接 servers 中所有启用的服务并把工具注册到 ts 的 Namespace 下, 返回的服务由调用方关闭
+// 连接失败的服务只记录日志并跳过, 单个服务故障不影响 agent 启动
+func Attach(ctx context.Context, ts *tools.ToolSet, servers map[string]config.MCPServer) []*Server {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var attached []*Server
+	for _, name := range names {
+		cfg := servers[name]
+		if cfg.Disabled {
+			continue
+		}
+		s, err := attach(ctx, ts, name, cfg)
+		if err != nil {
+			slog.WarnContext(ctx, "skip mcp server", "server", name, "error", err)
+			continue
+		}
+		attached = append(attached, s)
+	}
+	return attached
+}
+
+func attach(ctx context.Context, ts *tools.ToolSet, name string, cfg config.MCPServer) (*Server, error) {
+	s, err := Connect(ctx, name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := s.Tools(ctx)
+	if err != nil {
+		return nil, errors.Join(err, s.Close())
+	}
+	// 逐个注册, 名称冲突时撤回已注册的工具, 不留下注册了一半的服务
+	var added []string
+	for _, t := range remote {
+		if err := ts.AddNamespaced(s.Namespace(), t); err != nil {
+			for _, name := range added {
+				ts.RemoveTool(name)
+			}
+			return nil, errors.Join(err, s.Close())
+		}
+		info, _ := t.Info(ctx)
+		added = append(added, s.Namespace()+tools.NamespaceSep+info.Name)
+	}
+	return s, nil
+}
@@ -0,0 +1,57 @@
+package mcp_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/tools/mcp"
+	"github.com/bootun/cosmica/tools/tooltest"
+)
+
+// equals 检查输出与 want 完全一致
+func equals(want string) func(t testing.TB, out string) {
+	return func(t testing.TB, out string) {
+		if out != want {
+			t.Errorf("got %q, want %q", out, want)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	ctx := context.Background()
+	s, err := mcp.Connect(ctx, "test", config.MCPServer{URL: newTestServer(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	list, err := s.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]tooltest.Case{
+		"greet": {
+			{Name: "call", Args: `{"name":"gopher"}`, Check: equals("hello gopher")},
+			{Name: "tool_error", Args: `{"name":"nobody"}`, WantErr: true, ErrContains: []string{"nobody is not welcome"}},
+		},
+		"list_resources": {{Name: "list", Args: `{}`, Check: equals("file:///notes.txt\tnotes\n")}},
+		"read_resource": {
+			{Name: "read", Args: `{"uri":"file:///notes.txt"}`, Check: equals("remember the milk")},
+			{Name: "empty_uri", Args: `{"uri":" "}`, WantErr: true, ErrContains: []string{"uri"}},
+		},
+		"list_prompts": {{Name: "list", Args: `{}`, Check: equals("review\n  - lang (required)\n")}},
+		"get_prompt": {
+			{Name: "render", Args: `{"name":"review","arguments":{"lang":"Go"}}`, Check: equals("[user]\nreview this Go code\n")},
+			{Name: "empty_name", Args: `{"name":""}`, WantErr: true, ErrContains: []string{"name"}},
+		},
+	}
+	for _, tl := range list {
+		info, err := tl.Info(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(info.Name, func(t *testing.T) {
+			tooltest.Conformance(t, tl, cases[info.Name]...)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package mcp_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/tools/mcp"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newTestServer 通过 streamable HTTP 提供一个工具、一个资源与一个提示词模板的 MCP 服务, 返回其地址
+func newTestServer(t *testing.T) string {
+	s := server.NewMCPServer("test", "1.0.0",
+		server.WithToolCapabilities(false),
+		server.WithResourceCapabilities(false, false),
+		server.WithPromptCapabilities(false),
+	)
+	s.AddTool(mcpgo.NewTool("greet",
+		mcpgo.WithDescription("greet someone"),
+		mcpgo.WithString("name", mcpgo.Required(), mcpgo.Description("who to greet")),
+	), func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		name := req.GetString("name", "")
+		if name == "nobody" {
+			return mcpgo.NewToolResultError("nobody is not welcome"), nil
+		}
+		return mcpgo.NewToolResultText("hello " + name), nil
+	})
+	s.AddResource(mcpgo.NewResource("file:///notes.txt", "notes"),
+		func(ctx context.Context, req mcpgo.ReadResourceRequest) ([]mcpgo.ResourceContents, error) {
+			return []mcpgo.ResourceContents{mcpgo.TextResourceContents{URI: req.Params.URI, Text: "remember the milk"}}, nil
+		})
+	s.AddPrompt(mcpgo.NewPrompt("review", mcpgo.WithArgument("lang", mcpgo.RequiredArgument())),
+		func(ctx context.Context, req mcpgo.GetPromptRequest) (*mcpgo.GetPromptResult, error) {
+			return mcpgo.NewGetPromptResult("", []mcpgo.PromptMessage{
+				mcpgo.NewPromptMessage(mcpgo.RoleUser, mcpgo.NewTextContent("review this "+req.Params.Arguments["lang"]+" code")),
+			}), nil
+		})
+	ts := server.NewTestStreamableHTTPServer(s)
+	t.Cleanup(ts.Close)
+	return ts.URL + "/mcp"
+}
+
+func TestAttach(t *testing.T) {
+	ctx := context.Background()
+	ts, err := tools.NewToolSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := mcp.Attach(ctx, ts, map[string]config.MCPServer{
+		"test":     {URL: newTestServer(t)},
+		"off":      {URL: "http://127.0.0.1:1/mcp", Disabled: true},
+		"no_entry": {},
+	})
+	if len(servers) != 1 {
+		t.Fatalf("attached %d servers, want 1", len(servers))
+	}
+	defer servers[0].Close()
+
+	want := []string{
+		"mcp.test.greet",
+		"mcp.test.list_resources",
+		"mcp.test.read_resource",
+		"mcp.test.list_prompts",
+		"mcp.test.get_prompt",
+	}
+	if got := ts.Names(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("names = %v, want %v", got, want)
+	}
+
+	cases := []struct {
+		name, args, want string
+	}{
+		{"mcp__test__greet", `{"name":"gopher"}`, "hello gopher"},
+		{"mcp__test__list_resources", `{}`, "file:///notes.txt\tnotes\n"},
+		{"mcp__test__read_resource", `{"uri":"file:///notes.txt"}`, "remember the milk"},
+		{"mcp__test__list_prompts", `{}`, "review\n  - lang (required)\n"},
+		{"mcp__test__get_prompt", `{"name":"review","arguments":{"lang":"Go"}}`, "[user]\nreview this Go code\n"},
+	}
+	for _, c := range cases {
+		got, err := ts.Invoke(ctx, c.name, c.args)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRemoteToolErrors(t *testing.T) {
+	ctx := context.Background()
+	ts, err := tools.NewToolSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := mcp.Attach(ctx, ts, map[string]config.MCPServer{"test": {URL: newTestServer(t)}})
+	if len(servers) != 1 {
+		t.Fatalf("attached %d servers, want 1", len(servers))
+	}
+	defer servers[0].Close()
+
+	// 服务返回工具错误
+	if _, err := ts.Invoke(ctx, "mcp.test.greet", `{"name":"nobody"}`); err == nil || !strings.Contains(err.Error(), "nobody is not welcome") {
+		t.Errorf("err = %v, want tool error", err)
+	}
+	// 调用到达服务之前先按声明的 schema 校验参数
+	var argErr *tools.ArgumentError
+	if _, err := ts.Invoke(ctx, "mcp.test.greet", `{}`); !errors.As(err, &argErr) {
+		t.Errorf("err = %v, want ArgumentError", err)
+	}
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/components/tool"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+type readResourceParams struct {
+	URI string `json:"uri" desc:"URI of the resource, as returned by list_resources" required:"true"`
+}
+
+type getPromptParams struct {
+	Name      string            `json:"name" desc:"name of the prompt, as returned by list_prompts" required:"true"`
+	Arguments map[string]string `json:"arguments" desc:"values of the prompt arguments"`
+}
+
+// resourceTools 返回浏览与读取服务资源的工具
+func (s *Server) resourceTools() []tool.InvokableTool {
+	return []tool.InvokableTool{
+		tools.MustNewTypedTool("list_resources", fmt.Sprintf("list the resources provided by MCP server %s", s.name), s.listResources),
+		tools.MustNewTypedTool("read_resource", fmt.Sprintf("read a resource provided by MCP server %s", s.name), s.readResource),
+	}
+}
+
+// promptTools 返回列出与渲染服务提示词模板的工具
+func (s *Server) promptTools() []tool.InvokableTool {
+	return []tool.InvokableTool{
+		tools.MustNewTypedTool("list_prompts", fmt.Sprintf("list the prompt templates provided by MCP server %s", s.name), s.listPrompts),
+		tools.MustNewTypedTool("get_prompt", fmt.Sprintf("render a prompt template provided by MCP server %s", s.name), s.getPrompt),
+	}
+}
+
+func (s *Server) listResources(ctx context.Context, _ struct{}) (string, error) {
+	res, err := s.client.ListResources(ctx, mcpgo.ListResourcesRequest{})
+	if err != nil {
+		return "", fmt.Errorf("list resources: %w", err)
+	}
+	if len(res.Resources) == 0 {
+		return "no resources", nil
+	}
+	var sb strings.Builder
+	for _, r := range res.Resources {
+		fmt.Fprintf(&sb, "%s\t%s", r.URI, r.Name)
+		if r.MIMEType != "" {
+			fmt.Fprintf(&sb, " (%s)", r.MIMEType)
+		}
+		if r.Description != "" {
+			fmt.Fprintf(&sb, ": %s", r.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+func (s *Server) readResource(ctx context.Context, params readResourceParams) (string, error) {
+	if strings.TrimSpace(params.URI) == "" {
+		return "", errors.New("uri is required")
+	}
+	req := mcpgo.ReadResourceRequest{}
+	req.Params.URI = params.URI
+	res, err := s.client.ReadResource(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("read resource %s: %w", params.URI, err)
+	}
+	parts := make([]string, 0, len(res.Contents))
+	for _, c := range res.Contents {
+		parts = append(parts, resourceText(c))
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+func (s *Server) listPrompts(ctx context.Context, _ struct{}) (string, error) {
+	res, err := s.client.ListPrompts(ctx, mcpgo.ListPromptsRequest{})
+	if err != nil {
+		return "", fmt.Errorf("list prompts: %w", err)
+	}
+	if len(res.Prompts) == 0 {
+		return "no prompts", nil
+	}
+	var sb strings.Builder
+	for _, p := range res.Prompts {
+		sb.WriteString(p.Name)
+		if p.Description != "" {
+			fmt.Fprintf(&sb, ": %s", p.Description)
+		}
+		sb.WriteString("\n")
+		for _, arg := range p.Arguments {
+			fmt.Fprintf(&sb, "  - %s", arg.Name)
+			if arg.Required {
+				sb.WriteString(" (required)")
+			}
+			if arg.Description != "" {
+				fmt.Fprintf(&sb, ": %s", arg.Description)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+func (s *Server) getPrompt(ctx context.Context, params getPromptParams) (string, error) {
+	if strings.TrimSpace(params.Name) == "" {
+		return "", errors.New("name is required")
+	}
+	req := mcpgo.GetPromptRequest{}
+	req.Params.Name = params.Name
+	req.Params.Arguments = params.Arguments
+	res, err := s.client.GetPrompt(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("get prompt %s: %w", params.Name, err)
+	}
+	var sb strings.Builder
+	for _, m := range res.Messages {
+		fmt.Fprintf(&sb, "[%s]\n%s\n", m.Role, contentText([]mcpgo.Content{m.Content}))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/mark3labs/mcp-go/client"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+// remoteTool 把调用转发给 MCP 服务的工具
+type remoteTool struct {
+	client *client.Client
+	name   string
+	info   *schema.ToolInfo
+	schema *openapi3.Schema
+}
+
+func newRemoteTool(c *client.Client, t mcpgo.Tool) (tool.InvokableTool, error) {
+	s, err := inputSchema(t)
+	if err != nil {
+		return nil, fmt.Errorf("convert input schema of tool %s: %w", t.Name, err)
+	}
+	return &remoteTool{
+		client: c,
+		name:   t.Name,
+		schema: s,
+		info: &schema.ToolInfo{
+			Name:        t.Name,
+			Desc:        t.Description,
+			ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(s),
+		},
+	}, nil
+}
+
+// inputSchema 把服务声明的 JSON schema 转换为 eino 工具参数使用的 OpenAPI schema
+func inputSchema(t mcpgo.Tool) (*openapi3.Schema, error) {
+	var data []byte
+	if len(t.RawInputSchema) > 0 {
+		data = t.RawInputSchema
+	} else {
+		var err error
+		if data, err = json.Marshal(t.InputSchema); err != nil {
+			return nil, err
+		}
+	}
+	s := &openapi3.Schema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Type == "" {
+		s.Type = openapi3.TypeObject
+	}
+	return s, nil
+}
+
+func (t *remoteTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *remoteTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	if strings.TrimSpace(argumentsInJSON) == "" {
+		argumentsInJSON = "{}"
+	}
+	// 先在本地校验参数, 不符合 schema 的参数不会发给服务
+	if err := tools.ValidateArguments(t.name, t.schema, argumentsInJSON); err != nil {
+		return "", err
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", fmt.Errorf("unmarshal arguments: %w", err)
+	}
+	req := mcpgo.CallToolRequest{}
+	req.Params.Name = t.name
+	req.Params.Arguments = args
+	res, err := t.client.CallTool(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("call mcp tool %s: %w", t.name, err)
+	}
+	out := contentText(res.Content)
+	if res.IsError {
+		if out == "" {
+			out = "unknown error"
+		}
+		return "", errors.New(out)
+	}
+	return out, nil
+}
+
+// contentText 把 MCP 返回的内容拼接为交给模型的文本, 二进制内容替换为简短的占位符
+func contentText(contents []mcpgo.Content) string {
+	parts := make([]string, 0, len(contents))
+	for _, c := range contents {
+		switch c := c.(type) {
+		case mcpgo.TextContent:
+			parts = append(parts, c.Text)
+		case mcpgo.ImageContent:
+			parts = append(parts, fmt.Sprintf("[image %s]", c.MIMEType))
+		case mcpgo.AudioContent:
+			parts = append(parts, fmt.Sprintf("[audio %s]", c.MIMEType))
+		case mcpgo.EmbeddedResource:
+			parts = append(parts, resourceText(c.Resource))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func resourceText(r mcpgo.ResourceContents) string {
+	switch r := r.(type) {
+	case mcpgo.TextResourceContents:
+		return r.Text
+	case mcpgo.BlobResourceContents:
+		return fmt.Sprintf("[binary resource %s %s]", r.URI, r.MIMEType)
+	}
+	return ""
+}
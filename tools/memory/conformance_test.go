@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/tools/tooltest"
+)
+
+func TestConformance(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "memory.json"), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := store.Save(context.Background(), "the staging database password is in vault", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]tooltest.Case{
+		"memory_save": {{
+			Name: "save",
+			Args: `{"content":"the user prefers short answers","tags":["preference"]}`,
+			Check: func(t testing.TB, out string) {
+				if !strings.HasPrefix(out, "saved memory ") {
+					t.Errorf("got %q", out)
+				}
+			},
+		}},
+		"memory_search": {
+			{
+				Name: "search",
+				Args: `{"query":"database password"}`,
+				Check: func(t testing.TB, out string) {
+					if !strings.Contains(out, "["+m.ID+"] the staging database password is in vault") {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+			{
+				Name: "no_match",
+				Args: `{"query":"kubernetes"}`,
+				Check: func(t testing.TB, out string) {
+					if out != "no related memories" {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+		},
+		"memory_delete": {
+			{
+				Name:        "unknown_id",
+				Args:        `{"id":"nope"}`,
+				WantErr:     true,
+				ErrContains: []string{ErrNotFound.Error()},
+			},
+			{
+				Name: "delete",
+				Args: `{"id":"` + m.ID + `"}`,
+				Check: func(t testing.TB, out string) {
+					if !strings.HasPrefix(out, "deleted memory "+m.ID) {
+						t.Errorf("got %q", out)
+					}
+				},
+			},
+		},
+	}
+	for _, tl := range NewTools(store) {
+		info, err := tl.Info(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(info.Name, func(t *testing.T) {
+			tooltest.Conformance(t, tl, cases[info.Name]...)
+		})
+	}
+}
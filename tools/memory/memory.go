@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bootun/cosmica/tools"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// NewTools 返回基于 s 的 memory_save、memory_search 与 memory_delete 工具
+func NewTools(s *Store) []tool.InvokableTool {
+	return []tool.InvokableTool{
+		tools.MustNewTypedTool("memory_save", "save a fact to long-term memory so it can be recalled in later sessions, "+
+			"such as decisions made, where credentials or configs live, and user preferences. "+
+			"Save one self-contained fact per call and never save secret values themselves", s.save),
+		tools.MustNewTypedTool("memory_search", "search long-term memory saved in earlier sessions, "+
+			"returns the most related memories with their ids", s.search),
+		tools.MustNewTypedTool("memory_delete", "delete a memory that is wrong or outdated by its id", s.delete),
+	}
+}
+
+type saveParams struct {
+	Content string   `json:"content" desc:"the fact to remember, written so it is understandable without the current conversation" required:"true"`
+	Tags    []string `json:"tags" desc:"optional keywords that help finding the memory later"`
+}
+
+func (s *Store) save(ctx context.Context, params saveParams) (string, error) {
+	m, err := s.Save(ctx, params.Content, params.Tags)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("saved memory %s", m.ID), nil
+}
+
+type searchParams struct {
+	Query string `json:"query" desc:"what you want to recall" required:"true"`
+	Limit int    `json:"limit" desc:"maximum number of memories to return, defaults to 5"`
+}
+
+func (s *Store) search(ctx context.Context, params searchParams) (string, error) {
+	if strings.TrimSpace(params.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	results, err := s.Search(ctx, params.Query, params.Limit)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "no related memories", nil
+	}
+	var b strings.Builder
+	for _, r := range results {
+		fmt.Fprintf(&b, "- [%s] %s (saved %s", r.ID, r.Content, r.Created.Format("2006-01-02"))
+		if len(r.Tags) > 0 {
+			fmt.Fprintf(&b, ", tags: %s", strings.Join(r.Tags, ", "))
+		}
+		fmt.Fprintf(&b, ", score %.2f)\n", r.Score)
+	}
+	return b.String(), nil
+}
+
+type deleteParams struct {
+	ID string `json:"id" desc:"id of the memory, as returned by memory_search" required:"true"`
+}
+
+func (s *Store) delete(ctx context.Context, params deleteParams) (string, error) {
+	m, err := s.Delete(strings.TrimSpace(params.ID))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("deleted memory %s: %s", m.ID, m.Content), nil
+}
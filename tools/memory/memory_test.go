@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// fakeEmbedder embeds a text as the counts of a few keywords, so texts sharing
+// keywords are similar even without sharing any other word.
+type fakeEmbedder struct {
+	calls int
+	fail  bool
+}
+
+var keywords = [][]string{
+	{"database", "postgres", "db"},
+	{"password", "credential", "secret"},
+	{"tabs", "indent", "spaces"},
+}
+
+func (e *fakeEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	e.calls++
+	if e.fail {
+		return nil, errors.New("embedding service unavailable")
+	}
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float64, len(keywords))
+		for j, group := range keywords {
+			for _, k := range group {
+				vectors[i][j] += float64(strings.Count(strings.ToLower(text), k))
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestStoreEmbeddingSearch(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "memory.json")
+	e := &fakeEmbedder{}
+	s, err := Open(path, e, "fake-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := s.Save(ctx, "postgres credential lives in vault", []string{"ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Save(ctx, "the user indents with tabs", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// no word is shared with the memory, only the embedding matches
+	results, err := s.Search(ctx, "db secret", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != creds.ID || results[0].Score < 0.9 {
+		t.Errorf("results = %+v", results)
+	}
+	// memories below the minimum similarity are not returned
+	if results, err := s.Search(ctx, "weather today", 0); err != nil || len(results) != 0 {
+		t.Errorf("unrelated results = %+v, %v", results, err)
+	}
+
+	// another store on the same file sees the saved memories and their embeddings
+	other, err := Open(path, e, "fake-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := other.List()
+	if err != nil || len(list) != 2 || len(list[0].Embedding) != len(keywords) || list[0].Tags[0] != "ops" {
+		t.Fatalf("reopened memories = %+v, %v", list, err)
+	}
+	if _, err := other.Delete(creds.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(creds.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting a memory removed by another store: %v", err)
+	}
+}
+
+func TestConcurrentStoresKeepEveryMemory(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "memory.json")
+	var wg sync.WaitGroup
+	for i := range 4 {
+		s, err := Open(path, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 5 {
+				if _, err := s.Save(ctx, fmt.Sprintf("fact %d from store %d", j, i), nil); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	s, err := Open(path, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, err := s.List(); err != nil || len(list) != 20 {
+		t.Errorf("%d memories, %v, want 20", len(list), err)
+	}
+}
+
+func TestStoreReembedsAfterModelChange(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "memory.json")
+	s, err := Open(path, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Save(ctx, "postgres password is in vault", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &fakeEmbedder{}
+	s, err = Open(path, e, "fake-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := s.Search(ctx, "db credential", 0)
+	if err != nil || len(results) != 1 || results[0].Score < 0.9 {
+		t.Fatalf("results = %+v, %v", results, err)
+	}
+	if list, _ := s.List(); len(list[0].Embedding) == 0 {
+		t.Error("missing embedding was not computed")
+	}
+	// embeddings from the same model are reused
+	e.calls = 0
+	if _, err := s.Search(ctx, "tabs", 0); err != nil || e.calls != 1 {
+		t.Errorf("second search embedded %d batches, %v", e.calls, err)
+	}
+}
+
+func TestStoreFallsBackToBM25(t *testing.T) {
+	ctx := context.Background()
+	e := &fakeEmbedder{fail: true}
+	s, err := Open(filepath.Join(t.TempDir(), "memory.json"), e, "fake-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// saving still works when the embedding model fails
+	m, err := s.Save(ctx, "deploy with make deploy", []string{"release"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := s.Search(ctx, "how to release", 0)
+	if err != nil || len(results) != 1 || results[0].ID != m.ID {
+		t.Errorf("results = %+v, %v", results, err)
+	}
+	if _, err := s.Save(ctx, "  ", nil); err == nil {
+		t.Error("saving an empty memory should fail")
+	}
+}
@@ -0,0 +1,336 @@
+// Package memory 为 agent 提供跨会话保留的长期记忆
+// 记忆保存在本地的 JSON 文件中, 配置了向量模型时按向量相似度检索, 否则使用 BM25
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootun/cosmica/search"
+	"github.com/bootun/cosmica/utils/fsutil"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+const (
+	// DefaultLimit 检索未指定数量时返回的记忆条数
+	DefaultLimit = 5
+	// MinScore 向量检索时余弦相似度低于该值的记忆视为无关, 不会返回
+	MinScore = 0.3
+)
+
+var ErrNotFound = errors.New("memory not found")
+
+// Memory 是一条记住的事实
+type Memory struct {
+	ID      string    `json:"id"`
+	Content string    `json:"content"`
+	Tags    []string  `json:"tags,omitempty"`
+	Created time.Time `json:"created"`
+	// Embedding 由 Store 的向量模型计算, 计算成功前为空
+	Embedding []float64 `json:"embedding,omitempty"`
+}
+
+// Result 是检索到的记忆
+type Result struct {
+	Memory
+	Score float64
+}
+
+// storeFile 是记忆文件的格式
+type storeFile struct {
+	// EmbeddingModel 计算文件中向量所用的模型
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	Memories       []*Memory `json:"memories"`
+}
+
+// Store 是本机所有 agent 与会话共用的记忆文件
+// 其他进程修改文件后重新读取; 写入时持有文件锁并在最新的内容上修改, 不会覆盖其他进程保存的记忆
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	embedder embedding.Embedder
+	model    string
+	memories []*Memory
+	bm25     *search.BM25
+	// modTime 与 size 标识上次读取或写入的文件内容
+	modTime time.Time
+	size    int64
+	// fileModel 文件中记录的向量模型, 未配置向量模型时原样保留
+	fileModel string
+}
+
+// DefaultFile 返回 ~/.cosmica/memory.json
+func DefaultFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cosmica", "memory.json")
+}
+
+// Open 读取 path 处的记忆文件, path 为空时使用 DefaultFile
+// embedder 可以为 nil, 此时使用 BM25 检索; model 是向量模型的名称, 其他模型计算的向量会重新计算
+func Open(path string, embedder embedding.Embedder, model string) (*Store, error) {
+	if path == "" {
+		path = DefaultFile()
+	}
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("expand %s: %w", path, err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	if path == "" {
+		return nil, errors.New("no memory file")
+	}
+	s := &Store{path: path, embedder: embedder, model: model, bm25: search.NewBM25()}
+	if err := s.load(false); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path 返回记忆文件的路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// List 返回所有记忆, 最早保存的在前
+func (s *Store) List() ([]Memory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(false); err != nil {
+		return nil, err
+	}
+	list := make([]Memory, 0, len(s.memories))
+	for _, m := range s.memories {
+		list = append(list, *m)
+	}
+	return list, nil
+}
+
+// Save 记住 content, 向量模型出错时记忆仍会保存, 在下次检索时重新计算向量
+func (s *Store) Save(ctx context.Context, content string, tags []string) (Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Memory{}, errors.New("empty memory")
+	}
+	m := &Memory{ID: newID(), Content: content, Tags: tags, Created: time.Now().UTC().Truncate(time.Second)}
+	if s.embedder != nil {
+		vectors, err := s.embedder.EmbedStrings(ctx, []string{m.Content})
+		if err == nil && len(vectors) == 1 {
+			m.Embedding = vectors[0]
+		} else {
+			slog.WarnContext(ctx, "embed memory failed", "error", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.update(func() error {
+		s.memories = append(s.memories, m)
+		s.bm25.Add(m.ID, indexText(m))
+		return nil
+	})
+	if err != nil {
+		return Memory{}, err
+	}
+	return *m, nil
+}
+
+// Search 返回与 query 相关的至多 limit 条记忆, 最相关的在前
+func (s *Store) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(false); err != nil {
+		return nil, err
+	}
+	var hits []search.Hit
+	if s.embedder != nil {
+		var err error
+		if hits, err = s.vectorSearch(ctx, query, limit); err != nil {
+			slog.WarnContext(ctx, "embedding search failed, falling back to bm25", "error", err)
+			hits = nil
+		}
+	}
+	if hits == nil {
+		hits = s.bm25.Search(query, limit)
+	}
+	results := make([]Result, 0, len(hits))
+	for _, h := range hits {
+		if m := s.find(h.ID); m != nil {
+			results = append(results, Result{Memory: *m, Score: h.Score})
+		}
+	}
+	return results, nil
+}
+
+// vectorSearch 计算 query 与尚无向量的记忆的向量, 按余弦相似度排序
+// 相似度低于 MinScore 的记忆不返回, 都不相关时返回空的结果而不是 nil, 不再退回 BM25
+func (s *Store) vectorSearch(ctx context.Context, query string, limit int) ([]search.Hit, error) {
+	if len(s.memories) == 0 {
+		return nil, nil
+	}
+	var missing []*Memory
+	texts := []string{query}
+	for _, m := range s.memories {
+		if len(m.Embedding) == 0 {
+			missing = append(missing, m)
+			texts = append(texts, m.Content)
+		}
+	}
+	vectors, err := s.embedder.EmbedStrings(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedding model returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	if len(missing) > 0 {
+		// 写入前文件可能已被其他进程修改, 按 ID 把向量补到最新的记忆上
+		err := s.update(func() error {
+			for i, m := range missing {
+				if cur := s.find(m.ID); cur != nil && len(cur.Embedding) == 0 && cur.Content == m.Content {
+					cur.Embedding = vectors[i+1]
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	hits := make([]search.Hit, 0, len(s.memories))
+	for _, m := range s.memories {
+		if score := search.Cosine(vectors[0], m.Embedding); score >= MinScore {
+			hits = append(hits, search.Hit{ID: m.ID, Score: score})
+		}
+	}
+	return search.Rank(hits, limit), nil
+}
+
+// Delete 忘记 id 对应的记忆
+func (s *Store) Delete(id string) (Memory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted Memory
+	err := s.update(func() error {
+		for i, m := range s.memories {
+			if m.ID == id {
+				s.memories = append(s.memories[:i], s.memories[i+1:]...)
+				s.bm25.Remove(id)
+				deleted = *m
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	})
+	if err != nil {
+		return Memory{}, err
+	}
+	return deleted, nil
+}
+
+func (s *Store) find(id string) *Memory {
+	for _, m := range s.memories {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// update 持有文件锁重新读取文件, 在最新的内容上执行 change 后写回
+// 其他进程在本进程上次读取之后保存的记忆因此不会被覆盖; change 返回错误时不写回
+func (s *Store) update(change func() error) error {
+	unlock, err := fsutil.Lock(s.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.load(true); err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// load 在文件自上次读写后有变化时重新读取, force 为 true 时总是重新读取
+func (s *Store) load(force bool) error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", s.path, err)
+	}
+	if !force && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", s.path, err)
+	}
+	var f storeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("decode %s: %w", s.path, err)
+	}
+	s.memories, s.modTime, s.size, s.fileModel = f.Memories, info.ModTime(), info.Size(), f.EmbeddingModel
+	s.bm25 = search.NewBM25()
+	for _, m := range s.memories {
+		// 其他模型计算的向量与当前模型的不可比较
+		if s.embedder != nil && f.EmbeddingModel != s.model {
+			m.Embedding = nil
+		}
+		s.bm25.Add(m.ID, indexText(m))
+	}
+	return nil
+}
+
+// persist 写回记忆文件, 调用方需持有文件锁
+func (s *Store) persist() error {
+	f := storeFile{EmbeddingModel: s.fileModel, Memories: s.memories}
+	if s.embedder != nil {
+		f.EmbeddingModel = s.model
+	}
+	if f.Memories == nil {
+		f.Memories = []*Memory{}
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("encode memories: %w", err)
+	}
+	if err := fsutil.WriteFile(s.path, data); err != nil {
+		return fmt.Errorf("save memories: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// indexText 返回 BM25 检索记忆时匹配的文本
+func indexText(m *Memory) string {
+	return m.Content + " " + strings.Join(m.Tags, " ")
+}
+
+func newID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
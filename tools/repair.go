@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var trailingComma = regexp.MustCompile(`,\s*([}\]])`)
+
+// repairJSON 尝试修复模型常见的 JSON 格式错误: 空参数、markdown 代码块、
+// 首尾多余的文字、多余的逗号以及缺失的右括号, 无法修复时返回 false
+func repairJSON(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "{}", true
+	}
+	if json.Valid([]byte(s)) {
+		return s, true
+	}
+
+	// 去掉 ```json ... ``` 包裹
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		}
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+	// 只保留第一个 { 之后的内容
+	if i := strings.IndexByte(s, '{'); i > 0 {
+		s = s[i:]
+	}
+	if j := strings.LastIndexByte(s, '}'); j >= 0 && j < len(s)-1 && json.Valid([]byte(s[:j+1])) {
+		s = s[:j+1]
+	}
+	s = trailingComma.ReplaceAllString(s, "$1")
+	s = closeBrackets(s)
+	s = trailingComma.ReplaceAllString(s, "$1")
+
+	if !json.Valid([]byte(s)) {
+		return "", false
+	}
+	return s, true
+}
+
+// closeBrackets 补全未闭合的字符串与括号, 通常由输出被截断导致
+func closeBrackets(s string) string {
+	var stack []byte
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) > 0 && stack[len(stack)-1] == c {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(s)
+	if inString {
+		sb.WriteByte('"')
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		sb.WriteByte(stack[i])
+	}
+	return sb.String()
+}
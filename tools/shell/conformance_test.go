@@ -0,0 +1,21 @@
+package shell_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/tools/shell"
+	"github.com/bootun/cosmica/tools/tooltest"
+)
+
+func TestConformance(t *testing.T) {
+	tooltest.Conformance(t, shell.NewShellExecutor(), tooltest.Case{
+		Name: "echo",
+		Args: `{"command":"echo cosmica"}`,
+		Check: func(t testing.TB, out string) {
+			if strings.TrimSpace(out) != "cosmica" {
+				t.Errorf("got %q", out)
+			}
+		},
+	})
+}
@@ -3,50 +3,39 @@ package shell
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"os/exec"
 	"runtime"
 	"strings"
 
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/utils/logging"
 	"github.com/cloudwego/eino/components/tool"
-	"github.com/cloudwego/eino/schema"
 )
 
+// ExecutorName 是 shell 工具的名称, 审批规则按它匹配
+const ExecutorName = "shell_executor"
+
 // TODO(bootun): 命令行新开个线程，这样可以和AI互动?
-func NewShellExecutor() *shellExecutor {
-	return &shellExecutor{
+func NewShellExecutor() tool.InvokableTool {
+	s := &shellExecutor{
 		OS: runtime.GOOS,
 	}
+	return tools.MustNewTypedTool(ExecutorName, "command line shell, the user current operating system is "+s.OS, s.run)
 }
 
 type shellExecutor struct {
 	OS string
 }
 
-func (s *shellExecutor) Info(ctx context.Context) (*schema.ToolInfo, error) {
-	return &schema.ToolInfo{
-		Name: "shell_executor",
-		Desc: "command line shell, the user current operating system is " + s.OS,
-		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
-			"command": {
-				Desc:     "want to execute command",
-				Type:     schema.String,
-				Required: true,
-			},
-		}),
-	}, nil
+type shellParams struct {
+	Command string `json:"command" desc:"want to execute command" required:"true"`
 }
 
-func (s *shellExecutor) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
-	params, err := parseShellParams(argumentsInJSON)
-	if err != nil {
-		return "", fmt.Errorf("解析命令参数失败: %w", err)
-	}
-
+func (s *shellExecutor) run(ctx context.Context, params shellParams) (string, error) {
 	if strings.TrimSpace(params.Command) == "" {
-		return "", fmt.Errorf("命令不能为空")
+		return "", fmt.Errorf("参数 command 不能为空")
 	}
 
 	var cmd *exec.Cmd
@@ -65,33 +54,21 @@ func (s *shellExecutor) InvokableRun(ctx context.Context, argumentsInJSON string
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err = cmd.Run()
+	err := cmd.Run()
 	if err != nil {
 		errMsg := stderr.String()
 		if errMsg == "" {
 			errMsg = err.Error()
 		}
-		log.Printf("执行命令失败: %v, 错误: %v", params.Command, errMsg)
+		// 命令与输出可能含有敏感信息, 日志只记录摘要, stderr 已经在返回给模型的错误中
+		slog.WarnContext(ctx, "shell command failed", logging.Digest("command", params.Command), "error", err)
 		return "", fmt.Errorf("执行命令失败: %v", errMsg)
 	}
 
 	output := stdout.String()
-	// log.Printf("命令: %v, 输出: %v", params.Command, output)
+	slog.DebugContext(ctx, "shell command finished", logging.Digest("command", params.Command), "output_size", len(output))
 	if output == "" {
 		return "the command did not return a result", nil
 	}
 	return output, nil
 }
-
-type shellParams struct {
-	Command string `json:"command"`
-}
-
-func parseShellParams(argumentsInJSON string) (*shellParams, error) {
-	var params shellParams
-	err := json.Unmarshal([]byte(argumentsInJSON), &params)
-	if err != nil {
-		return nil, err
-	}
-	return &params, nil
-}
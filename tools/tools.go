@@ -4,66 +4,350 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/bootun/cosmica/utils/logging"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
 )
 
+// NamespaceSep 分隔命名空间与工具名, 例如 file.read, mcp.github.search
+const NamespaceSep = "."
+
+// modelNameSep 替换发送给模型的工具名中的 NamespaceSep, 大多数模型接口只允许 [a-zA-Z0-9_-]
+const modelNameSep = "__"
+
+// ToolSet 是 agent 可用的工具集合, 按注册顺序排列, 会话过程中可以增删、启用和禁用工具
+// 每次变更都会增加 Version, agent 据此在下次调用模型前重新绑定工具
 type ToolSet struct {
-	toolsMap map[string]tool.InvokableTool
-	infos    []*schema.ToolInfo
+	mu sync.RWMutex
+	// entries 按注册顺序保存所有工具
+	entries []*entry
+	// index 以完整名称与发送给模型的名称索引工具
+	index map[string]*entry
+	// aliases 别名 -> 完整名称
+	aliases map[string]string
+	version uint64
+	// autoRepair 为 true 时先尝试修复格式错误的参数 JSON
+	autoRepair bool
+}
+
+type entry struct {
+	// name 带命名空间的完整名称
+	name string
+	tool tool.InvokableTool
+	// info 发送给模型的描述, Name 为 ModelName(name)
+	info *schema.ToolInfo
+	// schema 工具参数的 JSON schema, 调用工具前据此校验参数
+	schema *openapi3.Schema
+	// disabled 被禁用的工具不会提供给模型, 也不能被调用
+	disabled bool
+	// required 为 true 时不能禁用或移除
+	required bool
 }
 
+// NewToolSet 创建工具集, 工具名重复时返回错误
 func NewToolSet(tools ...tool.InvokableTool) (*ToolSet, error) {
-	ts := &ToolSet{}
-	ts.toolsMap = make(map[string]tool.InvokableTool, len(tools))
-	ts.infos = make([]*schema.ToolInfo, 0, len(tools))
+	ts := &ToolSet{
+		entries: make([]*entry, 0, len(tools)),
+		index:   make(map[string]*entry, len(tools)),
+		aliases: make(map[string]string),
+	}
 	for _, t := range tools {
-		info, err := t.Info(context.Background())
-		if err != nil {
-			return nil, fmt.Errorf("get tool info: %w", err)
+		if err := ts.add("", t); err != nil {
+			return nil, err
 		}
-		toolName := info.Name
-		ts.toolsMap[toolName] = t
-		ts.infos = append(ts.infos, info)
 	}
 	return ts, nil
 }
 
+// ModelName 返回带命名空间的工具名发送给模型时使用的名称
+func ModelName(name string) string {
+	return strings.ReplaceAll(name, NamespaceSep, modelNameSep)
+}
+
+// Infos 按注册顺序返回所有已启用工具的描述
 func (ts *ToolSet) Infos() []*schema.ToolInfo {
-	return ts.infos
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	infos := make([]*schema.ToolInfo, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		if !e.disabled {
+			infos = append(infos, e.info)
+		}
+	}
+	return infos
 }
 
 var (
 	ErrToolNotFound = errors.New("tool not found")
+	ErrToolDisabled = errors.New("tool is disabled")
+	// ErrToolRequired 工具是 agent 工作所必需的, 不能被禁用或移除
+	ErrToolRequired = errors.New("tool is required")
 )
 
+// GetTool 按完整名称、发送给模型的名称或别名查找工具
 func (ts *ToolSet) GetTool(name string) (tool.InvokableTool, error) {
-	t, ok := ts.toolsMap[name]
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	e, ok := ts.lookup(name)
 	if !ok {
 		return nil, ErrToolNotFound
 	}
-	return t, nil
+	if e.disabled {
+		return nil, ErrToolDisabled
+	}
+	return e.tool, nil
 }
 
 func (ts *ToolSet) AddTool(tool tool.InvokableTool) error {
-	info, err := tool.Info(context.Background())
+	return ts.AddNamespaced("", tool)
+}
+
+// AddNamespaced 以 namespace 为前缀注册工具, 例如 namespace 为 mcp.github 时 search 工具注册为 mcp.github.search
+func (ts *ToolSet) AddNamespaced(namespace string, tools ...tool.InvokableTool) error {
+	for _, t := range tools {
+		if err := ts.add(namespace, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts *ToolSet) add(namespace string, t tool.InvokableTool) error {
+	info, err := t.Info(context.Background())
 	if err != nil {
 		return fmt.Errorf("get tool info: %w", err)
 	}
 	name := info.Name
-	if _, ok := ts.toolsMap[name]; ok {
-		return fmt.Errorf("tool %s already exists", name)
+	if namespace != "" {
+		name = namespace + NamespaceSep + name
+	}
+	e := &entry{name: name, tool: t}
+	if info.ParamsOneOf != nil {
+		if e.schema, err = info.ParamsOneOf.ToOpenAPIV3(); err != nil {
+			return fmt.Errorf("convert params of tool %s: %w", name, err)
+		}
 	}
-	ts.toolsMap[name] = tool
-	ts.infos = append(ts.infos, info)
+	modelInfo := *info
+	modelInfo.Name = ModelName(name)
+	e.info = &modelInfo
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, key := range []string{name, modelInfo.Name} {
+		if _, ok := ts.lookup(key); ok {
+			return fmt.Errorf("tool %s already exists", key)
+		}
+	}
+	ts.entries = append(ts.entries, e)
+	ts.index[name] = e
+	ts.index[modelInfo.Name] = e
+	ts.version++
 	return nil
 }
 
+// Alias 为工具添加别名, 别名只用于查找与调用, 不会发送给模型
+func (ts *ToolSet) Alias(alias, name string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	e, ok := ts.lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	if _, ok := ts.lookup(alias); ok {
+		return fmt.Errorf("tool %s already exists", alias)
+	}
+	ts.aliases[alias] = e.name
+	return nil
+}
+
+// RemoveTool 从工具集中移除工具及其别名
+func (ts *ToolSet) RemoveTool(name string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	e, ok := ts.lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	if e.required {
+		return fmt.Errorf("%w: %s", ErrToolRequired, e.name)
+	}
+	delete(ts.index, e.name)
+	delete(ts.index, e.info.Name)
+	for alias, target := range ts.aliases {
+		if target == e.name {
+			delete(ts.aliases, alias)
+		}
+	}
+	for i, cur := range ts.entries {
+		if cur == e {
+			ts.entries = append(ts.entries[:i:i], ts.entries[i+1:]...)
+			break
+		}
+	}
+	ts.version++
+	return nil
+}
+
+// Enable 启用被禁用的工具
+func (ts *ToolSet) Enable(name string) error {
+	return ts.setDisabled(name, false)
+}
+
+// Disable 禁用工具, 工具仍保留在工具集中, 可以随时重新启用
+func (ts *ToolSet) Disable(name string) error {
+	return ts.setDisabled(name, true)
+}
+
+func (ts *ToolSet) setDisabled(name string, disabled bool) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	e, ok := ts.lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	if e.disabled == disabled {
+		return nil
+	}
+	if disabled && e.required {
+		return fmt.Errorf("%w: %s", ErrToolRequired, e.name)
+	}
+	e.disabled = disabled
+	ts.version++
+	return nil
+}
+
+// Require 把工具标记为必需, 必需的工具会被启用, 之后不能再禁用或移除
+func (ts *ToolSet) Require(name string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	e, ok := ts.lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	e.required = true
+	if e.disabled {
+		e.disabled = false
+		ts.version++
+	}
+	return nil
+}
+
+// Enabled 返回工具是否存在且已启用
+func (ts *ToolSet) Enabled(name string) bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	e, ok := ts.lookup(name)
+	return ok && !e.disabled
+}
+
+// Version 在工具集每次变更后递增
+func (ts *ToolSet) Version() uint64 {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.version
+}
+
+// Names 按注册顺序返回所有工具的完整名称
+func (ts *ToolSet) Names() []string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	names := make([]string, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// ToolList 按注册顺序返回所有工具
 func (ts *ToolSet) ToolList() []tool.InvokableTool {
-	tools := make([]tool.InvokableTool, 0, len(ts.toolsMap))
-	for _, t := range ts.toolsMap {
-		tools = append(tools, t)
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	tools := make([]tool.InvokableTool, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		tools = append(tools, e.tool)
 	}
 	return tools
 }
+
+// Describe 按注册顺序列出所有工具的名称、别名、状态与描述的第一行
+func (ts *ToolSet) Describe() string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	aliases := make(map[string][]string)
+	for alias, target := range ts.aliases {
+		aliases[target] = append(aliases[target], alias)
+	}
+	var sb strings.Builder
+	for _, e := range ts.entries {
+		sb.WriteString(e.name)
+		if a := aliases[e.name]; len(a) > 0 {
+			sort.Strings(a)
+			fmt.Fprintf(&sb, " (alias: %s)", strings.Join(a, ", "))
+		}
+		if e.disabled {
+			sb.WriteString(" [disabled]")
+		}
+		if e.required {
+			sb.WriteString(" [required]")
+		}
+		desc, _, _ := strings.Cut(strings.TrimSpace(e.info.Desc), "\n")
+		if desc != "" {
+			sb.WriteString(" - ")
+			sb.WriteString(desc)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// SetAutoRepair 开启后, 参数不是合法 JSON 时先尝试修复再校验
+func (ts *ToolSet) SetAutoRepair(enabled bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.autoRepair = enabled
+}
+
+// Invoke 按工具声明的参数 schema 校验参数, 通过后再调用工具
+// 参数不合法时返回 *ArgumentError, 其中的信息可以直接交给模型修正
+func (ts *ToolSet) Invoke(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	ts.mu.RLock()
+	e, ok := ts.lookup(name)
+	disabled := ok && e.disabled
+	autoRepair := ts.autoRepair
+	ts.mu.RUnlock()
+	if !ok {
+		return "", ErrToolNotFound
+	}
+	if disabled {
+		return "", ErrToolDisabled
+	}
+	// 没有参数的调用按空对象处理, 与 typedTool 一致
+	if strings.TrimSpace(argumentsInJSON) == "" {
+		argumentsInJSON = "{}"
+	}
+	if autoRepair {
+		if repaired, ok := repairJSON(argumentsInJSON); ok && repaired != argumentsInJSON {
+			slog.InfoContext(ctx, "repaired tool arguments", "tool", e.name, logging.Digest("from", argumentsInJSON), logging.Digest("to", repaired))
+			argumentsInJSON = repaired
+		}
+	}
+	if err := ValidateArguments(e.name, e.schema, argumentsInJSON); err != nil {
+		return "", err
+	}
+	return e.tool.InvokableRun(ctx, argumentsInJSON, opts...)
+}
+
+// lookup 调用方需持有锁
+func (ts *ToolSet) lookup(name string) (*entry, bool) {
+	if target, ok := ts.aliases[name]; ok {
+		name = target
+	}
+	e, ok := ts.index[name]
+	return e, ok
+}
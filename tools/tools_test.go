@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type namedTool string
+
+func (n namedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: string(n), Desc: "tool " + string(n) + "\nmore details"}, nil
+}
+
+func (n namedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return string(n), nil
+}
+
+func TestToolSetOrderAndDuplicates(t *testing.T) {
+	names := []string{"zeta", "alpha", "mid", "beta", "omega"}
+	var list []tool.InvokableTool
+	for _, n := range names {
+		list = append(list, namedTool(n))
+	}
+	for i := 0; i < 10; i++ {
+		ts, err := NewToolSet(list...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ts.Names(); !reflect.DeepEqual(got, names) {
+			t.Fatalf("Names() = %v, want %v", got, names)
+		}
+		var infos []string
+		for _, info := range ts.Infos() {
+			infos = append(infos, info.Name)
+		}
+		if !reflect.DeepEqual(infos, names) {
+			t.Fatalf("Infos() = %v, want %v", infos, names)
+		}
+	}
+
+	if _, err := NewToolSet(namedTool("a"), namedTool("a")); err == nil {
+		t.Error("expected NewToolSet to reject duplicate names")
+	}
+}
+
+func TestToolSetNamespacesAndAliases(t *testing.T) {
+	ts, err := NewToolSet(namedTool("bell"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AddNamespaced("mcp.github", namedTool("search")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AddNamespaced("file", namedTool("read")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AddNamespaced("mcp.github", namedTool("search")); err == nil {
+		t.Error("expected duplicate namespaced tool to be rejected")
+	}
+	if err := ts.Alias("file_reader", "file.read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Alias("bell", "file.read"); err == nil {
+		t.Error("expected alias clashing with a tool name to be rejected")
+	}
+
+	var infos []string
+	for _, info := range ts.Infos() {
+		infos = append(infos, info.Name)
+	}
+	if want := []string{"bell", "mcp__github__search", "file__read"}; !reflect.DeepEqual(infos, want) {
+		t.Errorf("Infos() = %v, want %v", infos, want)
+	}
+
+	for _, name := range []string{"mcp.github.search", "mcp__github__search", "file_reader", "file.read"} {
+		if _, err := ts.Invoke(context.Background(), name, "{}"); err != nil {
+			t.Errorf("Invoke(%s): %v", name, err)
+		}
+	}
+
+	if err := ts.Disable("file_reader"); err != nil {
+		t.Fatal(err)
+	}
+	want := "bell - tool bell\n" +
+		"mcp.github.search - tool search\n" +
+		"file.read (alias: file_reader) [disabled] - tool read\n"
+	if got := ts.Describe(); got != want {
+		t.Errorf("Describe() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := ts.RemoveTool("file.read"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.GetTool("file_reader"); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("alias should be removed with its tool, got %v", err)
+	}
+	if strings.Contains(ts.Describe(), "file.read") {
+		t.Error("removed tool is still described")
+	}
+}
+
+func TestToolSetRequiredTools(t *testing.T) {
+	ts, err := NewToolSet(namedTool("bell"), namedTool("search"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Disable("bell"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Require("bell"); err != nil {
+		t.Fatal(err)
+	}
+	if !ts.Enabled("bell") {
+		t.Error("a required tool should be enabled")
+	}
+	if err := ts.Disable("bell"); !errors.Is(err, ErrToolRequired) {
+		t.Errorf("Disable err = %v, want ErrToolRequired", err)
+	}
+	if err := ts.RemoveTool("bell"); !errors.Is(err, ErrToolRequired) {
+		t.Errorf("RemoveTool err = %v, want ErrToolRequired", err)
+	}
+	if err := ts.Require("missing"); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("Require err = %v, want ErrToolNotFound", err)
+	}
+	if got := ts.Describe(); !strings.HasPrefix(got, "bell [required] - tool bell\n") {
+		t.Errorf("Describe() =\n%s", got)
+	}
+}
+
+type optionalParams struct {
+	Limit int `json:"limit" desc:"maximum number of results"`
+}
+
+func TestToolSetInvokeWithoutArguments(t *testing.T) {
+	greet := MustNewTypedTool("greet", "greet the user", func(ctx context.Context, p optionalParams) (string, error) {
+		return "hello", nil
+	})
+	ts, err := NewToolSet(greet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range []string{"", " \n"} {
+		if out, err := ts.Invoke(context.Background(), "greet", args); err != nil || out != "hello" {
+			t.Errorf("Invoke(%q) = %q, %v", args, out, err)
+		}
+	}
+}
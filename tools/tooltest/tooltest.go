@@ -0,0 +1,164 @@
+// Package tooltest 检查工具是否满足 agent 循环的约定: Info 的参数结构合法, 模型传入错误或缺失的参数时返回明确的错误而不是 panic
+package tooltest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// toolNamePattern OpenAI 兼容接口接受的函数名格式
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Case 对被测工具的一次调用
+type Case struct {
+	Name string
+	Args string
+	// WantErr 期望调用失败, 错误信息包含 ErrContains 中的每一项
+	WantErr     bool
+	ErrContains []string
+	// Check 检查调用成功时的输出
+	Check func(t testing.TB, out string)
+}
+
+// Conformance 先运行根据 Info 参数结构生成的通用检查, 再运行给定的用例
+func Conformance(t *testing.T, tl tool.InvokableTool, cases ...Case) {
+	t.Helper()
+	ctx := context.Background()
+
+	info, err := tl.Info(ctx)
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info == nil {
+		t.Fatal("Info returned nil")
+	}
+
+	var params *openapi3.Schema
+	t.Run("info", func(t *testing.T) {
+		if !toolNamePattern.MatchString(info.Name) {
+			t.Errorf("tool name %q does not match %s", info.Name, toolNamePattern)
+		}
+		if strings.TrimSpace(info.Desc) == "" {
+			t.Errorf("tool %s has no description", info.Name)
+		}
+	})
+	if info.ParamsOneOf != nil {
+		if params, err = info.ParamsOneOf.ToOpenAPIV3(); err != nil {
+			t.Fatalf("convert params of %s to json schema: %v", info.Name, err)
+		}
+	}
+	if params == nil || len(params.Properties) == 0 {
+		// 没有参数的工具可以忽略输入
+		runCases(t, tl, cases)
+		return
+	}
+
+	required := append([]string(nil), params.Required...)
+	sort.Strings(required)
+	for _, name := range required {
+		if _, ok := params.Properties[name]; !ok {
+			t.Errorf("required param %s is not declared in properties", name)
+		}
+	}
+	for name, prop := range params.Properties {
+		if prop.Value == nil || strings.TrimSpace(prop.Value.Description) == "" {
+			t.Errorf("param %s has no description", name)
+		}
+	}
+
+	t.Run("bad_json", func(t *testing.T) {
+		for _, args := range []string{`{"`, `not json`, `[]`} {
+			expectError(t, tl, args)
+		}
+	})
+	if len(required) == 0 {
+		runCases(t, tl, cases)
+		return
+	}
+	t.Run("empty_input", func(t *testing.T) {
+		expectError(t, tl, "")
+	})
+	t.Run("missing_required", func(t *testing.T) {
+		msg := expectError(t, tl, "{}")
+		if msg != "" && !containsAny(msg, required) {
+			t.Errorf("error %q does not name any of the required params %v", msg, required)
+		}
+	})
+	t.Run("wrong_type", func(t *testing.T) {
+		for _, name := range required {
+			prop := params.Properties[name].Value
+			if prop == nil || prop.Type != openapi3.TypeString {
+				continue
+			}
+			expectError(t, tl, fmt.Sprintf(`{%q: 12345}`, name))
+		}
+	})
+	runCases(t, tl, cases)
+}
+
+func runCases(t *testing.T, tl tool.InvokableTool, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			out, err := Invoke(t, tl, c.Args)
+			if c.WantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got output %q", out)
+				}
+				for _, s := range c.ErrContains {
+					if !strings.Contains(err.Error(), s) {
+						t.Errorf("error %q does not contain %q", err, s)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.Check != nil {
+				c.Check(t, out)
+			}
+		})
+	}
+}
+
+// Invoke 调用工具, panic 时测试失败
+func Invoke(t testing.TB, tl tool.InvokableTool, args string) (out string, err error) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("tool panicked on args %q: %v", args, r)
+		}
+	}()
+	return tl.InvokableRun(context.Background(), args)
+}
+
+// expectError 调用工具并要求返回非空的错误, 返回错误信息
+func expectError(t testing.TB, tl tool.InvokableTool, args string) string {
+	t.Helper()
+	out, err := Invoke(t, tl, args)
+	if err == nil {
+		t.Errorf("args %q: expected an error, got output %q", args, out)
+		return ""
+	}
+	if strings.TrimSpace(err.Error()) == "" {
+		t.Errorf("args %q: empty error message", args)
+	}
+	return err.Error()
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
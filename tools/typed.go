@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// TypedFunc 是带类型的工具实现, P 为参数结构体, R 为返回值
+// R 为 string 时原样返回给模型, 其他类型编码为 JSON
+type TypedFunc[P, R any] func(ctx context.Context, params P) (R, error)
+
+// typedTool 把 TypedFunc 包装为 tool.InvokableTool
+type typedTool[P, R any] struct {
+	info   *schema.ToolInfo
+	schema *openapi3.Schema
+	fn     TypedFunc[P, R]
+}
+
+// NewTypedTool 根据函数创建工具, 参数 schema 由 P 的字段标签生成:
+//
+//	json:"name"      参数名, 未设置时使用字段名
+//	desc:"..."       参数描述
+//	required:"true"  必填参数
+//	enum:"a,b,c"     可选值
+//
+// 支持 string, bool, 整数, 浮点数, 切片, 嵌套结构体及其指针
+func NewTypedTool[P, R any](name, desc string, fn TypedFunc[P, R]) (tool.InvokableTool, error) {
+	var zero P
+	typ := reflect.TypeOf(zero)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("params of tool %s must be a struct, got %T", name, zero)
+	}
+	params, err := structParams(typ, make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, fmt.Errorf("infer params of tool %s: %w", name, err)
+	}
+	info := &schema.ToolInfo{
+		Name: name,
+		Desc: desc,
+	}
+	if len(params) > 0 {
+		info.ParamsOneOf = schema.NewParamsOneOfByParams(params)
+	}
+	s, err := info.ParamsOneOf.ToOpenAPIV3()
+	if err != nil {
+		return nil, fmt.Errorf("convert params of tool %s: %w", name, err)
+	}
+	return &typedTool[P, R]{info: info, schema: s, fn: fn}, nil
+}
+
+// MustNewTypedTool 与 NewTypedTool 相同, 出错时 panic, 适用于参数类型固定的内置工具
+func MustNewTypedTool[P, R any](name, desc string, fn TypedFunc[P, R]) tool.InvokableTool {
+	t, err := NewTypedTool(name, desc, fn)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Register 创建带类型的工具并加入工具集
+func Register[P, R any](ts *ToolSet, name, desc string, fn TypedFunc[P, R]) error {
+	t, err := NewTypedTool(name, desc, fn)
+	if err != nil {
+		return err
+	}
+	return ts.AddTool(t)
+}
+
+func (t *typedTool[P, R]) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *typedTool[P, R]) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	if strings.TrimSpace(argumentsInJSON) == "" {
+		argumentsInJSON = "{}"
+	}
+	if err := ValidateArguments(t.info.Name, t.schema, argumentsInJSON); err != nil {
+		return "", err
+	}
+	var params P
+	if err := json.Unmarshal([]byte(argumentsInJSON), &params); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+	res, err := t.fn(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := any(res).(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return "", fmt.Errorf("encode result: %w", err)
+	}
+	return string(data), nil
+}
+
+// structParams 生成结构体字段的参数定义, visiting 记录正在展开的结构体, 用于发现自引用的类型
+func structParams(typ reflect.Type, visiting map[reflect.Type]bool) (map[string]*schema.ParameterInfo, error) {
+	if visiting[typ] {
+		return nil, fmt.Errorf("recursive type %s is not supported", typ)
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	params := make(map[string]*schema.ParameterInfo, typ.NumField())
+	// promoted 是匿名嵌入结构体的字段, 与 encoding/json 一样展开到外层, 同名时外层字段优先
+	promoted := make(map[string]*schema.ParameterInfo)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				sub, err := structParams(embedded, visiting)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.Name, err)
+				}
+				for n, p := range sub {
+					if _, ok := promoted[n]; !ok {
+						promoted[n] = p
+					}
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		p, err := paramInfo(field.Type, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		p.Desc = field.Tag.Get("desc")
+		p.Required = field.Tag.Get("required") == "true"
+		if enum := field.Tag.Get("enum"); enum != "" {
+			for _, e := range strings.Split(enum, ",") {
+				p.Enum = append(p.Enum, strings.TrimSpace(e))
+			}
+		}
+		params[name] = p
+	}
+	for name, p := range promoted {
+		if _, ok := params[name]; !ok {
+			params[name] = p
+		}
+	}
+	return params, nil
+}
+
+func paramInfo(typ reflect.Type, visiting map[reflect.Type]bool) (*schema.ParameterInfo, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return &schema.ParameterInfo{Type: schema.String}, nil
+	case reflect.Bool:
+		return &schema.ParameterInfo{Type: schema.Boolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schema.ParameterInfo{Type: schema.Integer}, nil
+	case reflect.Float32, reflect.Float64:
+		return &schema.ParameterInfo{Type: schema.Number}, nil
+	case reflect.Slice, reflect.Array:
+		elem, err := paramInfo(typ.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &schema.ParameterInfo{Type: schema.Array, ElemInfo: elem}, nil
+	case reflect.Struct:
+		sub, err := structParams(typ, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &schema.ParameterInfo{Type: schema.Object, SubParams: sub}, nil
+	case reflect.Map:
+		return &schema.ParameterInfo{Type: schema.Object}, nil
+	case reflect.Interface:
+		// any 类型的字段接受任意 JSON 值, 不限定类型
+		return &schema.ParameterInfo{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+}
@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+type searchParams struct {
+	Query  string   `json:"query" desc:"search query" required:"true"`
+	Limit  int      `json:"limit" desc:"max results"`
+	Order  string   `json:"order" desc:"sort order" enum:"asc, desc"`
+	Paths  []string `json:"paths" desc:"paths to search"`
+	Filter *struct {
+		Lang string `json:"lang" desc:"language" required:"true"`
+	} `json:"filter" desc:"optional filter"`
+	internal string
+}
+
+type searchResult struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+func TestTypedToolSchema(t *testing.T) {
+	tl, err := NewTypedTool("search", "search things", func(ctx context.Context, p searchParams) (searchResult, error) {
+		return searchResult{Query: p.Query, Limit: p.Limit}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := tl.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := info.ParamsOneOf.ToOpenAPIV3()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Required) != 1 || s.Required[0] != "query" {
+		t.Errorf("required = %v", s.Required)
+	}
+	wantTypes := map[string]string{
+		"query":  openapi3.TypeString,
+		"limit":  openapi3.TypeInteger,
+		"order":  openapi3.TypeString,
+		"paths":  openapi3.TypeArray,
+		"filter": openapi3.TypeObject,
+	}
+	if len(s.Properties) != len(wantTypes) {
+		t.Errorf("properties = %v", s.Properties)
+	}
+	for name, typ := range wantTypes {
+		prop, ok := s.Properties[name]
+		if !ok {
+			t.Errorf("missing property %s", name)
+			continue
+		}
+		if prop.Value.Type != typ {
+			t.Errorf("%s type = %s, want %s", name, prop.Value.Type, typ)
+		}
+	}
+	if enum := s.Properties["order"].Value.Enum; len(enum) != 2 || enum[1] != "desc" {
+		t.Errorf("order enum = %v", enum)
+	}
+	if items := s.Properties["paths"].Value.Items; items == nil || items.Value.Type != openapi3.TypeString {
+		t.Errorf("paths items = %v", items)
+	}
+	if req := s.Properties["filter"].Value.Required; len(req) != 1 || req[0] != "lang" {
+		t.Errorf("filter required = %v", req)
+	}
+
+	out, err := tl.InvokableRun(context.Background(), `{"query":"cosmica","limit":3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `{"query":"cosmica","limit":3}` {
+		t.Errorf("got %s", out)
+	}
+	if _, err := tl.InvokableRun(context.Background(), `{"limit":3}`); err == nil {
+		t.Error("expected an error for missing query")
+	}
+}
+
+func TestTypedToolRejectsNonStruct(t *testing.T) {
+	_, err := NewTypedTool("bad", "bad", func(ctx context.Context, p string) (string, error) {
+		return p, nil
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+type treeNode struct {
+	Name     string     `json:"name" desc:"node name"`
+	Children []treeNode `json:"children" desc:"child nodes"`
+}
+
+type pairParams struct {
+	// the same struct twice is not a cycle
+	Left  searchResult `json:"left" desc:"left side"`
+	Right searchResult `json:"right" desc:"right side"`
+}
+
+func TestTypedToolRejectsRecursiveParams(t *testing.T) {
+	_, err := NewTypedTool("tree", "walk a tree", func(ctx context.Context, p treeNode) (string, error) {
+		return p.Name, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "recursive type tools.treeNode") {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := NewTypedTool("pair", "compare a pair", func(ctx context.Context, p pairParams) (string, error) {
+		return "", nil
+	}); err != nil {
+		t.Errorf("repeated struct rejected: %v", err)
+	}
+}
+
+type commonParams struct {
+	Limit int    `json:"limit" desc:"max results"`
+	Query string `json:"query" desc:"shadowed by the outer field"`
+}
+
+type embeddedParams struct {
+	commonParams
+	Query  string            `json:"query" desc:"search query" required:"true"`
+	Value  any               `json:"value" desc:"any JSON value"`
+	Labels map[string]string `json:"labels" desc:"labels"`
+}
+
+func TestTypedToolEmbeddedAndAnyFields(t *testing.T) {
+	tl, err := NewTypedTool("find", "find things", func(ctx context.Context, p embeddedParams) (string, error) {
+		return fmt.Sprintf("%s %d %v", p.Query, p.Limit, p.Value), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := tl.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := info.ParamsOneOf.ToOpenAPIV3()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := map[string]string{
+		"limit":  openapi3.TypeInteger,
+		"query":  openapi3.TypeString,
+		"value":  "",
+		"labels": openapi3.TypeObject,
+	}
+	if len(s.Properties) != len(wantTypes) {
+		t.Errorf("properties = %v", s.Properties)
+	}
+	for name, typ := range wantTypes {
+		if prop, ok := s.Properties[name]; !ok || prop.Value.Type != typ {
+			t.Errorf("property %s = %v, want type %q", name, prop, typ)
+		}
+	}
+	if desc := s.Properties["query"].Value.Description; desc != "search query" {
+		t.Errorf("query desc = %q", desc)
+	}
+
+	ts, err := NewToolSet(tl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for args, want := range map[string]string{
+		`{"query":"q","limit":2,"value":"str"}`: "q 2 str",
+		`{"query":"q","value":[1,true]}`:        "q 0 [1 true]",
+	} {
+		if out, err := ts.Invoke(context.Background(), "find", args); err != nil || out != want {
+			t.Errorf("Invoke(%s) = %q, %v, want %q", args, out, err, want)
+		}
+	}
+}
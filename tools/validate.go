@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// ArgumentError 表示模型给出的工具参数不符合工具声明的 schema
+// 错误信息会原样返回给模型, 因此需要指出具体哪个参数有什么问题
+type ArgumentError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("invalid arguments for tool %s: %s. Call the tool again with arguments that match its parameter schema",
+		e.Tool, strings.Join(e.Problems, "; "))
+}
+
+// ValidateArguments 校验参数 JSON 是否满足 schema, schema 为 nil 时不做任何校验
+// ToolSet 在调用前总会校验, 单独使用的工具(例如 MCP 远程工具)也可以用它自行校验
+func ValidateArguments(toolName string, s *openapi3.Schema, argumentsInJSON string) error {
+	if s == nil {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal([]byte(argumentsInJSON), &value); err != nil {
+		return &ArgumentError{Tool: toolName, Problems: []string{fmt.Sprintf("arguments are not valid JSON (%v)", err)}}
+	}
+	var problems []string
+	validateValue("arguments", s, value, &problems)
+	if len(problems) > 0 {
+		return &ArgumentError{Tool: toolName, Problems: problems}
+	}
+	return nil
+}
+
+func validateValue(path string, s *openapi3.Schema, value any, problems *[]string) {
+	if s == nil {
+		return
+	}
+	if value == nil {
+		if !s.Nullable && s.Type != "" && s.Type != "null" {
+			*problems = append(*problems, fmt.Sprintf("%s must be %s, got null", path, article(s.Type)))
+		}
+		return
+	}
+
+	switch s.Type {
+	case openapi3.TypeObject:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be an object, got %s", path, jsonType(value)))
+			return
+		}
+		required := append([]string(nil), s.Required...)
+		sort.Strings(required)
+		for _, name := range required {
+			if v, ok := obj[name]; !ok || v == nil {
+				*problems = append(*problems, fmt.Sprintf("missing required param %q", join(path, name)))
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v, ok := obj[name]
+			if !ok || v == nil {
+				continue
+			}
+			if ref := s.Properties[name]; ref != nil {
+				validateValue(join(path, name), ref.Value, v, problems)
+			}
+		}
+		return
+	case openapi3.TypeArray:
+		arr, ok := value.([]any)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be an array, got %s", path, jsonType(value)))
+			return
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), s.Items.Value, item, problems)
+			}
+		}
+		return
+	case openapi3.TypeString:
+		if _, ok := value.(string); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be a string, got %s", path, jsonType(value)))
+			return
+		}
+	case openapi3.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be a boolean, got %s", path, jsonType(value)))
+			return
+		}
+	case openapi3.TypeNumber:
+		if _, ok := value.(float64); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be a number, got %s", path, jsonType(value)))
+			return
+		}
+	case openapi3.TypeInteger:
+		f, ok := value.(float64)
+		if !ok || f != math.Trunc(f) {
+			*problems = append(*problems, fmt.Sprintf("%s must be an integer, got %s", path, jsonType(value)))
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		options := make([]string, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			options = append(options, fmt.Sprintf("%v", e))
+		}
+		*problems = append(*problems, fmt.Sprintf("%s must be one of [%s], got %v", path, strings.Join(options, ", "), value))
+	}
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// join 拼接参数路径, 顶层参数直接使用参数名
+func join(path, name string) string {
+	if path == "arguments" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func article(typ string) string {
+	switch typ {
+	case openapi3.TypeObject, openapi3.TypeArray, openapi3.TypeInteger:
+		return "an " + typ
+	default:
+		return "a " + typ
+	}
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type echoTool struct{}
+
+func (echoTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: "echo",
+		Desc: "echo the arguments",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"text":  {Desc: "text to echo", Type: schema.String, Required: true},
+			"times": {Desc: "repeat count", Type: schema.Integer},
+			"mode":  {Desc: "echo mode", Type: schema.String, Enum: []string{"plain", "loud"}},
+			"tags": {Desc: "tags", Type: schema.Array, ElemInfo: &schema.ParameterInfo{
+				Type: schema.String,
+			}},
+		}),
+	}, nil
+}
+
+func (echoTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return argumentsInJSON, nil
+}
+
+func TestInvokeValidatesArguments(t *testing.T) {
+	ts, err := NewToolSet(echoTool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{`{"text":"hi"}`, nil},
+		{`{}`, []string{`missing required param "text"`}},
+		{`{"text":null}`, []string{`missing required param "text"`}},
+		{`{"text":1}`, []string{"text must be a string, got integer"}},
+		{`{"text":"hi","times":1.5}`, []string{"times must be an integer, got number"}},
+		{`{"text":"hi","mode":"quiet"}`, []string{"mode must be one of [plain, loud], got quiet"}},
+		{`{"text":"hi","tags":["a",2]}`, []string{"tags[1] must be a string, got integer"}},
+		{`[]`, []string{"arguments must be an object, got array"}},
+		{`{"text":`, []string{"arguments are not valid JSON"}},
+	}
+	for _, tt := range tests {
+		_, err := ts.Invoke(context.Background(), "echo", tt.args)
+		if len(tt.want) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.args, err)
+			}
+			continue
+		}
+		var argErr *ArgumentError
+		if !errors.As(err, &argErr) {
+			t.Errorf("%s: expected *ArgumentError, got %v", tt.args, err)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(err.Error(), w) {
+				t.Errorf("%s: error %q does not contain %q", tt.args, err, w)
+			}
+		}
+	}
+
+	if _, err := ts.Invoke(context.Background(), "missing", "{}"); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("expected ErrToolNotFound, got %v", err)
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "{}", true},
+		{`{"a":1}`, `{"a":1}`, true},
+		{"```json\n{\"a\":1}\n```", `{"a":1}`, true},
+		{`arguments: {"a":1}`, `{"a":1}`, true},
+		{`{"a":[1,2,],}`, `{"a":[1,2]}`, true},
+		{`{"a":"b`, `{"a":"b"}`, true},
+		{`{"a":{"b":1`, `{"a":{"b":1}}`, true},
+		{`{'a':1}`, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := repairJSON(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("repairJSON(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bootun/cosmica/utils/text"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxPanelLines 展开的工具面板中参数与结果各自最多显示的行数
+const maxPanelLines = 20
+
+type entryKind int
+
+const (
+	entryUser entryKind = iota
+	entryAssistant
+	entryTool
+	entryInfo
+	entryError
+)
+
+// entry 是对话记录中的一项, 工具调用以可折叠的面板显示
+type entry struct {
+	kind entryKind
+	text string
+
+	// 以下字段仅工具调用使用
+	call     schema.ToolCall
+	result   string
+	err      error
+	rejected bool
+	done     bool
+	duration time.Duration
+	expanded bool
+
+	// markdown 累积回答渲染后的文本, 回答只会变长, 每次只追加新到达的部分
+	// rendered 缓存渲染结果, 以长度判断是否过期
+	markdown    *text.MarkdownBuffer
+	markdownLen int
+	rendered    string
+}
+
+var (
+	userStyle      = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	infoStyle      = lipgloss.NewStyle().Faint(true)
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	panelStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8")).Padding(0, 1)
+	selectedBorder = lipgloss.Color("11")
+	labelStyle     = lipgloss.NewStyle().Faint(true)
+)
+
+// historyEntries 由历史消息重建对话记录, 用于 /undo、/load 等替换了历史的命令
+func historyEntries(history []*schema.Message) []*entry {
+	var entries []*entry
+	calls := make(map[string]*entry)
+	for _, msg := range history {
+		switch msg.Role {
+		case schema.User:
+			entries = append(entries, &entry{kind: entryUser, text: msg.Content})
+		case schema.Assistant:
+			if msg.Content != "" {
+				entries = append(entries, &entry{kind: entryAssistant, text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				e := &entry{kind: entryTool, call: call}
+				entries = append(entries, e)
+				calls[call.ID] = e
+			}
+		case schema.Tool:
+			if e, ok := calls[msg.ToolCallID]; ok {
+				e.result, e.done = msg.Content, true
+			}
+		}
+	}
+	return entries
+}
+
+// render 把记录项渲染为不超过 width 列的文本
+func (e *entry) render(width int, selected bool, theme text.Theme) string {
+	wrap := lipgloss.NewStyle().Width(width)
+	switch e.kind {
+	case entryUser:
+		return wrap.Render(userStyle.Render("› ") + e.text)
+	case entryAssistant:
+		if e.markdown == nil || len(e.text) < e.markdownLen {
+			e.markdown, e.markdownLen = text.NewMarkdownBuffer(theme), 0
+		}
+		if e.markdownLen != len(e.text) || e.rendered == "" {
+			e.markdown.WriteString(e.text[e.markdownLen:])
+			e.markdownLen = len(e.text)
+			e.rendered = e.markdown.String()
+		}
+		return wrap.Render(e.rendered)
+	case entryInfo:
+		return infoStyle.Width(width).Render(e.text)
+	case entryError:
+		return errorStyle.Width(width).Render("error: " + e.text)
+	}
+
+	style := panelStyle
+	if selected {
+		style = style.BorderForeground(selectedBorder)
+	}
+	// 边框与内边距共占 4 列
+	inner := max(width-4, 10)
+	arrow := "▸"
+	if e.expanded {
+		arrow = "▾"
+	}
+	header := fmt.Sprintf("%s %s %s", arrow, e.call.Function.Name, e.statusIcon())
+	if !e.expanded {
+		header += " " + labelStyle.Render(oneLine(e.call.Function.Arguments))
+		return style.Width(width - 2).Render(truncate(header, inner))
+	}
+	var b strings.Builder
+	b.WriteString(header)
+	b.WriteString("\n" + labelStyle.Render("arguments"))
+	b.WriteString("\n" + clip(prettyJSON(e.call.Function.Arguments), maxPanelLines))
+	switch {
+	case e.rejected:
+		b.WriteString("\n" + labelStyle.Render("rejected"))
+	case e.err != nil:
+		b.WriteString("\n" + labelStyle.Render("error"))
+		b.WriteString("\n" + errorStyle.Render(clip(e.err.Error(), maxPanelLines)))
+	case e.done:
+		b.WriteString("\n" + labelStyle.Render(fmt.Sprintf("result (%s)", e.duration.Round(time.Millisecond))))
+		b.WriteString("\n" + clip(e.result, maxPanelLines))
+	}
+	return style.Width(width - 2).Render(lipgloss.NewStyle().Width(inner).Render(b.String()))
+}
+
+func (e *entry) statusIcon() string {
+	switch {
+	case e.rejected:
+		return "⊘"
+	case e.err != nil:
+		return errorStyle.Render("✗")
+	case e.done:
+		return "✓"
+	default:
+		return "…"
+	}
+}
+
+// prettyJSON 缩进合法的 JSON, 其他内容原样返回
+func prettyJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// clip 只保留前 n 行, 其余以省略说明代替
+func clip(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n… %d more lines", len(lines)-n)
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate 把单行文本截断到 width 列
+func truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
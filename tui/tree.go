@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type nodeState int
+
+const (
+	nodeRunning nodeState = iota
+	nodeDone
+	nodeFailed
+)
+
+// node 是子 agent 树中的一次运行
+type node struct {
+	agent    string
+	task     string
+	state    nodeState
+	activity string
+	children []*node
+}
+
+// agentTree 记录当前问题中 create_agent 创建的子 agent, 每个新问题重新开始
+type agentTree struct {
+	root  *node
+	nodes map[string]*node
+	// tasks 父 agent 的 RunID 到其最近委派的任务, 子 agent 开始运行时取出
+	tasks map[string]string
+}
+
+func newAgentTree() *agentTree {
+	return &agentTree{nodes: make(map[string]*node), tasks: make(map[string]string)}
+}
+
+// empty 报告是否有子 agent, 没有时不显示侧栏
+func (t *agentTree) empty() bool {
+	return t.root == nil || len(t.root.children) == 0
+}
+
+func (t *agentTree) observe(ev agent.Event) {
+	src := ev.EventSource()
+	switch ev := ev.(type) {
+	case agent.TurnStarted:
+		n := &node{agent: src.Agent, task: ev.Question}
+		if src.Depth == 0 {
+			*t = *newAgentTree()
+			t.root = n
+		} else if parent, ok := t.nodes[src.Parent]; ok {
+			if task, ok := t.tasks[src.Parent]; ok {
+				n.task = task
+				delete(t.tasks, src.Parent)
+			}
+			parent.children = append(parent.children, n)
+		} else {
+			return
+		}
+		t.nodes[src.RunID] = n
+	case agent.SubAgentSpawned:
+		t.tasks[src.RunID] = ev.Task
+	case agent.ToolCallRequested:
+		if n, ok := t.nodes[src.RunID]; ok {
+			n.activity = "→ " + ev.Call.Function.Name
+		}
+	case agent.Finished:
+		if n, ok := t.nodes[src.RunID]; ok {
+			n.state, n.activity = nodeDone, ""
+		}
+	case agent.Failed:
+		if n, ok := t.nodes[src.RunID]; ok {
+			n.state, n.activity = nodeFailed, ev.Err.Error()
+		}
+	}
+}
+
+var treeTitleStyle = lipgloss.NewStyle().Bold(true)
+
+// render 以缩进的形式渲染树, 每行不超过 width 列
+func (t *agentTree) render(width int) string {
+	var b strings.Builder
+	b.WriteString(treeTitleStyle.Render("agents"))
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		indent := strings.Repeat("  ", depth)
+		b.WriteString("\n" + truncate(fmt.Sprintf("%s%s %s", indent, n.icon(), n.agent), width))
+		if depth > 0 && n.task != "" {
+			b.WriteString("\n" + labelStyle.Render(truncate(indent+"  "+oneLine(n.task), width)))
+		}
+		if n.activity != "" {
+			b.WriteString("\n" + labelStyle.Render(truncate(indent+"  "+n.activity, width)))
+		}
+		for _, c := range n.children {
+			walk(c, depth+1)
+		}
+	}
+	if t.root != nil {
+		walk(t.root, 0)
+	}
+	return b.String()
+}
+
+func (n *node) icon() string {
+	switch n.state {
+	case nodeDone:
+		return "✓"
+	case nodeFailed:
+		return errorStyle.Render("✗")
+	default:
+		return "●"
+	}
+}
@@ -0,0 +1,630 @@
+// Package tui 以全屏终端界面与 agent 对话: 可滚动的对话记录、可折叠的工具调用面板、状态栏、多行输入与子 agent 树
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/command"
+	"github.com/bootun/cosmica/mention"
+	"github.com/bootun/cosmica/utils/text"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	inputHeight = 3
+	sideWidth   = 32
+	// eventBuffer 界面来不及处理时缓存的事件数, 缓冲区满时 agent 等待界面
+	eventBuffer = 256
+)
+
+// Events 把 agent 的事件转交给界面, 创建 agent 时通过 common.WithObserver 传入
+// 问题处理完毕的消息经过同一个通道, 保证界面先处理完这个问题的所有事件
+type Events struct {
+	ch chan tea.Msg
+}
+
+func NewEvents() *Events {
+	return &Events{ch: make(chan tea.Msg, eventBuffer)}
+}
+
+func (e *Events) OnEvent(ctx context.Context, ev agent.Event) {
+	e.send(ctx, eventMsg{ev: ev})
+}
+
+func (e *Events) send(ctx context.Context, msg tea.Msg) {
+	select {
+	case e.ch <- msg:
+	case <-ctx.Done():
+	}
+}
+
+// Option 定制界面
+type Option func(*Model)
+
+// WithTheme 指定渲染回答中 markdown 的主题, 默认不带颜色
+func WithTheme(theme text.Theme) Option {
+	return func(m *Model) {
+		m.theme = theme
+	}
+}
+
+// WithMentions 在提问前展开问题中的 @文件、@目录/ 引用与开头的 !命令, 输入框中按 tab 补全 @路径
+func WithMentions(e *mention.Expander) Option {
+	return func(m *Model) {
+		m.mentions = e
+	}
+}
+
+// WithCommands 指定处理以 / 开头的输入的命令表, 默认为 command.Default()
+func WithCommands(r *command.Registry) Option {
+	return func(m *Model) {
+		m.commands = r
+	}
+}
+
+type focusArea int
+
+const (
+	focusInput focusArea = iota
+	focusTranscript
+)
+
+type eventMsg struct {
+	ev agent.Event
+}
+
+type turnDoneMsg struct {
+	history []*schema.Message
+	err     error
+}
+
+// approvalMsg 请求用户批准一次工具调用, 结果写入 reply
+type approvalMsg struct {
+	call  schema.ToolCall
+	reply chan bool
+}
+
+// status 是状态栏显示的信息
+type status struct {
+	agent     string
+	model     string
+	tokens    int
+	iteration int
+	activity  string
+}
+
+// Model 是界面的 bubbletea 模型
+type Model struct {
+	ctx      context.Context
+	session  *command.Session
+	events   *Events
+	commands *command.Registry
+	mentions *mention.Expander
+	theme    text.Theme
+
+	// cancel 不为 nil 表示正在处理问题
+	cancel context.CancelFunc
+	// approval 不为 nil 表示正在等待用户批准工具调用
+	approval *approvalMsg
+
+	entries []*entry
+	// calls 工具调用 ID 到对话记录中的面板
+	calls map[string]*entry
+	// reply 当前一步中模型的回答, 新的一步开始时置空
+	reply  *entry
+	tree   *agentTree
+	status status
+
+	viewport viewport.Model
+	input    textarea.Model
+	focus    focusArea
+	// selected 选中的工具面板在 entries 中的下标, -1 表示未选中
+	selected int
+	// follow 为 true 时新内容出现后滚动到底部
+	follow bool
+	width  int
+	height int
+
+	// inputs 提交过的输入, historyIdx 为正在浏览的位置, draft 为浏览前未提交的输入
+	inputs     []string
+	historyIdx int
+	draft      string
+}
+
+// New 创建在会话 s 中对话的界面, events 必须是会话中 agent 的观察者
+func New(ctx context.Context, s *command.Session, events *Events, opts ...Option) *Model {
+	input := textarea.New()
+	input.Placeholder = "Ask anything (enter to send, alt+enter for a new line)"
+	input.ShowLineNumbers = false
+	input.SetHeight(inputHeight)
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	input.Focus()
+
+	m := &Model{
+		ctx:      ctx,
+		session:  s,
+		events:   events,
+		commands: command.Default(),
+		calls:    make(map[string]*entry),
+		tree:     newAgentTree(),
+		viewport: viewport.New(0, 0),
+		input:    input,
+		selected: -1,
+		follow:   true,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	// 正在处理问题时 /cancel 由 submit 直接处理, 注册它是为了出现在 /help 中
+	// 命令表中已有同名命令时保留原来的
+	_ = m.commands.Register(command.Command{Name: "cancel", Help: "取消正在处理的问题", Run: func(ctx context.Context, s *command.Session, args []string) (command.Result, error) {
+		return command.Result{Output: "nothing to cancel"}, nil
+	}})
+	return m
+}
+
+// Run 全屏运行界面直到用户退出
+func Run(m *Model) error {
+	_, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(m.ctx)).Run()
+	if errors.Is(err, tea.ErrProgramKilled) && m.ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (m *Model) Init() tea.Cmd {
+	return tea.Batch(textarea.Blink, m.waitEvent())
+}
+
+// waitEvent 等待 agent 的下一个事件或问题处理完毕
+func (m *Model) waitEvent() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case msg := <-m.events.ch:
+			return msg
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		return m, nil
+	case eventMsg:
+		m.observe(msg.ev)
+		m.refresh()
+		return m, m.waitEvent()
+	case turnDoneMsg:
+		m.finishTurn(msg)
+		m.refresh()
+		return m, m.waitEvent()
+	case approvalMsg:
+		m.approval = &msg
+		m.entries = append(m.entries, &entry{kind: entryInfo, text: fmt.Sprintf("run %s %s? press y to approve, any other key to reject", msg.call.Function.Name, oneLine(msg.call.Function.Arguments))})
+		m.follow = true
+		m.refresh()
+		return m, m.waitEvent()
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// Approve 在界面中请求用户批准工具调用, 可以作为 agent.Approver 使用, 例如执行问题开头的 !命令 前
+// 在处理问题的协程中调用, 阻塞到用户按键或 ctx 结束
+func (m *Model) Approve(ctx context.Context, call schema.ToolCall) bool {
+	reply := make(chan bool, 1)
+	m.events.send(ctx, approvalMsg{call: call, reply: reply})
+	select {
+	case ok := <-reply:
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// 等待批准时按 y 批准, 其他键拒绝, ctrl+c 等按键在拒绝后照常处理
+	if m.approval != nil {
+		ok := msg.String() == "y"
+		m.approval.reply <- ok
+		m.approval = nil
+		result := "rejected"
+		if ok {
+			result = "approved"
+		}
+		m.entries = append(m.entries, &entry{kind: entryInfo, text: result})
+		m.refresh()
+		if ok || (msg.String() != "ctrl+c" && msg.String() != "ctrl+d") {
+			return m, nil
+		}
+	}
+	switch msg.String() {
+	case "ctrl+c":
+		// 先取消正在处理的问题, 空闲时退出
+		if m.cancel != nil {
+			m.cancel()
+			return m, nil
+		}
+		return m, tea.Quit
+	case "ctrl+d":
+		if m.cancel != nil {
+			m.cancel()
+		}
+		return m, tea.Quit
+	case "tab":
+		// 光标前是 @路径 时补全路径, 否则在输入框与工具面板之间切换
+		if m.focus == focusInput && m.complete() {
+			return m, nil
+		}
+		m.toggleFocus()
+		m.refresh()
+		return m, nil
+	case "pgup", "pgdown":
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		m.follow = m.viewport.AtBottom()
+		return m, cmd
+	}
+	if m.focus == focusTranscript {
+		m.handleTranscriptKey(msg)
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "enter":
+		return m, m.submit()
+	case "up":
+		if m.input.Line() == 0 && m.browseHistory(-1) {
+			return m, nil
+		}
+	case "down":
+		if m.input.Line() == m.input.LineCount()-1 && m.browseHistory(1) {
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// complete 补全输入框中光标前以 @ 开头的路径, 没有可补全的内容时返回 false
+func (m *Model) complete() bool {
+	if m.mentions == nil {
+		return false
+	}
+	lines := strings.Split(m.input.Value(), "\n")
+	row := m.input.Line()
+	info := m.input.LineInfo()
+	current := []rune(lines[row])
+	col := min(info.StartColumn+info.ColumnOffset, len(current))
+	before := strings.Join(append(lines[:row:row], string(current[:col])), "\n")
+	completed, _, ok := mention.Complete(before, len(before))
+	if !ok {
+		return false
+	}
+	// 补全只在光标处追加内容
+	m.input.InsertString(completed[len(before):])
+	return true
+}
+
+// handleTranscriptKey 在对话记录中选择并折叠或展开工具面板
+func (m *Model) handleTranscriptKey(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		m.toggleFocus()
+	case "up", "k":
+		m.selectTool(-1)
+	case "down", "j":
+		m.selectTool(1)
+	case "enter", " ":
+		if m.selected >= 0 {
+			m.entries[m.selected].expanded = !m.entries[m.selected].expanded
+		}
+	case "a":
+		// 有折叠的面板时全部展开, 否则全部折叠
+		expand := false
+		for _, e := range m.entries {
+			if e.kind == entryTool && !e.expanded {
+				expand = true
+			}
+		}
+		for _, e := range m.entries {
+			if e.kind == entryTool {
+				e.expanded = expand
+			}
+		}
+	default:
+		return
+	}
+	m.refresh()
+}
+
+func (m *Model) toggleFocus() {
+	if m.focus == focusInput {
+		m.focus = focusTranscript
+		m.input.Blur()
+		if m.selected < 0 {
+			m.selectTool(-1)
+		}
+		return
+	}
+	m.focus = focusInput
+	m.selected = -1
+	m.follow = true
+	m.input.Focus()
+}
+
+// selectTool 选中上一个(-1)或下一个(1)工具面板, 未选中时从最后一个开始
+func (m *Model) selectTool(dir int) {
+	i := m.selected
+	if i < 0 {
+		i = len(m.entries)
+		dir = -1
+	}
+	for j := i + dir; j >= 0 && j < len(m.entries); j += dir {
+		if m.entries[j].kind == entryTool {
+			m.selected = j
+			m.follow = false
+			return
+		}
+	}
+}
+
+// browseHistory 在提交过的输入中前后浏览, 没有更多输入时返回 false
+func (m *Model) browseHistory(dir int) bool {
+	i := m.historyIdx + dir
+	if i < 0 || i > len(m.inputs) || len(m.inputs) == 0 {
+		return false
+	}
+	if m.historyIdx == len(m.inputs) {
+		m.draft = m.input.Value()
+	}
+	m.historyIdx = i
+	if i == len(m.inputs) {
+		m.input.SetValue(m.draft)
+	} else {
+		m.input.SetValue(m.inputs[i])
+	}
+	return true
+}
+
+// submit 提交输入: 以 / 开头的交给命令处理, 其余作为问题交给 agent
+func (m *Model) submit() tea.Cmd {
+	line := strings.TrimSpace(m.input.Value())
+	if line == "" {
+		return nil
+	}
+	m.input.Reset()
+	m.inputs = append(m.inputs, line)
+	m.historyIdx, m.draft = len(m.inputs), ""
+	m.follow = true
+
+	// 命令会读写会话, 不能与正在处理的问题同时进行, 处理期间只能取消或退出
+	if m.cancel != nil {
+		switch strings.Fields(line)[0] {
+		case "/cancel":
+			m.cancel()
+			return nil
+		case "/quit", "/exit":
+			m.cancel()
+			return tea.Quit
+		}
+		m.entries = append(m.entries, &entry{kind: entryInfo, text: "the agent is still working, use /cancel or ctrl+c to cancel"})
+		m.refresh()
+		return nil
+	}
+	if command.IsCommand(line) {
+		return m.runCommand(line)
+	}
+	return m.ask(line)
+}
+
+// runCommand 执行命令, 历史被替换时按新的历史重新显示对话
+func (m *Model) runCommand(line string) tea.Cmd {
+	m.entries = append(m.entries, &entry{kind: entryUser, text: line})
+	res, err := m.commands.Run(m.ctx, m.session, line)
+	if err != nil {
+		m.entries = append(m.entries, &entry{kind: entryError, text: err.Error()})
+		m.refresh()
+		return nil
+	}
+	if res.Quit {
+		return tea.Quit
+	}
+	m.status.agent = m.session.AgentName
+	if res.Model != "" {
+		m.status.model = res.Model
+	}
+	if res.Reset {
+		m.entries = historyEntries(m.session.History)
+		m.calls = make(map[string]*entry)
+		m.selected = -1
+	}
+	if out := strings.TrimRight(res.Output, "\n"); out != "" {
+		m.entries = append(m.entries, &entry{kind: entryInfo, text: out})
+	}
+	if res.Ask != "" {
+		return m.ask(res.Ask)
+	}
+	m.refresh()
+	return nil
+}
+
+// ask 把问题交给 agent, 处理完毕后经事件通道通知界面
+func (m *Model) ask(question string) tea.Cmd {
+	m.entries = append(m.entries, &entry{kind: entryUser, text: question})
+	m.refresh()
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancel = cancel
+	a, history, events, mentions := m.session.Agent, m.session.History, m.events, m.mentions
+	return func() tea.Msg {
+		// 引用的命令可能较慢, 与问题一起在后台处理
+		if mentions != nil {
+			question = mentions.Expand(ctx, question)
+		}
+		newHistory, err := a.HandleQuestion(ctx, question, history)
+		events.send(m.ctx, turnDoneMsg{history: newHistory, err: err})
+		return nil
+	}
+}
+
+// finishTurn 问题处理完毕, 出错或被取消时保留原来的历史
+func (m *Model) finishTurn(msg turnDoneMsg) {
+	canceled := m.cancel != nil && errors.Is(msg.err, context.Canceled)
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.reply = nil
+	m.approval = nil
+	m.status.activity = ""
+	switch {
+	case msg.err == nil:
+		m.session.History = msg.history
+	case canceled:
+		m.entries = append(m.entries, &entry{kind: entryInfo, text: "canceled"})
+	default:
+		m.entries = append(m.entries, &entry{kind: entryError, text: msg.err.Error()})
+	}
+}
+
+// observe 根据 agent 的事件更新对话记录、状态栏与子 agent 树
+func (m *Model) observe(ev agent.Event) {
+	hadTree := !m.tree.empty()
+	m.tree.observe(ev)
+	if hadTree != !m.tree.empty() {
+		m.layout()
+	}
+
+	src := ev.EventSource()
+	if ev, ok := ev.(agent.StepFinished); ok && ev.Usage != nil {
+		m.status.tokens += ev.Usage.TotalTokens
+	}
+	// 对话记录与其余状态只反映顶层 agent, 子 agent 显示在侧栏中
+	if src.Depth > 0 {
+		return
+	}
+	switch ev := ev.(type) {
+	case agent.TurnStarted:
+		m.status.agent = src.Agent
+		m.status.iteration = 0
+	case agent.StepStarted:
+		m.status.iteration = ev.Iteration
+		m.status.activity = "thinking"
+		m.reply = nil
+	case agent.TokenDelta:
+		if m.reply == nil {
+			m.reply = &entry{kind: entryAssistant}
+			m.entries = append(m.entries, m.reply)
+		}
+		m.reply.text += ev.Content
+	case agent.StepFinished:
+		if ev.Model != "" {
+			m.status.model = ev.Model
+		}
+	case agent.ToolCallRequested:
+		e := &entry{kind: entryTool, call: ev.Call}
+		m.entries = append(m.entries, e)
+		m.calls[ev.Call.ID] = e
+		m.status.activity = "running " + ev.Call.Function.Name
+	case agent.ToolResult:
+		if e, ok := m.calls[ev.Call.ID]; ok {
+			e.result, e.err, e.rejected, e.duration, e.done = ev.Result, ev.Err, ev.Rejected, ev.Duration, true
+			delete(m.calls, ev.Call.ID)
+		}
+		m.status.activity = "thinking"
+	case agent.Finished, agent.Failed:
+		m.status.activity = ""
+	}
+}
+
+// layout 按窗口大小分配对话记录、侧栏与输入框的空间
+func (m *Model) layout() {
+	width := m.width
+	if !m.tree.empty() {
+		width -= sideWidth
+	}
+	m.viewport.Width = max(width, 1)
+	// 状态栏与帮助各占一行
+	m.viewport.Height = max(m.height-inputHeight-2, 1)
+	m.input.SetWidth(max(m.width, 1))
+	m.refresh()
+}
+
+// refresh 重新渲染对话记录, 选中的面板保持在可见范围内
+func (m *Model) refresh() {
+	width := m.viewport.Width
+	var b strings.Builder
+	selectedLine := -1
+	for i, e := range m.entries {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		if i == m.selected {
+			selectedLine = strings.Count(b.String(), "\n")
+		}
+		b.WriteString(e.render(width, i == m.selected, m.theme))
+	}
+	m.viewport.SetContent(b.String())
+	switch {
+	case m.follow:
+		m.viewport.GotoBottom()
+	case selectedLine >= 0 && (selectedLine < m.viewport.YOffset || selectedLine >= m.viewport.YOffset+m.viewport.Height):
+		m.viewport.SetYOffset(selectedLine)
+	}
+}
+
+var (
+	statusStyle = lipgloss.NewStyle().Reverse(true)
+	helpStyle   = lipgloss.NewStyle().Faint(true)
+	sideStyle   = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), false, false, false, true).PaddingLeft(1)
+)
+
+func (m *Model) View() string {
+	main := m.viewport.View()
+	if !m.tree.empty() {
+		side := sideStyle.Width(sideWidth - 1).Height(m.viewport.Height).Render(m.tree.render(sideWidth - 3))
+		main = lipgloss.JoinHorizontal(lipgloss.Top, main, side)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, main, m.statusView(), m.input.View(), m.helpView())
+}
+
+func (m *Model) statusView() string {
+	s := m.status
+	parts := []string{orDash(s.agent), orDash(s.model), fmt.Sprintf("%d tokens", s.tokens), fmt.Sprintf("step %d", s.iteration)}
+	if s.activity != "" {
+		parts = append(parts, s.activity+"…")
+	}
+	return statusStyle.Width(max(m.width, 1)).Render(truncate(" "+strings.Join(parts, " │ "), max(m.width, 1)))
+}
+
+func (m *Model) helpView() string {
+	help := "enter send • alt+enter newline • ↑/↓ history • tab complete @path/tool panels • pgup/pgdn scroll • ctrl+c cancel/quit"
+	if m.focus == focusTranscript {
+		help = "↑/↓ select • enter toggle • a toggle all • tab/esc back to input • pgup/pgdn scroll"
+	}
+	return helpStyle.Render(truncate(help, max(m.width, 1)))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,252 @@
+package tui
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bootun/cosmica/agent"
+	"github.com/bootun/cosmica/agent/common"
+	"github.com/bootun/cosmica/command"
+	"github.com/bootun/cosmica/mention"
+	"github.com/bootun/cosmica/provider/replay"
+	"github.com/bootun/cosmica/tools"
+	"github.com/bootun/cosmica/tools/base"
+	"github.com/bootun/cosmica/tools/compose"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cloudwego/eino/schema"
+)
+
+func newTestModel(t *testing.T) *Model {
+	t.Helper()
+	netizenTools, err := tools.NewToolSet(base.NewBell())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := tools.NewToolSet(base.NewBell(), compose.NewAgentCreator(common.NetizenFactory(
+		common.WithChatModel(replay.NewScripted(replay.Reply("晴", replay.ToolCall("bell", nil)))),
+		common.WithToolSet(netizenTools),
+		common.WithOutput(io.Discard),
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := replay.NewScripted(
+		replay.Reply("我让netizen查一下", replay.ToolCall("create_agent", map[string]string{
+			"name": "netizen",
+			"task": "查询天气",
+		})),
+		replay.Reply("北京今天晴", replay.ToolCall("bell", nil)),
+	)
+	events := NewEvents()
+	sm, err := common.NewSpaceMan(context.Background(), common.WithChatModel(m), common.WithToolSet(ts), common.WithOutput(io.Discard), common.WithObserver(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := command.Default()
+	err = commands.Register(command.Command{Name: "ping", Run: func(ctx context.Context, s *command.Session, args []string) (command.Result, error) {
+		return command.Result{Output: "pong"}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ui := New(context.Background(), &command.Session{Agent: sm, AgentName: agent.AgentSpaceman}, events, WithCommands(commands))
+	ui.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	return ui
+}
+
+// ask 输入问题并处理完 agent 的所有事件
+func ask(t *testing.T, m *Model, question string) {
+	t.Helper()
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(question)})
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		return
+	}
+	go cmd()
+	for {
+		msg := m.waitEvent()()
+		m.Update(msg)
+		if _, ok := msg.(turnDoneMsg); ok {
+			return
+		}
+	}
+}
+
+func TestTranscriptAndStatus(t *testing.T) {
+	m := newTestModel(t)
+	ask(t, m, "天气怎么样")
+
+	view := m.View()
+	for _, want := range []string{"› 天气怎么样", "我让netizen查一下", "北京今天晴", "▸ create_agent ✓", "▸ bell ✓", "spaceman", "step 2"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view does not contain %q:\n%s", want, view)
+		}
+	}
+	// 子 agent 只显示在侧栏中
+	if strings.Count(view, "晴") != 1 {
+		t.Errorf("sub-agent output leaked into the transcript:\n%s", view)
+	}
+	if !strings.Contains(view, "agents") || !strings.Contains(view, "✓ netizen") || !strings.Contains(view, "查询天气") {
+		t.Errorf("side pane does not show the sub-agent:\n%s", view)
+	}
+	if len(m.session.History) != 6 {
+		t.Errorf("history has %d messages, want 6", len(m.session.History))
+	}
+}
+
+func TestToolPanelsCollapse(t *testing.T) {
+	m := newTestModel(t)
+	ask(t, m, "天气怎么样")
+
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if m.selected < 0 || m.entries[m.selected].call.Function.Name != "bell" {
+		t.Fatalf("tab should select the last tool panel, got %d", m.selected)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	view := m.View()
+	if !strings.Contains(view, "▾ create_agent") || !strings.Contains(view, `"task": "查询天气"`) || !strings.Contains(view, "▸ bell") {
+		t.Errorf("create_agent panel should be expanded alone:\n%s", view)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if view := m.View(); !strings.Contains(view, "▾ bell") {
+		t.Errorf("a should expand every panel:\n%s", view)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.focus != focusInput || m.selected != -1 {
+		t.Error("esc should return to the input")
+	}
+}
+
+func TestInputHistoryAndCommands(t *testing.T) {
+	m := newTestModel(t)
+	ask(t, m, "/ping")
+	ask(t, m, "/nope")
+	if view := m.View(); !strings.Contains(view, "pong") || !strings.Contains(view, "unknown command /nope") {
+		t.Errorf("commands not shown:\n%s", view)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("draft")})
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if got := m.input.Value(); got != "/nope" {
+		t.Errorf("up = %q", got)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if got := m.input.Value(); got != "/ping" {
+		t.Errorf("up twice = %q", got)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if got := m.input.Value(); got != "draft" {
+		t.Errorf("down back to draft = %q", got)
+	}
+}
+
+func TestUndoRebuildsTranscript(t *testing.T) {
+	m := newTestModel(t)
+	ask(t, m, "天气怎么样")
+	ask(t, m, "/undo")
+
+	view := m.View()
+	if strings.Contains(view, "北京今天晴") || !strings.Contains(view, "removed: 天气怎么样") {
+		t.Errorf("transcript not rebuilt after /undo:\n%s", view)
+	}
+	if len(m.session.History) != 1 {
+		t.Errorf("history has %d messages after /undo, want only the system prompt", len(m.session.History))
+	}
+}
+
+func TestCancelAndQuitWhileBusy(t *testing.T) {
+	m := newTestModel(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/ping")})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if ctx.Err() != nil || !strings.Contains(m.View(), "still working") {
+		t.Fatal("other commands should wait for the agent")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/cancel")})
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil || ctx.Err() == nil {
+		t.Error("/cancel should cancel the running question")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/quit")})
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil || ctx.Err() == nil {
+		t.Fatal("/quit should cancel and quit")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("/quit should quit while busy")
+	}
+}
+
+// switcher 只记录切换的模型
+type switcher struct{ model string }
+
+func (s *switcher) HandleQuestion(ctx context.Context, question string, history []*schema.Message) ([]*schema.Message, error) {
+	return history, nil
+}
+
+func (s *switcher) SwitchModel(ctx context.Context, model string) error {
+	s.model = model
+	return nil
+}
+
+func TestModelSwitchUpdatesStatus(t *testing.T) {
+	m := New(context.Background(), &command.Session{Agent: &switcher{}, AgentName: agent.AgentSpaceman}, NewEvents())
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	ask(t, m, "/model fast")
+	if m.status.model != "fast" || !strings.Contains(m.View(), "fast") {
+		t.Errorf("status model = %q", m.status.model)
+	}
+	ask(t, m, "/cancel")
+	if !strings.Contains(m.View(), "nothing to cancel") {
+		t.Errorf("/cancel when idle:\n%s", m.View())
+	}
+}
+
+func TestMentionCommandNeedsApproval(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("main.go", []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestModel(t)
+	mentions := mention.NewExpander()
+	m.mentions = mentions
+	mentions.Approve = m.Approve
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("看看 @ma")})
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if got := m.input.Value(); got != "看看 @main.go" || m.focus != focusInput {
+		t.Fatalf("tab completion = %q", got)
+	}
+	m.input.Reset()
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("!echo hi")})
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	go cmd()
+	for {
+		msg := m.waitEvent()()
+		m.Update(msg)
+		if _, ok := msg.(approvalMsg); ok {
+			if !strings.Contains(m.View(), "run shell_executor") {
+				t.Errorf("approval prompt not shown:\n%s", m.View())
+			}
+			m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+		}
+		if _, ok := msg.(turnDoneMsg); ok {
+			break
+		}
+	}
+	question := m.session.History[1].Content
+	if !strings.Contains(question, "not run: the command was not approved") || !strings.Contains(m.View(), "rejected") {
+		t.Errorf("question = %q", question)
+	}
+}
@@ -0,0 +1,75 @@
+// Package fsutil 提供多个进程共用的本地文件的写入与互斥, 例如长期记忆与代码索引
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockRetry 锁被占用时重试的间隔
+	lockRetry = 20 * time.Millisecond
+	// lockTimeout 等待锁的最长时间
+	lockTimeout = 10 * time.Second
+	// staleLock 锁文件超过该时间未释放时视为持有者已经退出, 写入只需要毫秒级的时间
+	staleLock = time.Minute
+)
+
+// ErrLocked 等待锁超时
+var ErrLocked = errors.New("file is locked")
+
+// WriteFile 先写入同目录下的临时文件再重命名, 读取方不会看到写了一半的文件, 目录不存在时创建
+func WriteFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lock 以 path 旁的 .lock 文件在进程间互斥, 返回释放锁的函数
+// 锁被占用时最多等待 10 秒, 超时返回 ErrLocked; 持有者异常退出留下的锁文件在一分钟后失效
+func Lock(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	lock := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lock %s: %w", path, ErrLocked)
+		}
+		time.Sleep(lockRetry)
+	}
+}
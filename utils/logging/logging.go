@@ -0,0 +1,106 @@
+// Package logging 基于 log/slog 的结构化日志, context 中的会话、轮次与工具调用 ID 会自动附加到日志上
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/bootun/cosmica/config"
+)
+
+type attrsKey struct{}
+
+// WithAttrs 返回携带日志属性的 context, 使用该 context 的 slog.XxxContext 调用都会带上这些属性
+// 属性会累加, 例如会话 ID 在外层设置, 轮次与工具调用 ID 在对话循环中设置
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	merged = append(merged, prev...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// Digest 以长度与 SHA-256 前缀代替原文记录 s, 用于工具参数等可能含有密钥或隐私的内容
+// 日志中相同的摘要说明内容相同, 但无法还原原文
+func Digest(key, s string) slog.Attr {
+	sum := sha256.Sum256([]byte(s))
+	return slog.Group(key, slog.Int("len", len(s)), slog.String("sha256", hex.EncodeToString(sum[:6])))
+}
+
+// contextHandler 把 context 中的属性加到每条日志上
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewHandler 包装 h, 使其输出 WithAttrs 设置的属性
+func NewHandler(h slog.Handler) slog.Handler {
+	return contextHandler{Handler: h}
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// Setup 按配置创建默认 logger, 返回的 closer 关闭日志文件
+// interactive 为 true 时(终端对话)若未指定日志文件与级别, 只把错误输出到标准错误, 避免日志打断对话
+func Setup(cfg config.Logging, interactive bool) (io.Closer, error) {
+	level := slog.LevelInfo
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("parse log level: %w", err)
+		}
+	} else if interactive && cfg.File == "" {
+		level = slog.LevelError
+	}
+
+	var out io.Writer = os.Stderr
+	var file *os.File
+	if cfg.File != "" {
+		var err error
+		if file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+			return nil, fmt.Errorf("open log file: %w", err)
+		}
+		out = file
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		h = slog.NewTextHandler(out, opts)
+	case "json":
+		h = slog.NewJSONHandler(out, opts)
+	default:
+		if file != nil {
+			file.Close()
+		}
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	slog.SetDefault(slog.New(NewHandler(h)))
+	if file == nil {
+		return nopCloser{}, nil
+	}
+	return file, nil
+}
+
+// nopCloser 是输出到标准错误时返回的 closer, 标准错误不应被关闭
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,88 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bootun/cosmica/config"
+	"github.com/bootun/cosmica/utils/logging"
+)
+
+func TestHandlerAddsContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(&buf, nil)))
+	ctx := logging.WithAttrs(context.Background(), slog.String("session", "s1"))
+	ctx = logging.WithAttrs(ctx, slog.String("turn", "spaceman-1"))
+	logger.WarnContext(ctx, "tool call failed", "tool", "bell")
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"session": "s1", "turn": "spaceman-1", "tool": "bell", "msg": "tool call failed"} {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %s", k, got[k], v)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	path := filepath.Join(t.TempDir(), "cosmica.log")
+	closer, err := logging.Setup(config.Logging{Level: "warn", Format: "json", File: path}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("hidden")
+	slog.Warn("shown")
+	closer.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("want a single json line, got %q", data)
+	}
+	if got["msg"] != "shown" {
+		t.Errorf("msg = %v", got["msg"])
+	}
+
+	if _, err := logging.Setup(config.Logging{Level: "loud"}, false); err == nil {
+		t.Error("want error for unknown level")
+	}
+	if _, err := logging.Setup(config.Logging{Format: "xml"}, false); err == nil {
+		t.Error("want error for unknown format")
+	}
+}
+
+func TestDigest(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	args := `{"command":"curl -H 'Authorization: secret'"}`
+	logger.Warn("tool call failed", logging.Digest("arguments", args))
+
+	if bytes.Contains(buf.Bytes(), []byte("secret")) {
+		t.Fatalf("arguments leaked into the log: %s", buf.Bytes())
+	}
+	var got struct {
+		Arguments struct {
+			Len    int    `json:"len"`
+			SHA256 string `json:"sha256"`
+		} `json:"arguments"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Arguments.Len != len(args) || len(got.Arguments.SHA256) != 12 {
+		t.Errorf("arguments = %+v", got.Arguments)
+	}
+}
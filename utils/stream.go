@@ -1,8 +1,8 @@
 package utils
 
 import (
+	"fmt"
 	"io"
-	"log"
 
 	"github.com/cloudwego/eino/schema"
 )
@@ -17,7 +17,7 @@ func DealStream(sr *schema.StreamReader[*schema.Message], onRecv func(message *s
 			return schema.ConcatMessages(msgs)
 		}
 		if err != nil {
-			log.Fatalf("recv failed: %v", err)
+			return nil, fmt.Errorf("recv failed: %w", err)
 		}
 		onRecv(message)
 		msgs = append(msgs, message)
@@ -0,0 +1,420 @@
+package text
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/mattn/go-runewidth"
+)
+
+// ruleWidth 分隔线的宽度
+const ruleWidth = 40
+
+var (
+	orderedItem  = regexp.MustCompile(`^(\d+[.)])\s+(.*)$`)
+	tableDivider = regexp.MustCompile(`^:?-+:?$`)
+	ansiEscape   = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+)
+
+// Markdown 把流式输出的 markdown 渲染到终端
+// 代码块、表格与标题等块级元素在整行到达后渲染; 普通段落在行内标记闭合处分段输出, 不必等到换行
+type Markdown struct {
+	out   io.Writer
+	theme Theme
+
+	// pending 当前行尚未输出的部分
+	pending string
+	// partial 为 true 表示当前行的前一部分已经作为段落输出
+	partial bool
+	// fence 不为空表示在代码块中, code 为代码块已到达的行
+	fence string
+	lang  string
+	code  []string
+	table [][]string
+	// whole 为 true 时文本已完整到达, 代码块在结束时整块高亮
+	whole bool
+}
+
+// NewMarkdown 返回以 theme 渲染到 w 的 Markdown
+func NewMarkdown(w io.Writer, theme Theme) *Markdown {
+	return &Markdown{out: w, theme: theme}
+}
+
+// RenderMarkdown 渲染一段完整的 markdown
+func RenderMarkdown(s string, theme Theme) string {
+	b := NewMarkdownBuffer(theme)
+	b.WriteString(s)
+	return b.String()
+}
+
+// MarkdownBuffer 累积流式到达的完整 markdown, 已结束的行只渲染一次
+// String 只重新渲染末尾尚未结束的行、代码块与表格, 长回答逐块到达时不必每次从头渲染
+type MarkdownBuffer struct {
+	done strings.Builder
+	md   *Markdown
+}
+
+// NewMarkdownBuffer 返回以 theme 渲染的 MarkdownBuffer
+func NewMarkdownBuffer(theme Theme) *MarkdownBuffer {
+	b := &MarkdownBuffer{}
+	b.md = NewMarkdown(&b.done, theme)
+	b.md.whole = true
+	return b
+}
+
+// WriteString 追加一段 markdown
+func (b *MarkdownBuffer) WriteString(s string) {
+	b.md.Write([]byte(s))
+}
+
+// String 返回目前为止的渲染结果, 与对全部文本调用 RenderMarkdown 相同
+func (b *MarkdownBuffer) String() string {
+	// 在副本上结束末尾的块, 缓冲区本身的状态不变, 之后仍可继续追加
+	tail := *b.md
+	var rest strings.Builder
+	tail.out = &rest
+	tail.Flush()
+	return b.done.String() + rest.String()
+}
+
+func (m *Markdown) Write(p []byte) (int, error) {
+	m.pending += string(p)
+	for {
+		i := strings.IndexByte(m.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := m.pending[:i]
+		m.pending = m.pending[i+1:]
+		if out, ok := m.endLine(line); ok {
+			io.WriteString(m.out, out+"\n")
+		}
+	}
+	m.streamParagraph()
+	return len(p), nil
+}
+
+// Flush 输出尚未结束的行与表格, 并结束未闭合的代码块, 在模型的一次输出结束时调用
+func (m *Markdown) Flush() {
+	if m.pending != "" || m.partial {
+		if out, ok := m.endLine(m.pending); ok {
+			io.WriteString(m.out, out)
+		}
+	}
+	if len(m.table) > 0 {
+		io.WriteString(m.out, m.renderTable())
+	}
+	if m.whole && len(m.code) > 0 {
+		io.WriteString(m.out, m.highlight(m.code))
+	}
+	m.pending, m.partial = "", false
+	m.fence, m.lang, m.code = "", "", nil
+}
+
+// streamParagraph 输出段落行中行内标记已经闭合的部分
+func (m *Markdown) streamParagraph() {
+	if m.pending == "" || m.fence != "" {
+		return
+	}
+	if !m.partial {
+		// 行首可能是块级标记时等待整行
+		r, _ := utf8.DecodeRuneInString(m.pending)
+		if strings.ContainsRune("#>-*+|`~=_ \t0123456789", r) {
+			return
+		}
+		if len(m.table) > 0 {
+			io.WriteString(m.out, m.renderTable()+"\n")
+		}
+	}
+	n := safePrefix(m.pending)
+	if n == 0 {
+		return
+	}
+	io.WriteString(m.out, m.inline(m.pending[:n]))
+	m.pending = m.pending[n:]
+	m.partial = true
+}
+
+// endLine 渲染一行的剩余部分, 缓存的表格行不输出, 返回 false
+func (m *Markdown) endLine(line string) (string, bool) {
+	if m.partial {
+		m.partial = false
+		return m.inline(line), true
+	}
+	return m.renderLine(line)
+}
+
+// renderLine 渲染完整的一行, 表格行先缓存, 表格结束后一起对齐输出
+func (m *Markdown) renderLine(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if m.fence != "" {
+		if strings.HasPrefix(trimmed, m.fence) && strings.Trim(trimmed, m.fence[:1]) == "" {
+			out := m.theme.Rule.Render(line)
+			if m.whole && len(m.code) > 0 {
+				out = m.highlight(m.code) + "\n" + out
+			}
+			m.fence, m.lang, m.code = "", "", nil
+			return out, true
+		}
+		m.code = append(m.code, line)
+		if m.whole {
+			return "", false
+		}
+		return m.highlight(m.code[len(m.code)-1:]), true
+	}
+	if strings.HasPrefix(trimmed, "|") {
+		m.table = append(m.table, splitRow(trimmed))
+		return "", false
+	}
+	var b strings.Builder
+	if len(m.table) > 0 {
+		b.WriteString(m.renderTable())
+		b.WriteString("\n")
+	}
+
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	switch {
+	case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+		m.fence = trimmed[:3]
+		m.lang = strings.TrimSpace(strings.TrimLeft(trimmed, m.fence[:1]))
+		b.WriteString(m.theme.Rule.Render(line))
+	case isHeading(trimmed):
+		if m.theme.Heading == "" {
+			b.WriteString(line)
+		} else {
+			b.WriteString(m.theme.Heading.Render(strings.TrimSpace(strings.TrimLeft(trimmed, "#"))))
+		}
+	case isRule(trimmed):
+		b.WriteString(m.theme.Rule.Render(strings.Repeat("─", ruleWidth)))
+	case strings.HasPrefix(trimmed, ">"):
+		b.WriteString(indent + m.theme.Quote.Render("│ "+m.inline(strings.TrimSpace(trimmed[1:]))))
+	case len(trimmed) > 1 && strings.ContainsRune("-*+", rune(trimmed[0])) && trimmed[1] == ' ':
+		b.WriteString(indent + m.theme.Bullet.Render("•") + " " + m.inline(strings.TrimSpace(trimmed[2:])))
+	default:
+		if sub := orderedItem.FindStringSubmatch(trimmed); sub != nil {
+			b.WriteString(indent + m.theme.Bullet.Render(sub[1]) + " " + m.inline(sub[2]))
+		} else {
+			b.WriteString(m.inline(line))
+		}
+	}
+	return b.String(), true
+}
+
+// renderTable 按列宽对齐缓存的表格行, 分隔行之前的行作为表头
+func (m *Markdown) renderTable() string {
+	rows := m.table
+	m.table = nil
+	header := -1
+	var cells [][]string
+	for _, row := range rows {
+		if header < 0 && len(cells) > 0 && isDivider(row) {
+			header = len(cells)
+			continue
+		}
+		rendered := make([]string, len(row))
+		for i, c := range row {
+			rendered[i] = m.inline(c)
+		}
+		cells = append(cells, rendered)
+	}
+	var widths []int
+	for _, row := range cells {
+		for i, c := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], visibleWidth(c))
+		}
+	}
+
+	sep := m.theme.Table.Render(" │ ")
+	var lines []string
+	for r, row := range cells {
+		if r == header {
+			parts := make([]string, len(widths))
+			for i, w := range widths {
+				parts[i] = strings.Repeat("─", w)
+			}
+			lines = append(lines, m.theme.Table.Render(strings.Join(parts, "─┼─")))
+		}
+		parts := make([]string, len(widths))
+		for i, w := range widths {
+			c := ""
+			if i < len(row) {
+				c = row[i]
+			}
+			if r < header {
+				c = m.theme.Strong.Render(c)
+			}
+			parts[i] = c + strings.Repeat(" ", w-visibleWidth(c))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(parts, sep), " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// highlight 高亮代码块中从 lines 开始的行, lines 是 m.code 的末尾部分
+// 流式输出时每次只输出最后一行, 但整块重新分词, 跨行的字符串与注释也能正确着色
+func (m *Markdown) highlight(lines []string) string {
+	raw := strings.Join(lines, "\n")
+	if m.theme.CodeStyle == "" || m.lang == "" {
+		return raw
+	}
+	lexer := lexers.Get(m.lang)
+	if lexer == nil {
+		return raw
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, strings.Join(m.code, "\n")+"\n")
+	if err != nil {
+		return raw
+	}
+	split := chroma.SplitTokensIntoLines(it.Tokens())
+	if len(split) < len(m.code) {
+		return raw
+	}
+	var tokens []chroma.Token
+	for _, line := range split[len(m.code)-len(lines) : len(m.code)] {
+		tokens = append(tokens, line...)
+	}
+	if n := len(tokens); n > 0 {
+		tokens[n-1].Value = strings.TrimSuffix(tokens[n-1].Value, "\n")
+	}
+	var b bytes.Buffer
+	if err := codeFormatter().Format(&b, styles.Get(m.theme.CodeStyle), chroma.Literator(tokens...)); err != nil {
+		return raw
+	}
+	return b.String()
+}
+
+func codeFormatter() chroma.Formatter {
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return formatters.Get("terminal16m")
+	}
+	return formatters.Get("terminal256")
+}
+
+// inline 渲染行内的代码、粗体、斜体与链接, 对应样式为空时保留原来的标记
+func (m *Markdown) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				b.WriteString(keepMarker(m.theme.InlineCode, "`", s[i+1:i+1+end]))
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(s[i:], "**"):
+			if end := strings.Index(s[i+2:], "**"); end > 0 {
+				b.WriteString(keepMarker(m.theme.Strong, "**", s[i+2:i+2+end]))
+				i += end + 4
+				continue
+			}
+		case s[i] == '*' && i+1 < len(s) && s[i+1] != ' ':
+			if end := strings.IndexByte(s[i+1:], '*'); end > 0 {
+				b.WriteString(keepMarker(m.theme.Emphasis, "*", s[i+1:i+1+end]))
+				i += end + 2
+				continue
+			}
+		case s[i] == '[':
+			if mid := strings.Index(s[i:], "]("); mid > 0 {
+				if end := strings.IndexByte(s[i+mid:], ')'); end > 0 {
+					text, url := s[i+1:i+mid], s[i+mid+2:i+mid+end]
+					if m.theme.Link == "" {
+						b.WriteString(s[i : i+mid+end+1])
+					} else {
+						b.WriteString(m.theme.Link.Render(text))
+						if url != text {
+							b.WriteString(" (" + url + ")")
+						}
+					}
+					i += mid + end + 1
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+func keepMarker(style Style, marker, content string) string {
+	if style == "" {
+		return marker + content + marker
+	}
+	return style.Render(content)
+}
+
+// safePrefix 返回 s 中行内标记都已闭合的最长前缀的长度, 末尾可能开始新标记的字符留到下次
+func safePrefix(s string) int {
+	safe := 0
+	code, strong, em, link := false, false, false, false
+	for i := 0; i < len(s); i++ {
+		if !(code || strong || em || link) && utf8.RuneStart(s[i]) {
+			safe = i
+		}
+		switch c := s[i]; {
+		case c == '`':
+			code = !code
+		case code:
+		case c == '*' && i+1 < len(s) && s[i+1] == '*':
+			strong = !strong
+			i++
+		case c == '*' && em:
+			em = false
+		case c == '*' && (i+1 == len(s) || s[i+1] != ' '):
+			em = true
+		case c == '[':
+			link = true
+		case c == ')' && link:
+			link = false
+		}
+	}
+	if !(code || strong || em || link) && !strings.ContainsRune("`*[\\", rune(s[len(s)-1])) {
+		return len(s)
+	}
+	return safe
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells
+}
+
+func isDivider(row []string) bool {
+	for _, c := range row {
+		if !tableDivider.MatchString(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHeading(s string) bool {
+	n := len(s) - len(strings.TrimLeft(s, "#"))
+	return n >= 1 && n <= 6 && len(s) > n && s[n] == ' '
+}
+
+func isRule(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	return len(s) >= 3 && (strings.Trim(s, "-") == "" || strings.Trim(s, "*") == "" || strings.Trim(s, "_") == "")
+}
+
+// visibleWidth 返回去掉转义序列后文本在终端中占的列数
+func visibleWidth(s string) int {
+	return runewidth.StringWidth(ansiEscape.ReplaceAllString(s, ""))
+}
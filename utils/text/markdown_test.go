@@ -0,0 +1,102 @@
+package text
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownPlainTheme(t *testing.T) {
+	plain, _ := LookupTheme("plain")
+	in := "# 标题\n- 第一项 **粗体**\n  2. 子项\n> 引用\n---\n| 名称 | 说明 |\n|---|---|\n| a | 很长的说明 |\n| bb | c |\n结束 `code`\n"
+	want := "# 标题\n• 第一项 **粗体**\n  2. 子项\n│ 引用\n" + strings.Repeat("─", ruleWidth) + "\n" +
+		"名称 │ 说明\n─────┼───────────\na    │ 很长的说明\nbb   │ c\n" +
+		"结束 `code`\n"
+	if got := RenderMarkdown(in, plain); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMarkdownStylesInline(t *testing.T) {
+	dark, _ := LookupTheme("dark")
+	got := RenderMarkdown("see **this** and [docs](https://example.com)", dark)
+	want := "see " + dark.Strong.Render("this") + " and " + dark.Link.Render("docs") + " (https://example.com)"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownStreamsParagraphs(t *testing.T) {
+	plain, _ := LookupTheme("plain")
+	var b strings.Builder
+	md := NewMarkdown(&b, plain)
+
+	md.Write([]byte("Hello wor"))
+	if b.String() != "Hello wor" {
+		t.Errorf("paragraph text should stream before the newline, got %q", b.String())
+	}
+	// 未闭合的行内标记等闭合后再输出
+	md.Write([]byte("ld, **bo"))
+	if b.String() != "Hello world, " {
+		t.Errorf("open markup should be held back, got %q", b.String())
+	}
+	md.Write([]byte("ld** done\n- item"))
+	if b.String() != "Hello world, **bold** done\n" {
+		t.Errorf("list items wait for the whole line, got %q", b.String())
+	}
+	md.Flush()
+	if b.String() != "Hello world, **bold** done\n• item" {
+		t.Errorf("flush should render the rest, got %q", b.String())
+	}
+}
+
+func TestMarkdownHighlightsCode(t *testing.T) {
+	dark, _ := LookupTheme("dark")
+	var b strings.Builder
+	md := NewMarkdown(&b, dark)
+	md.Write([]byte("```go\nfunc main() {\n"))
+	if !strings.Contains(b.String(), "\x1b[") || !strings.Contains(b.String(), "main") {
+		t.Errorf("code line should be highlighted as it arrives, got %q", b.String())
+	}
+	md.Write([]byte("}\n```\n"))
+	md.Flush()
+
+	whole := RenderMarkdown("```go\nfunc main() {\n}\n```", dark)
+	if got := ansiEscape.ReplaceAllString(whole, ""); got != "```go\nfunc main() {\n}\n```" {
+		t.Errorf("highlighting should not change the text, got %q", got)
+	}
+	if ansiEscape.ReplaceAllString(b.String(), "") != "```go\nfunc main() {\n}\n```\n" {
+		t.Errorf("streamed code = %q", b.String())
+	}
+
+	plain, _ := LookupTheme("plain")
+	if got := RenderMarkdown("```go\nfunc main() {}\n```", plain); got != "```go\nfunc main() {}\n```" {
+		t.Errorf("plain theme should not highlight, got %q", got)
+	}
+}
+
+func TestMarkdownBufferMatchesWholeRender(t *testing.T) {
+	dark, _ := LookupTheme("dark")
+	in := "# Title\n\nsee **this** and `code` here\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n```go\nfunc main() {\n\t\"s\"\n}\n```\n- item *one*\n1. two"
+	b := NewMarkdownBuffer(dark)
+	for i := 0; i < len(in); i += 3 {
+		b.WriteString(in[i:min(i+3, len(in))])
+		// 中途取结果不影响之后的渲染
+		if want, got := RenderMarkdown(in[:min(i+3, len(in))], dark), b.String(); got != want {
+			t.Fatalf("after %d bytes:\n%q\nwant\n%q", i+3, got, want)
+		}
+	}
+}
+
+func TestThemeForNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if th := ThemeFor("dark"); th.Name != "plain" {
+		t.Errorf("NO_COLOR theme = %s", th.Name)
+	}
+	if got := Colorize("x", Black, BgYellow); got != "x" {
+		t.Errorf("Colorize with NO_COLOR = %q", got)
+	}
+	t.Setenv("NO_COLOR", "")
+	if th := ThemeFor("nope"); th.Name != DefaultTheme {
+		t.Errorf("unknown theme = %s", th.Name)
+	}
+}
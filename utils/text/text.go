@@ -27,7 +27,10 @@ const (
 	BgWhite
 )
 
-// Colorize 返回带有颜色的字符串
+// Colorize 返回带有颜色的字符串, 设置了 NO_COLOR 时原样返回
 func Colorize(text string, fg ANSIColor, bg ANSIColor) string {
+	if NoColor() {
+		return text
+	}
 	return fmt.Sprintf("\033[%d;%dm%s\033[0m", fg, bg, text)
 }
@@ -0,0 +1,136 @@
+package text
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// 文本属性, 与颜色一起组成 Style
+const (
+	AttrBold      ANSIColor = 1
+	AttrFaint     ANSIColor = 2
+	AttrItalic    ANSIColor = 3
+	AttrUnderline ANSIColor = 4
+)
+
+// Style 是一组 ANSI SGR 参数, 例如 "1;36", 为空时不改变文本
+type Style string
+
+// NewStyle 组合颜色与属性
+func NewStyle(codes ...ANSIColor) Style {
+	parts := make([]string, len(codes))
+	for i, c := range codes {
+		parts[i] = strconv.Itoa(int(c))
+	}
+	return Style(strings.Join(parts, ";"))
+}
+
+// Render 返回带有样式的文本
+func (s Style) Render(text string) string {
+	if s == "" || text == "" {
+		return text
+	}
+	return "\033[" + string(s) + "m" + text + "\033[0m"
+}
+
+// Theme 是终端输出使用的一组样式, 样式为空的元素按原样输出
+type Theme struct {
+	Name       string
+	Heading    Style
+	Strong     Style
+	Emphasis   Style
+	InlineCode Style
+	Link       Style
+	Quote      Style
+	Bullet     Style
+	Rule       Style
+	Table      Style
+	ToolCall   Style
+	ToolError  Style
+	// CodeStyle 代码块高亮使用的 chroma 配色, 为空时代码块不高亮
+	CodeStyle string
+}
+
+var themes = map[string]Theme{
+	"dark": {
+		Name:       "dark",
+		Heading:    NewStyle(AttrBold, Cyan),
+		Strong:     NewStyle(AttrBold),
+		Emphasis:   NewStyle(AttrItalic),
+		InlineCode: NewStyle(Yellow),
+		Link:       NewStyle(AttrUnderline, Blue),
+		Quote:      NewStyle(AttrFaint, AttrItalic),
+		Bullet:     NewStyle(Cyan),
+		Rule:       NewStyle(AttrFaint),
+		Table:      NewStyle(AttrFaint),
+		ToolCall:   NewStyle(Black, BgYellow),
+		ToolError:  NewStyle(White, BgRed),
+		CodeStyle:  "monokai",
+	},
+	"light": {
+		Name:       "light",
+		Heading:    NewStyle(AttrBold, Blue),
+		Strong:     NewStyle(AttrBold),
+		Emphasis:   NewStyle(AttrItalic),
+		InlineCode: NewStyle(Magenta),
+		Link:       NewStyle(AttrUnderline, Blue),
+		Quote:      NewStyle(AttrFaint, AttrItalic),
+		Bullet:     NewStyle(Blue),
+		Rule:       NewStyle(AttrFaint),
+		Table:      NewStyle(AttrFaint),
+		ToolCall:   NewStyle(Black, BgYellow),
+		ToolError:  NewStyle(White, BgRed),
+		CodeStyle:  "github",
+	},
+	// plain 不输出任何转义序列, 用于 NO_COLOR
+	"plain": {Name: "plain"},
+}
+
+// DefaultTheme 未指定主题时使用的主题
+const DefaultTheme = "dark"
+
+// LookupTheme 按名称返回主题, 名称为空时返回默认主题
+func LookupTheme(name string) (Theme, bool) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	t, ok := themes[name]
+	return t, ok
+}
+
+// ThemeNames 按名称顺序返回所有主题的名称
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NoColor 报告是否设置了 NO_COLOR 环境变量, 见 https://no-color.org
+func NoColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// IsTerminal 报告 w 是否为终端
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// ThemeFor 返回在终端上使用的主题, 设置了 NO_COLOR 时为 plain, 未知的名称使用默认主题(终端对话启动时已拒绝未知的名称)
+func ThemeFor(name string) Theme {
+	if NoColor() {
+		return themes["plain"]
+	}
+	if t, ok := LookupTheme(name); ok {
+		return t
+	}
+	return themes[DefaultTheme]
+}